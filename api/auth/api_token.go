@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"regexp"
+	"time"
+)
+
+// apiTokenPrefix is put in front of every generated API token, so they are easy to recognize, e.g. by secret scanners.
+const apiTokenPrefix = "gobble_"
+
+// ApiToken is a named bearer token that authenticates as the ApiUser it belongs to, e.g. for CI pipelines.
+// Only the hash of the token is stored; the token itself is only shown once, when it is created.
+type ApiToken struct {
+	Id     uuid.UUID
+	UserId uuid.UUID
+	Name   string
+	Hash   string
+	// CreatedAt is when the token was created.
+	CreatedAt time.Time
+	// ExpiresAt is when the token stops working, which is the zero time if it never expires.
+	ExpiresAt time.Time
+	// LastUsedAt is when the token was last used to authenticate, which is the zero time if it has never been used.
+	LastUsedAt time.Time
+}
+
+func NewApiToken(id uuid.UUID, userId uuid.UUID, name string, hash string, createdAt time.Time, expiresAt time.Time) (ApiToken, error) {
+	var t ApiToken
+
+	if err := validateApiTokenName(name); err != nil {
+		return t, err
+	}
+
+	if !expiresAt.IsZero() && !expiresAt.After(createdAt) {
+		return t, errors.New("expiry must be after the creation time")
+	}
+
+	return ApiToken{
+		Id:        id,
+		UserId:    userId,
+		Name:      name,
+		Hash:      hash,
+		CreatedAt: createdAt,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GenerateApiToken generates a new random API token. Only its hash (see HashApiToken) should be stored.
+func GenerateApiToken() (string, error) {
+	token, err := RandomHex(32)
+	if err != nil {
+		return "", err
+	}
+
+	return apiTokenPrefix + token, nil
+}
+
+// HashApiToken returns the hash of the passed API token, which is what is stored and looked up.
+// API tokens are long and random, so a fast hash is enough, unlike for passwords.
+func HashApiToken(token string) string {
+	return hashToken(token)
+}
+
+// RandomHex returns n random bytes encoded as hex, e.g. for tokens and other secrets.
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the SHA-256 hash of a long and random token, encoded as hex.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// IsExpired returns whether the token has expired at the passed time.
+func (t ApiToken) IsExpired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+func validateApiTokenName(name string) error {
+	p := "^[a-zA-Z0-9-_. ]{1,64}$"
+	matched, err := regexp.MatchString(p, name)
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return errors.New("token name contains illegal characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryTokenRepository is a simple in-memory ApiTokenRepository for testing.
+type memoryTokenRepository map[uuid.UUID]ApiToken
+
+func (r memoryTokenRepository) GetApiTokensByUserId(userId uuid.UUID) ([]ApiToken, error) {
+	var tokens []ApiToken
+	for _, t := range r {
+		if t.UserId == userId {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens, nil
+}
+
+func (r memoryTokenRepository) GetApiTokenById(id uuid.UUID) (ApiToken, error) {
+	t, ok := r[id]
+	if !ok {
+		return t, repository.ErrNotFound
+	}
+	return t, nil
+}
+
+func (r memoryTokenRepository) GetApiTokenByHash(hash string) (ApiToken, error) {
+	for _, t := range r {
+		if t.Hash == hash {
+			return t, nil
+		}
+	}
+	return ApiToken{}, repository.ErrNotFound
+}
+
+func (r memoryTokenRepository) SetApiToken(t ApiToken) error {
+	r[t.Id] = t
+	return nil
+}
+
+func (r memoryTokenRepository) SetApiTokenLastUsed(id uuid.UUID, lastUsedAt time.Time) error {
+	t, ok := r[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	t.LastUsedAt = lastUsedAt
+	r[id] = t
+	return nil
+}
+
+func (r memoryTokenRepository) DeleteApiTokenById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func TestNewApiToken(t *testing.T) {
+	now := time.Now()
+	for _, expiresAt := range []time.Time{{}, now.Add(time.Hour)} {
+		_, err := NewApiToken(uuid.New(), uuid.New(), "ci pipeline-1", "hash", now, expiresAt)
+		if err != nil {
+			t.Fatalf(`NewApiToken() with expiry '%v' returned error: %v`, expiresAt, err)
+		}
+	}
+}
+
+func TestNewApiTokenInvalid(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+	}{
+		{"", time.Time{}},
+		{"token/1", time.Time{}},
+		{strings.Repeat("a", 65), time.Time{}},
+		{"token", now},
+		{"token", now.Add(-time.Hour)},
+	}
+
+	for _, test := range tests {
+		actual, err := NewApiToken(uuid.New(), uuid.New(), test.name, "hash", now, test.expiresAt)
+		if err == nil {
+			t.Fatalf(`Expected NewApiToken() to return error for name '%s' and expiry '%v', got: %v, %v`, test.name, test.expiresAt, actual, err)
+		}
+	}
+}
+
+func TestApiTokenIsExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		expiresAt time.Time
+		expected  bool
+	}{
+		{time.Time{}, false},
+		{now.Add(time.Minute), false},
+		{now, true},
+		{now.Add(-time.Minute), true},
+	}
+
+	for _, test := range tests {
+		token := ApiToken{ExpiresAt: test.expiresAt}
+		if actual := token.IsExpired(now); actual != test.expected {
+			t.Fatalf(`IsExpired() with expiry '%v' = %v, expected: %v`, test.expiresAt, actual, test.expected)
+		}
+	}
+}
+
+func TestGenerateApiToken(t *testing.T) {
+	first, err := GenerateApiToken()
+	if err != nil {
+		t.Fatalf(`GenerateApiToken() returned error: %v`, err)
+	}
+	second, _ := GenerateApiToken()
+
+	if !strings.HasPrefix(first, apiTokenPrefix) || first == second {
+		t.Fatalf(`GenerateApiToken() returned '%s' and '%s', expected two different tokens starting with '%s'`, first, second, apiTokenPrefix)
+	}
+
+	if HashApiToken(first) != HashApiToken(first) || HashApiToken(first) == HashApiToken(second) {
+		t.Fatalf(`HashApiToken() is not deterministic or returned the same hash for different tokens`)
+	}
+}
+
+func TestApiAuthBearerToken(t *testing.T) {
+	user := NewApiUser(uuid.New(), "ci", nil, RoleOperator)
+	users := memoryRepository{user.Id: user}
+	tokens := memoryTokenRepository{}
+
+	now := time.Now()
+	valid, _ := NewApiToken(uuid.New(), user.Id, "valid", HashApiToken("gobble_valid"), now, time.Time{})
+	expired, _ := NewApiToken(uuid.New(), user.Id, "expired", HashApiToken("gobble_expired"), now.Add(-time.Hour), now.Add(-time.Minute))
+	_ = tokens.SetApiToken(valid)
+	_ = tokens.SetApiToken(expired)
+
+	b, err := NewBasicAuthenticator(users)
+	if err != nil {
+		t.Fatalf(`NewBasicAuthenticator() returned error: %v`, err)
+	}
+
+	handler := ApiAuth(b, tokens, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := UserFromContext(r.Context())
+		if !ok || u.Id != user.Id {
+			t.Fatalf(`Expected user %s in the request context, got: %v, %v`, user.Id, u, ok)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		header   string
+		expected int
+	}{
+		{"Bearer gobble_valid", http.StatusOK},
+		{"bearer gobble_valid", http.StatusOK},
+		{"Bearer gobble_expired", http.StatusUnauthorized},
+		{"Bearer gobble_unknown", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/systems", nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.expected {
+			t.Fatalf(`Request with Authorization header '%s' returned status %d, expected: %d`, test.header, w.Code, test.expected)
+		}
+	}
+
+	if tokens[valid.Id].LastUsedAt.IsZero() {
+		t.Fatalf(`Expected the last used time of the token to be recorded`)
+	}
+}
+
+// externalAuthenticator is an ExternalAuthenticator for testing, which accepts a single token.
+type externalAuthenticator struct {
+	token string
+	user  ApiUser
+}
+
+func (e externalAuthenticator) AuthenticateToken(ctx context.Context, token string) (ApiUser, error) {
+	if token != e.token {
+		return ApiUser{}, errors.New("invalid token")
+	}
+	return e.user, nil
+}
+
+func TestApiAuthExternal(t *testing.T) {
+	local := NewApiUser(uuid.New(), "ci", nil, RoleOperator)
+	external := externalAuthenticator{"eyJ.external.jwt", NewApiUser(uuid.New(), "alice", nil, RoleAdmin)}
+
+	tokens := memoryTokenRepository{}
+	apiToken, _ := NewApiToken(uuid.New(), local.Id, "ci", HashApiToken("gobble_valid"), time.Now(), time.Time{})
+	_ = tokens.SetApiToken(apiToken)
+
+	b, _ := NewBasicAuthenticator(memoryRepository{local.Id: local})
+	var authenticated ApiUser
+	handler := ApiAuth(b, tokens, external)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, _ = UserFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		token    string
+		expected int
+		user     ApiUser
+	}{
+		{"gobble_valid", http.StatusOK, local},
+		{"eyJ.external.jwt", http.StatusOK, external.user},
+		{"eyJ.other.jwt", http.StatusUnauthorized, ApiUser{}},
+	}
+
+	for _, test := range tests {
+		authenticated = ApiUser{}
+		r := httptest.NewRequest(http.MethodGet, "/api/systems", nil)
+		r.Header.Set("Authorization", "Bearer "+test.token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.expected || authenticated.Id != test.user.Id {
+			t.Fatalf(`Request with bearer token '%s' returned status %d and user %v, expected: %d and %v`, test.token, w.Code, authenticated, test.expected, test.user)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -9,16 +10,43 @@ type ApiUser struct {
 	Id       uuid.UUID
 	Name     string
 	Password []byte
+	Role     Role
 }
 
-func NewApiUser(id uuid.UUID, name string, password []byte) ApiUser {
+func NewApiUser(id uuid.UUID, name string, password []byte, role Role) ApiUser {
 	return ApiUser{
 		Id:       id,
 		Name:     name,
 		Password: password,
+		Role:     role,
 	}
 }
 
 func (u ApiUser) CheckPassword(password string) error {
 	return bcrypt.CompareHashAndPassword(u.Password, []byte(password))
 }
+
+// ErrLastAdmin is returned when the last admin would be deleted or lose their role.
+var ErrLastAdmin = errors.New("the last admin cannot be deleted or lose the admin role")
+
+// IsLastAdmin returns whether the user with the passed ID is the only admin, who cannot be deleted or lose their role
+// without locking everyone out of managing users.
+func IsLastAdmin(r ApiUserRepository, id uuid.UUID) (bool, error) {
+	users, err := r.GetApiUsers()
+	if err != nil {
+		return false, err
+	}
+
+	isAdmin := false
+	admins := 0
+	for _, u := range users {
+		if u.Role == RoleAdmin {
+			admins++
+			if u.Id == id {
+				isAdmin = true
+			}
+		}
+	}
+
+	return isAdmin && admins == 1, nil
+}
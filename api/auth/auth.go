@@ -1,51 +1,230 @@
 package auth
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/repository"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
-// ApiBasicAuth will check the basic auth credentials sent in the request against the known users,
-// and return a JSON response if authentication has failed.
-func ApiBasicAuth(db ApiUserRepository) func(next http.Handler) http.Handler {
-	return basicAuth(db, sendBasicAuthFailedResponse)
-}
+var (
+	errNoCredentials  = errors.New("no credentials were sent")
+	errExpiredToken   = errors.New("API token has expired")
+	errExpiredSession = errors.New("session has expired")
+)
 
-// BrowserBasicAuth will check the basic auth credentials sent in the request against the known users,
-// and (re)-request basic auth credentials if authentication has failed.
-func BrowserBasicAuth(db ApiUserRepository) func(next http.Handler) http.Handler {
-	return basicAuth(db, requestBasicAuth)
+// ExternalAuthenticator authenticates users of an external identity provider by a bearer token, e.g. a JWT issued by an OpenID Connect provider.
+type ExternalAuthenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (ApiUser, error)
 }
 
-// basicAuth will check the basic auth credentials sent in the request against the known users,
-// and execute the passed callback if authentication has failed.
-func basicAuth(db ApiUserRepository, authFailureCallback func(w http.ResponseWriter)) func(next http.Handler) http.Handler {
+// ApiAuth will authenticate the request using the API token sent as a bearer token, or otherwise the basic auth credentials
+// sent in the request, and return a JSON response if authentication has failed. Bearer tokens that are not API tokens
+// are passed to the external authenticator, if there is one.
+func ApiAuth(b *BasicAuthenticator, tokens ApiTokenRepository, external ExternalAuthenticator) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			user, pass, ok := r.BasicAuth()
-			if !ok {
-				authFailureCallback(w)
-				return
+			var apiUser ApiUser
+			var err error
+
+			if token, ok := bearerToken(r); ok {
+				apiUser, err = authenticateBearerToken(r.Context(), b, tokens, external, remoteAddress(r), token)
+			} else {
+				apiUser, err = authenticateBasicAuth(b, r)
 			}
 
-			apiUser, err := db.GetApiUserByName(user)
 			if err != nil {
-				authFailureCallback(w)
+				if errors.Is(err, ErrLockedOut) {
+					sendLockedOutResponse(w)
+					return
+				}
+
+				sendBasicAuthFailedResponse(w)
 				return
 			}
 
-			err = apiUser.CheckPassword(pass)
+			next.ServeHTTP(w, r.WithContext(WithUser(r.Context(), apiUser)))
+		})
+	}
+}
+
+// BrowserSessionAuth will check the session cookie sent in the request against the known sessions,
+// and redirect to the passed login URL if there is no valid session.
+func BrowserSessionAuth(sessions SessionRepository, users ApiUserRepository, loginUrl string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, apiUser, err := authenticateSession(sessions, users, r)
 			if err != nil {
-				authFailureCallback(w)
+				if !errors.Is(err, errNoCredentials) && !errors.Is(err, errExpiredSession) && !errors.Is(err, repository.ErrNotFound) {
+					log.Println(err)
+					http.Error(w, "internal server error", http.StatusInternalServerError)
+					return
+				}
+
+				redirectToLogin(w, r, loginUrl)
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := WithSession(WithUser(r.Context(), apiUser), s)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// VerifyCsrfToken will check that requests that change something contain the CSRF token of the session in their form,
+// and refuse them if they do not. It has to be used after BrowserSessionAuth, which adds the session to the request.
+func VerifyCsrfToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnly(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s, ok := SessionFromContext(r.Context())
+		if !ok || !s.CheckCsrfToken(r.PostFormValue(CsrfFieldName)) {
+			http.Error(w, "Forbidden: invalid or missing CSRF token, reload the page and try again", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateBasicAuth returns the user that the basic auth credentials sent in the request belong to,
+// or an error if there are none or they are invalid.
+func authenticateBasicAuth(b *BasicAuthenticator, r *http.Request) (ApiUser, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return ApiUser{}, errNoCredentials
+	}
+
+	return b.Authenticate(remoteAddress(r), user, pass)
+}
+
+// remoteAddress returns the IP address that the request came from, without the port.
+func remoteAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// authenticateSession returns the session that the cookie sent in the request belongs to and its user,
+// or an error if there is none or it has expired. Expired sessions are deleted.
+func authenticateSession(sessions SessionRepository, users ApiUserRepository, r *http.Request) (Session, ApiUser, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return Session{}, ApiUser{}, errNoCredentials
+	}
+
+	s, err := sessions.GetSessionByHash(hashToken(cookie.Value))
+	if err != nil {
+		return Session{}, ApiUser{}, err
+	}
+
+	if s.IsExpired(time.Now()) {
+		if err := sessions.DeleteSessionById(s.Id); err != nil {
+			log.Println(err)
+		}
+		return Session{}, ApiUser{}, errExpiredSession
+	}
+
+	if s.External {
+		return s, NewApiUser(s.UserId, s.UserName, nil, s.Role), nil
+	}
+
+	apiUser, err := users.GetApiUserById(s.UserId)
+	if err != nil {
+		return Session{}, ApiUser{}, err
+	}
+
+	return s, apiUser, nil
+}
+
+// redirectToLogin will redirect to the passed login URL. For GET requests, the requested page is passed along,
+// so the user returns to it after logging in.
+func redirectToLogin(w http.ResponseWriter, r *http.Request, loginUrl string) {
+	if r.Method == http.MethodGet {
+		loginUrl += "?next=" + url.QueryEscape(r.URL.RequestURI())
+	}
+
+	http.Redirect(w, r, loginUrl, http.StatusSeeOther)
+}
+
+// bearerToken returns the token sent in the 'Authorization: Bearer <token>' header of the request, and whether there is one.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// authenticateBearerToken returns the user that the bearer token belongs to, which is either an API token or a token of the external authenticator.
+// Invalid tokens count as failed attempts from the address, like invalid basic auth credentials, so tokens can not be guessed without limit either.
+func authenticateBearerToken(ctx context.Context, b *BasicAuthenticator, tokens ApiTokenRepository, external ExternalAuthenticator, address string, token string) (ApiUser, error) {
+	now := b.now()
+	if b.addressLockedOut(address, now) {
+		metrics.Add("lockedOutAttempts", 1)
+		return ApiUser{}, ErrLockedOut
+	}
+
+	if external != nil && !strings.HasPrefix(token, apiTokenPrefix) {
+		apiUser, err := external.AuthenticateToken(ctx, token)
+		if err != nil {
+			metrics.Add("failedTokens", 1)
+			b.failAddress(address, now)
+		}
+		return apiUser, err
+	}
+
+	apiUser, err := authenticateApiToken(b.users, tokens, token)
+	if err != nil {
+		metrics.Add("failedTokens", 1)
+
+		// Only unknown and expired tokens count as failed attempts, not e.g. the database being unavailable
+		if errors.Is(err, repository.ErrNotFound) || errors.Is(err, errExpiredToken) {
+			b.failAddress(address, now)
+		}
+	}
+
+	return apiUser, err
+}
+
+// authenticateApiToken returns the user that the passed API token belongs to, or an error if the token is unknown or has expired.
+// The time the token was used is recorded, but failing to do so does not fail the authentication.
+func authenticateApiToken(users ApiUserRepository, tokens ApiTokenRepository, token string) (ApiUser, error) {
+	t, err := tokens.GetApiTokenByHash(HashApiToken(token))
+	if err != nil {
+		return ApiUser{}, err
+	}
+
+	now := time.Now()
+	if t.IsExpired(now) {
+		return ApiUser{}, errExpiredToken
+	}
+
+	apiUser, err := users.GetApiUserById(t.UserId)
+	if err != nil {
+		return apiUser, err
+	}
+
+	if err := tokens.SetApiTokenLastUsed(t.Id, now); err != nil {
+		log.Println(err)
+	}
+
+	return apiUser, nil
+}
+
 // sendBasicAuthFailedResponse will write a JSON response indicating authentication failure to the passed http.ResponseWriter variable.
 func sendBasicAuthFailedResponse(w http.ResponseWriter) {
 	err := response.Error(w, http.StatusUnauthorized, "authentication failed")
@@ -54,9 +233,10 @@ func sendBasicAuthFailedResponse(w http.ResponseWriter) {
 	}
 }
 
-// sendBasicAuthFailedResponse will request basic authentication by sending the 'WWW-Authenticate' header with a 401 status code.
-func requestBasicAuth(w http.ResponseWriter) {
-	w.Header().Set("WWW-Authenticate", `Basic realm="gobble"`)
-	w.WriteHeader(401)
-	_, _ = fmt.Fprint(w, "Unauthorised")
+// sendLockedOutResponse will write a JSON response indicating that authentication was refused because of too many failed attempts.
+func sendLockedOutResponse(w http.ResponseWriter) {
+	err := response.Error(w, http.StatusTooManyRequests, ErrLockedOut.Error())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"expvar"
+	"github.com/evanebb/gobble/repository"
+	"log"
+	"time"
+)
+
+const (
+	// credentialCacheTTL is how long verified credentials are remembered before bcrypt is used again.
+	credentialCacheTTL = time.Minute
+	// credentialCacheSize is the maximum number of users whose verified credentials are remembered.
+	credentialCacheSize = 1024
+
+	// maxUserFailures is the number of failed attempts for a single user name from a single IP address after which that combination is locked out.
+	// The user name is not locked out on its own, since anyone could then lock out e.g. the admin user by failing on purpose.
+	maxUserFailures = 10
+	// maxAddressFailures is the number of failed attempts from a single IP address after which it is locked out.
+	// This is the global brake on guessing passwords, and it is higher than maxUserFailures, since multiple users can share an address, e.g. behind NAT.
+	maxAddressFailures = 50
+	// failureWindow is how long failed attempts are counted towards a lockout.
+	failureWindow = 15 * time.Minute
+	// lockoutDuration is how long a user name and IP address combination or an IP address stays locked out.
+	lockoutDuration = 15 * time.Minute
+	// failureLimiterSize is the maximum number of user name and IP address combinations or IP addresses whose failed attempts are tracked.
+	failureLimiterSize = 10000
+)
+
+// ErrLockedOut is returned when authentication is refused because of too many failed attempts.
+var ErrLockedOut = errors.New("too many failed authentication attempts, try again later")
+
+// metrics contains the authentication counters, which are published through expvar.
+var metrics = expvar.NewMap("auth")
+
+// BasicAuthenticator checks user names and passwords against the known users. Recently verified credentials are cached,
+// so bcrypt does not have to be used on every request, and IP addresses and user names from them are locked out after too many failed attempts.
+// The user is still looked up on every attempt, so changes to their password or role take effect immediately.
+type BasicAuthenticator struct {
+	users     ApiUserRepository
+	cache     *credentialCache
+	userLimit *failureLimiter
+	addrLimit *failureLimiter
+	now       func() time.Time
+}
+
+func NewBasicAuthenticator(users ApiUserRepository) (*BasicAuthenticator, error) {
+	cache, err := newCredentialCache(credentialCacheTTL, credentialCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BasicAuthenticator{
+		users:     users,
+		cache:     cache,
+		userLimit: newFailureLimiter(maxUserFailures, failureWindow, lockoutDuration, failureLimiterSize),
+		addrLimit: newFailureLimiter(maxAddressFailures, failureWindow, lockoutDuration, failureLimiterSize),
+		now:       time.Now,
+	}, nil
+}
+
+// Authenticate returns the user with the passed name if the password is correct. The address is the IP address
+// that the attempt came from, which is used to lock out addresses with too many failed attempts.
+func (b *BasicAuthenticator) Authenticate(address string, name string, password string) (ApiUser, error) {
+	now := b.now()
+
+	if b.addrLimit.lockedOut(address, now) || b.userLimit.lockedOut(userAddressKey(name, address), now) {
+		metrics.Add("lockedOutAttempts", 1)
+		return ApiUser{}, ErrLockedOut
+	}
+
+	apiUser, err := b.users.GetApiUserByName(name)
+	if err != nil {
+		// Only unknown users count as failed attempts, not e.g. the database being unavailable
+		if errors.Is(err, repository.ErrNotFound) {
+			b.fail(address, name, now)
+		}
+		return ApiUser{}, err
+	}
+
+	if b.cache.verified(apiUser, password, now) {
+		metrics.Add("cacheHits", 1)
+		return apiUser, nil
+	}
+
+	metrics.Add("cacheMisses", 1)
+	err = apiUser.CheckPassword(password)
+	if err != nil {
+		b.fail(address, name, now)
+		return ApiUser{}, err
+	}
+
+	b.cache.add(apiUser, password, now)
+	b.userLimit.reset(userAddressKey(name, address))
+	return apiUser, nil
+}
+
+// userAddressKey returns the key that failed attempts for the user name from the IP address are tracked by.
+// IP addresses never contain a space, so different combinations can not result in the same key.
+func userAddressKey(name string, address string) string {
+	return address + " " + name
+}
+
+// fail records a failed attempt for the passed address and user name.
+func (b *BasicAuthenticator) fail(address string, name string, now time.Time) {
+	metrics.Add("failedAttempts", 1)
+	b.failAddress(address, now)
+
+	if b.userLimit.fail(userAddressKey(name, address), now) {
+		metrics.Add("lockouts", 1)
+		log.Printf("locking out user %q from address %s for %s after too many failed authentication attempts", name, address, lockoutDuration)
+	}
+}
+
+// addressLockedOut returns whether the IP address is locked out because of too many failed attempts.
+func (b *BasicAuthenticator) addressLockedOut(address string, now time.Time) bool {
+	return b.addrLimit.lockedOut(address, now)
+}
+
+// failAddress records a failed attempt from the passed address only, e.g. for an invalid bearer token, which does not contain a user name.
+func (b *BasicAuthenticator) failAddress(address string, now time.Time) {
+	if b.addrLimit.fail(address, now) {
+		metrics.Add("lockouts", 1)
+		log.Printf("locking out address %s for %s after too many failed authentication attempts", address, lockoutDuration)
+	}
+}
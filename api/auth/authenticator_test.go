@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestAuthenticator returns a BasicAuthenticator for a single user with the passed password, and a pointer to the time it uses.
+func newTestAuthenticator(t *testing.T, password string) (*BasicAuthenticator, ApiUser, *time.Time) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf(`GenerateFromPassword() returned error: %v`, err)
+	}
+
+	user := NewApiUser(uuid.New(), "user", hash, RoleViewer)
+	b, err := NewBasicAuthenticator(memoryRepository{user.Id: user})
+	if err != nil {
+		t.Fatalf(`NewBasicAuthenticator() returned error: %v`, err)
+	}
+
+	now := time.Now()
+	b.now = func() time.Time { return now }
+	return b, user, &now
+}
+
+func TestBasicAuthenticatorCache(t *testing.T) {
+	b, user, _ := newTestAuthenticator(t, "secret")
+
+	if _, err := b.Authenticate("192.0.2.1", user.Name, "secret"); err != nil {
+		t.Fatalf(`Authenticate() returned error: %v`, err)
+	}
+
+	if !b.cache.verified(user, "secret", b.now()) {
+		t.Fatalf(`Expected the verified credentials to be cached`)
+	}
+
+	if b.cache.verified(user, "wrong", b.now()) {
+		t.Fatalf(`Expected a different password not to be verified by the cache`)
+	}
+
+	if _, err := b.Authenticate("192.0.2.1", user.Name, "wrong"); err == nil {
+		t.Fatalf(`Expected Authenticate() to return error for a wrong password of a cached user`)
+	}
+}
+
+func TestCredentialCacheExpiry(t *testing.T) {
+	c, _ := newCredentialCache(time.Minute, 2)
+	user := NewApiUser(uuid.New(), "user", []byte("hash"), RoleViewer)
+	now := time.Now()
+
+	c.add(user, "secret", now)
+	if c.verified(user, "secret", now.Add(time.Minute)) {
+		t.Fatalf(`Expected cached credentials to expire after the TTL`)
+	}
+
+	c.add(user, "secret", now)
+	user.Password = []byte("changed")
+	if c.verified(user, "secret", now) {
+		t.Fatalf(`Expected cached credentials to be invalid after the password has changed`)
+	}
+}
+
+func TestCredentialCacheSize(t *testing.T) {
+	c, _ := newCredentialCache(time.Minute, 2)
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		c.add(NewApiUser(uuid.New(), "user", []byte("hash"), RoleViewer), "secret", now.Add(time.Duration(i)*time.Second))
+	}
+
+	if len(c.entries) != 2 {
+		t.Fatalf(`Cache contains %d entries, expected: 2`, len(c.entries))
+	}
+}
+
+func TestBasicAuthenticatorLockout(t *testing.T) {
+	b, user, now := newTestAuthenticator(t, "secret")
+
+	for i := 0; i < maxUserFailures; i++ {
+		if _, err := b.Authenticate("192.0.2.1", user.Name, "wrong"); err == nil || errors.Is(err, ErrLockedOut) {
+			t.Fatalf(`Attempt %d returned %v, expected invalid credentials error`, i+1, err)
+		}
+	}
+
+	// The user is locked out from that address, even with the correct password
+	if _, err := b.Authenticate("192.0.2.1", user.Name, "secret"); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf(`Authenticate() returned %v, expected: %v`, err, ErrLockedOut)
+	}
+
+	// Someone failing on purpose can not lock the user out from other addresses
+	if _, err := b.Authenticate("192.0.2.2", user.Name, "secret"); err != nil {
+		t.Fatalf(`Authenticate() from another address returned error: %v`, err)
+	}
+
+	*now = now.Add(lockoutDuration)
+	if _, err := b.Authenticate("192.0.2.1", user.Name, "secret"); err != nil {
+		t.Fatalf(`Authenticate() after the lockout returned error: %v`, err)
+	}
+}
+
+func TestBasicAuthenticatorAddressLockout(t *testing.T) {
+	b, user, _ := newTestAuthenticator(t, "secret")
+
+	// Use a different unknown user name for every attempt, so only the address gets locked out
+	for i := 0; i < maxAddressFailures; i++ {
+		_, _ = b.Authenticate("192.0.2.1", uuid.NewString(), "wrong")
+	}
+
+	if _, err := b.Authenticate("192.0.2.1", user.Name, "secret"); !errors.Is(err, ErrLockedOut) {
+		t.Fatalf(`Authenticate() from locked out address returned %v, expected: %v`, err, ErrLockedOut)
+	}
+
+	if _, err := b.Authenticate("192.0.2.2", user.Name, "secret"); err != nil {
+		t.Fatalf(`Authenticate() from another address returned error: %v`, err)
+	}
+}
+
+func TestFailureLimiterWindow(t *testing.T) {
+	l := newFailureLimiter(2, time.Minute, time.Hour, 10)
+	now := time.Now()
+
+	l.fail("key", now)
+	if l.fail("key", now.Add(time.Minute)) || l.lockedOut("key", now.Add(time.Minute)) {
+		t.Fatalf(`Expected failures outside the window not to lead to a lockout`)
+	}
+
+	if !l.fail("key", now.Add(time.Minute+time.Second)) {
+		t.Fatalf(`Expected failures within the window to lead to a lockout`)
+	}
+}
+
+func TestFailureLimiterEvictsUnlocked(t *testing.T) {
+	l := newFailureLimiter(2, time.Minute, time.Hour, 2)
+	now := time.Now()
+
+	l.fail("locked", now)
+	l.fail("locked", now)
+	l.fail("other", now.Add(time.Second))
+
+	// The limiter is full, so tracking a new key has to remove one, which must not be the one that is locked out
+	l.fail("new", now.Add(2*time.Second))
+	if !l.lockedOut("locked", now.Add(2*time.Second)) {
+		t.Fatalf(`Expected the locked out key to be kept when evicting entries`)
+	}
+	if _, ok := l.entries["other"]; ok {
+		t.Fatalf(`Expected the key that is not locked out to be evicted`)
+	}
+}
+
+func TestApiAuthBearerTokenLockedOut(t *testing.T) {
+	b, user, _ := newTestAuthenticator(t, "secret")
+	tokens := memoryTokenRepository{}
+	valid, _ := NewApiToken(uuid.New(), user.Id, "valid", HashApiToken("gobble_valid"), time.Now(), time.Time{})
+	_ = tokens.SetApiToken(valid)
+
+	handler := ApiAuth(b, tokens, externalAuthenticator{token: "eyJ.external.jwt", user: user})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Guessing API tokens and external tokens both count towards the lockout of the address
+	for i := 0; i < maxAddressFailures; i++ {
+		token := "gobble_" + uuid.NewString()
+		if i%2 == 0 {
+			token = "eyJ." + uuid.NewString()
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/api/systems", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf(`Attempt %d returned status %d, expected: %d`, i+1, w.Code, http.StatusUnauthorized)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/systems", nil)
+	r.Header.Set("Authorization", "Bearer gobble_valid")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf(`Request from locked out address returned status %d, expected: %d`, w.Code, http.StatusTooManyRequests)
+	}
+}
+
+func TestApiAuthLockedOut(t *testing.T) {
+	b, user, _ := newTestAuthenticator(t, "secret")
+	handler := ApiAuth(b, memoryTokenRepository{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	expected := http.StatusUnauthorized
+	for i := 0; i <= maxUserFailures; i++ {
+		if i == maxUserFailures {
+			expected = http.StatusTooManyRequests
+		}
+
+		r := httptest.NewRequest(http.MethodGet, "/api/systems", nil)
+		r.SetBasicAuth(user.Name, "wrong")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != expected {
+			t.Fatalf(`Attempt %d returned status %d, expected: %d`, i+1, w.Code, expected)
+		}
+	}
+}
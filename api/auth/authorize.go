@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/evanebb/gobble/api/response"
+	"net/http"
+)
+
+// ApiAuthorize will check that the authenticated user has the role that is required for the request,
+// and return a JSON response if they do not. Requests that only read require the read role, all others require the write role.
+// It has to be used after ApiAuth, which adds the authenticated user to the request.
+func ApiAuthorize(read Role, write Role) func(next http.Handler) http.Handler {
+	return authorize(read, write, sendForbiddenResponse)
+}
+
+// BrowserAuthorize will check that the authenticated user has the role that is required for the request,
+// and show an error page if they do not. Requests that only read require the read role, all others require the write role.
+// It has to be used after BrowserSessionAuth, which adds the authenticated user to the request.
+func BrowserAuthorize(read Role, write Role) func(next http.Handler) http.Handler {
+	return authorize(read, write, sendForbiddenPage)
+}
+
+// authorize will check that the authenticated user has the role that is required for the request,
+// and execute the passed callback if they do not.
+func authorize(read Role, write Role, authorizationFailureCallback func(w http.ResponseWriter)) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			required := write
+			if isReadOnly(r) {
+				required = read
+			}
+
+			u, ok := UserFromContext(r.Context())
+			if !ok || !u.Role.Allows(required) {
+				authorizationFailureCallback(w)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isReadOnly returns whether the request only reads data, based on its method.
+func isReadOnly(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+}
+
+// sendForbiddenResponse will write a JSON response indicating that the user is not allowed to do this to the passed http.ResponseWriter variable.
+func sendForbiddenResponse(w http.ResponseWriter) {
+	err := response.Error(w, http.StatusForbidden, "you do not have the required role for this action")
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// sendForbiddenPage will tell the user that they are not allowed to do this with a 403 status code.
+func sendForbiddenPage(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = fmt.Fprint(w, "Forbidden: you do not have the required role for this action")
+}
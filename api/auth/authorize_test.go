@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApiAuthorize(t *testing.T) {
+	tests := []struct {
+		role     Role
+		method   string
+		expected int
+	}{
+		{RoleViewer, http.MethodGet, http.StatusOK},
+		{RoleViewer, http.MethodPost, http.StatusForbidden},
+		{RoleOperator, http.MethodDelete, http.StatusOK},
+		{RoleAdmin, http.MethodPut, http.StatusOK},
+	}
+
+	handler := ApiAuthorize(RoleViewer, RoleOperator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/api/systems", nil)
+		r = r.WithContext(WithUser(r.Context(), NewApiUser(uuid.New(), "user", nil, test.role)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != test.expected {
+			t.Fatalf(`%s request by %s returned status %d, expected: %d`, test.method, test.role, w.Code, test.expected)
+		}
+	}
+}
+
+func TestApiAuthorizeWithoutUser(t *testing.T) {
+	handler := ApiAuthorize(RoleViewer, RoleViewer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/systems", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf(`Request without user returned status %d, expected: %d`, w.Code, http.StatusForbidden)
+	}
+}
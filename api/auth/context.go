@@ -0,0 +1,32 @@
+package auth
+
+import "context"
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	sessionContextKey
+)
+
+// WithUser returns a copy of the context that contains the authenticated user.
+func WithUser(ctx context.Context, u ApiUser) context.Context {
+	return context.WithValue(ctx, userContextKey, u)
+}
+
+// UserFromContext returns the authenticated user in the context, and whether there is one.
+func UserFromContext(ctx context.Context) (ApiUser, bool) {
+	u, ok := ctx.Value(userContextKey).(ApiUser)
+	return u, ok
+}
+
+// WithSession returns a copy of the context that contains the session of the authenticated user.
+func WithSession(ctx context.Context, s Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey, s)
+}
+
+// SessionFromContext returns the session of the authenticated user in the context, and whether there is one.
+func SessionFromContext(ctx context.Context) (Session, bool) {
+	s, ok := ctx.Value(sessionContextKey).(Session)
+	return s, ok
+}
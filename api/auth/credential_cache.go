@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// credentialCache remembers for a short time which passwords were recently verified for which users, so the expensive
+// bcrypt comparison does not have to be done on every request. It holds at most size entries.
+// Passwords are never stored; only an HMAC of them, using a key that only lives in memory.
+type credentialCache struct {
+	mu      sync.Mutex
+	key     []byte
+	ttl     time.Duration
+	size    int
+	entries map[uuid.UUID]cachedCredentials
+}
+
+type cachedCredentials struct {
+	mac []byte
+	// storedHash is the bcrypt hash that the password was verified against, so changing the password invalidates the entry.
+	storedHash []byte
+	expiresAt  time.Time
+}
+
+func newCredentialCache(ttl time.Duration, size int) (*credentialCache, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &credentialCache{
+		key:     key,
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[uuid.UUID]cachedCredentials),
+	}, nil
+}
+
+// verified returns whether the password was recently verified for the passed user.
+func (c *credentialCache) verified(u ApiUser, password string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[u.Id]
+	if !ok {
+		return false
+	}
+
+	if !now.Before(e.expiresAt) || !bytes.Equal(e.storedHash, u.Password) {
+		delete(c.entries, u.Id)
+		return false
+	}
+
+	return hmac.Equal(e.mac, c.mac(password))
+}
+
+// add remembers that the password was verified for the passed user.
+func (c *credentialCache) add(u ApiUser, password string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[u.Id]; !ok && len(c.entries) >= c.size {
+		c.evict(now)
+	}
+
+	c.entries[u.Id] = cachedCredentials{
+		mac:        c.mac(password),
+		storedHash: u.Password,
+		expiresAt:  now.Add(c.ttl),
+	}
+}
+
+// evict removes all expired entries, or the entry that expires first if none have expired yet.
+func (c *credentialCache) evict(now time.Time) {
+	var first uuid.UUID
+	var firstExpiresAt time.Time
+
+	for id, e := range c.entries {
+		if !now.Before(e.expiresAt) {
+			delete(c.entries, id)
+			continue
+		}
+
+		if firstExpiresAt.IsZero() || e.expiresAt.Before(firstExpiresAt) {
+			first = id
+			firstExpiresAt = e.expiresAt
+		}
+	}
+
+	if len(c.entries) >= c.size {
+		delete(c.entries, first)
+	}
+}
+
+func (c *credentialCache) mac(password string) []byte {
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(password))
+	return h.Sum(nil)
+}
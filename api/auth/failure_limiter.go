@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// failureLimiter counts failed authentication attempts per key, e.g. an IP address or user name, and locks the key out
+// once too many attempts have failed within the window. It tracks at most size keys.
+type failureLimiter struct {
+	mu          sync.Mutex
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+	size        int
+	entries     map[string]*failures
+}
+
+type failures struct {
+	count       int
+	since       time.Time
+	lockedUntil time.Time
+}
+
+func newFailureLimiter(maxFailures int, window time.Duration, lockout time.Duration, size int) *failureLimiter {
+	return &failureLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+		size:        size,
+		entries:     make(map[string]*failures),
+	}
+}
+
+// lockedOut returns whether the key is currently locked out.
+func (l *failureLimiter) lockedOut(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.entries[key]
+	return ok && now.Before(f.lockedUntil)
+}
+
+// fail records a failed attempt for the key, and returns whether this attempt caused the key to be locked out.
+func (l *failureLimiter) fail(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.entries[key]
+	if !ok || l.isStale(f, now) {
+		if !ok && len(l.entries) >= l.size {
+			l.evict(now)
+		}
+
+		f = &failures{since: now}
+		l.entries[key] = f
+	}
+
+	f.count++
+	if f.count < l.maxFailures {
+		return false
+	}
+
+	// Start counting again once the lockout is over
+	f.count = 0
+	f.since = now
+	f.lockedUntil = now.Add(l.lockout)
+	return true
+}
+
+// reset forgets the failed attempts for the key, e.g. after a successful attempt.
+func (l *failureLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// isStale returns whether the failures are outside the window and not locked out, so they can be forgotten.
+func (l *failureLimiter) isStale(f *failures, now time.Time) bool {
+	return !now.Before(f.since.Add(l.window)) && !now.Before(f.lockedUntil)
+}
+
+// evict removes all stale entries, or otherwise the oldest entry that is not locked out. A locked out entry is only removed
+// if all entries are locked out, so a lockout can not be lifted by failing with many other keys, e.g. from other addresses.
+// In that case, the lockout that ends first is removed.
+func (l *failureLimiter) evict(now time.Time) {
+	var oldest, firstToUnlock string
+	var oldestSince, firstLockedUntil time.Time
+
+	for key, f := range l.entries {
+		if l.isStale(f, now) {
+			delete(l.entries, key)
+			continue
+		}
+
+		if now.Before(f.lockedUntil) {
+			if firstLockedUntil.IsZero() || f.lockedUntil.Before(firstLockedUntil) {
+				firstToUnlock = key
+				firstLockedUntil = f.lockedUntil
+			}
+			continue
+		}
+
+		if oldestSince.IsZero() || f.since.Before(oldestSince) {
+			oldest = key
+			oldestSince = f.since
+		}
+	}
+
+	if len(l.entries) < l.size {
+		return
+	}
+
+	if !oldestSince.IsZero() {
+		delete(l.entries, oldest)
+		return
+	}
+
+	delete(l.entries, firstToUnlock)
+}
@@ -0,0 +1,369 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// clockSkew is how much the clocks of gobble and the provider may differ when checking the validity of a token.
+	clockSkew = time.Minute
+	// keysRefreshInterval is how often the signing keys may be fetched again, when a token is signed by an unknown key.
+	keysRefreshInterval = time.Minute
+)
+
+var (
+	errInvalidToken  = errors.New("token is not a valid JWT")
+	errUnknownKey    = errors.New("token is signed by an unknown key")
+	errInvalidSig    = errors.New("token has an invalid signature")
+	errExpiredJwt    = errors.New("token has expired")
+	errWrongIssuer   = errors.New("token was issued by another issuer")
+	errWrongAudience = errors.New("token was issued for another audience")
+)
+
+// claims are the claims in the payload of a JWT.
+type claims map[string]any
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwk is a JSON Web Key, of which only the fields of RSA and EC public keys are used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// algorithms are the supported signing algorithms, with the hash that they use.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// algorithmCurves are the curves that the ECDSA algorithms are defined for, since e.g. ES256 may only be used with P-256.
+var algorithmCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+// minRsaKeyBits is the minimum size of the RSA signing keys of the provider, smaller keys are not used.
+const minRsaKeyBits = 2048
+
+// verify checks the signature of the JWT, and that it was issued by the provider for the passed audience and is valid right now.
+// It returns the claims of the token.
+func (p *Provider) verify(ctx context.Context, token string, audience string) (claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errInvalidToken
+	}
+
+	var h jwtHeader
+	err := decodeSegment(parts[0], &h)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	hash, ok := algorithms[h.Alg]
+	if !ok {
+		return nil, fmt.Errorf("token is signed with unsupported algorithm %q", h.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	key, err := p.key(ctx, h.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	err = verifySignature(key, h.Alg, hash, hasher.Sum(nil), sig)
+	if err != nil {
+		return nil, err
+	}
+
+	var c claims
+	err = decodeSegment(parts[1], &c)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+
+	return c, p.validate(c, audience)
+}
+
+// validate checks the issuer, audience and validity period of the claims.
+func (p *Provider) validate(c claims, audience string) error {
+	if iss, _ := c["iss"].(string); iss != p.discovery.Issuer {
+		return errWrongIssuer
+	}
+
+	if !c.hasAudience(audience) {
+		return errWrongAudience
+	}
+
+	now := p.now()
+	exp, ok, err := c.time("exp")
+	if err != nil {
+		return err
+	}
+	if !ok || !now.Before(exp.Add(clockSkew)) {
+		return errExpiredJwt
+	}
+
+	nbf, ok, err := c.time("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(clockSkew).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+
+	return nil
+}
+
+// hasAudience returns whether the audience claim, which is either a string or a list of strings, contains the passed audience.
+func (c claims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []any:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// time returns the time in the passed claim, which is a number of seconds since the Unix epoch, and whether there is one.
+// A claim that is not a number is an error, instead of being treated as missing.
+func (c claims) time(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	seconds, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("token has a non-numeric %s claim", name)
+	}
+
+	return time.Unix(int64(seconds), 0), true, nil
+}
+
+// key returns the signing key with the passed ID. If it is unknown, the keys are fetched again, in case the provider has rotated them.
+// Tokens without a key ID are only accepted if the provider has a single signing key, so there is no doubt about which key to use.
+func (p *Provider) key(ctx context.Context, kid string) (any, error) {
+	p.keysMu.Lock()
+	key, ok := p.lookupKey(kid)
+	refresh := !ok && p.now().Sub(p.keysFetchedAt) >= keysRefreshInterval
+	p.keysMu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	if !refresh {
+		return nil, errUnknownKey
+	}
+
+	err := p.refreshKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	key, ok = p.lookupKey(kid)
+	if !ok {
+		return nil, errUnknownKey
+	}
+
+	return key, nil
+}
+
+// lookupKey returns the known signing key with the passed ID, or the only signing key if the ID is empty.
+// The caller has to hold keysMu.
+func (p *Provider) lookupKey(kid string) (any, bool) {
+	if kid == "" {
+		return p.onlyKey, p.onlyKey != nil
+	}
+
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// refreshKeys fetches the signing keys of the provider. Keys without an ID can not be selected by tokens with a key ID,
+// and are only used for tokens without a key ID if they are the single signing key of the provider.
+func (p *Provider) refreshKeys(ctx context.Context) error {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	err := p.getJson(ctx, p.discovery.JwksUri, &set)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]any)
+	var usable []any
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			// Skip keys that cannot be used, e.g. of an unsupported type, instead of failing altogether
+			continue
+		}
+
+		usable = append(usable, key)
+		if k.Kid != "" {
+			keys[k.Kid] = key
+		}
+	}
+
+	var onlyKey any
+	if len(usable) == 1 {
+		onlyKey = usable[0]
+	}
+
+	p.keysMu.Lock()
+	defer p.keysMu.Unlock()
+
+	p.keys = keys
+	p.onlyKey = onlyKey
+	p.keysFetchedAt = p.now()
+	return nil
+}
+
+// publicKey returns the RSA or ECDSA public key in the JWK.
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+
+		if n.BitLen() < minRsaKeyBits {
+			return nil, fmt.Errorf("RSA key is smaller than %d bits", minRsaKeyBits)
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on the curve")
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifySignature checks the signature of the hashed signing input with the passed key, which has to match the algorithm.
+func verifySignature(key any, alg string, hash crypto.Hash, hashed []byte, sig []byte) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") || rsa.VerifyPKCS1v15(key, hash, hashed, sig) != nil {
+			return errInvalidSig
+		}
+	case *ecdsa.PublicKey:
+		// ECDSA signatures in JWTs are the R and S values, each padded to the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if algorithmCurves[alg] != key.Curve.Params().Name || len(sig) != 2*size {
+			return errInvalidSig
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, hashed, r, s) {
+			return errInvalidSig
+		}
+	default:
+		return errInvalidSig
+	}
+
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a JWT into v.
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func constantTimeEqual(a string, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,233 @@
+// Package oidc implements single sign-on through an OpenID Connect provider: the authorization code flow for the UI,
+// and the validation of JWTs issued by the provider for the API.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanebb/gobble/api/auth"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoRole = errors.New("the user does not have a role in gobble")
+
+type Config struct {
+	// IssuerUrl is the URL of the OpenID Connect provider, e.g. https://idp.example.local/realms/gobble.
+	IssuerUrl    string
+	ClientId     string
+	ClientSecret string
+	// Audience is the audience that JWTs sent to the API must be issued for, which defaults to the client ID.
+	Audience string
+	// UsernameClaim is the claim that contains the name of the user, which defaults to preferred_username.
+	UsernameClaim string
+	// GroupsClaim is the claim that contains the groups of the user, which defaults to groups.
+	GroupsClaim string
+	// RoleMapping maps the groups of the user to roles; the user gets the highest role of all their groups.
+	RoleMapping map[string]auth.Role
+	// DefaultRole is the role of users that are not in any mapped group. If it is empty, these users cannot log in.
+	DefaultRole auth.Role
+}
+
+// discovery is the part of the OpenID Connect discovery document that is used.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+// Provider is an OpenID Connect provider that users can log in with. It implements auth.ExternalAuthenticator,
+// so JWTs issued by the provider can be used as bearer tokens for the API.
+type Provider struct {
+	config    Config
+	discovery discovery
+	client    *http.Client
+	now       func() time.Time
+
+	// keys are the signing keys of the provider by key ID, which are fetched again when a token is signed by an unknown key.
+	keysMu        sync.Mutex
+	keys          map[string]any
+	keysFetchedAt time.Time
+	// onlyKey is the signing key if the provider has exactly one, which is the only key that tokens without a key ID are checked against.
+	onlyKey any
+}
+
+// NewProvider fetches the discovery document and the signing keys of the provider.
+func NewProvider(ctx context.Context, config Config) (*Provider, error) {
+	if config.IssuerUrl == "" || config.ClientId == "" {
+		return nil, errors.New("the issuer URL and client ID are required for OpenID Connect")
+	}
+
+	if config.Audience == "" {
+		config.Audience = config.ClientId
+	}
+
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+
+	p := &Provider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		now:    time.Now,
+	}
+
+	discoveryUrl := strings.TrimSuffix(config.IssuerUrl, "/") + "/.well-known/openid-configuration"
+	err := p.getJson(ctx, discoveryUrl, &p.discovery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OpenID Connect discovery document: %w", err)
+	}
+
+	// The issuer has to match exactly, since it is compared to the issuer of every token
+	if p.discovery.Issuer != config.IssuerUrl {
+		return nil, fmt.Errorf("issuer %q in discovery document does not match configured issuer %q", p.discovery.Issuer, config.IssuerUrl)
+	}
+
+	if p.discovery.AuthorizationEndpoint == "" || p.discovery.TokenEndpoint == "" || p.discovery.JwksUri == "" {
+		return nil, errors.New("discovery document is missing an endpoint")
+	}
+
+	err = p.refreshKeys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signing keys: %w", err)
+	}
+
+	return p, nil
+}
+
+// LoginRequest contains the values that have to be remembered between redirecting the user to the provider and the callback.
+type LoginRequest struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// NewLoginRequest generates the random values for a new login.
+func NewLoginRequest() (LoginRequest, error) {
+	var l LoginRequest
+	var err error
+
+	for _, v := range []*string{&l.State, &l.Nonce, &l.Verifier} {
+		*v, err = auth.RandomHex(32)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	return l, nil
+}
+
+// AuthCodeUrl returns the URL of the provider to redirect the user to for logging in, which will redirect back to the redirect URL.
+func (p *Provider) AuthCodeUrl(l LoginRequest, redirectUrl string) string {
+	challenge := sha256.Sum256([]byte(l.Verifier))
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.config.ClientId)
+	v.Set("redirect_uri", redirectUrl)
+	v.Set("scope", "openid profile email")
+	v.Set("state", l.State)
+	v.Set("nonce", l.Nonce)
+	v.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	v.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return p.discovery.AuthorizationEndpoint + separator + v.Encode()
+}
+
+// Exchange exchanges the authorization code from the callback for an ID token, and returns the user that it belongs to.
+func (p *Provider) Exchange(ctx context.Context, l LoginRequest, code string, redirectUrl string) (auth.ApiUser, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", redirectUrl)
+	v.Set("code_verifier", l.Verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(v.Encode()))
+	if err != nil {
+		return auth.ApiUser{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientId), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return auth.ApiUser{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return auth.ApiUser{}, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		IdToken string `json:"id_token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return auth.ApiUser{}, err
+	}
+
+	if body.IdToken == "" {
+		return auth.ApiUser{}, errors.New("token endpoint did not return an ID token")
+	}
+
+	c, err := p.verify(ctx, body.IdToken, p.config.ClientId)
+	if err != nil {
+		return auth.ApiUser{}, err
+	}
+
+	if nonce, _ := c["nonce"].(string); !constantTimeEqual(nonce, l.Nonce) {
+		return auth.ApiUser{}, errors.New("ID token has an invalid nonce")
+	}
+
+	return p.user(c)
+}
+
+// AuthenticateToken returns the user that the passed JWT belongs to, if it was issued by the provider for the configured audience.
+func (p *Provider) AuthenticateToken(ctx context.Context, token string) (auth.ApiUser, error) {
+	c, err := p.verify(ctx, token, p.config.Audience)
+	if err != nil {
+		return auth.ApiUser{}, err
+	}
+
+	return p.user(c)
+}
+
+// getJson gets the passed URL and decodes the JSON response into v.
+func (p *Provider) getJson(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,404 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIssuer is a local stand-in for an OpenID Connect provider, which serves the discovery document, the signing keys
+// and a token endpoint, and can sign tokens.
+type testIssuer struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	kid    string
+	// singleKey makes the provider serve only its RSA key, without a key ID.
+	singleKey bool
+	// codes maps the authorization codes that the token endpoint accepts to the code challenge and nonce of the login.
+	codes map[string][2]string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf(`GenerateKey() returned error: %v`, err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf(`GenerateKey() returned error: %v`, err)
+	}
+
+	i := &testIssuer{rsaKey: rsaKey, ecKey: ecKey, kid: "rsa-1", codes: make(map[string][2]string)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 i.server.URL,
+			"authorization_endpoint": i.server.URL + "/authorize",
+			"token_endpoint":         i.server.URL + "/token",
+			"jwks_uri":               i.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		if i.singleKey {
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "RSA", "use": "sig", "n": encode(i.rsaKey.N.Bytes()), "e": encode([]byte{1, 0, 1})},
+			}})
+			return
+		}
+
+		size := (i.ecKey.Curve.Params().BitSize + 7) / 8
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": i.kid, "use": "sig", "n": encode(i.rsaKey.N.Bytes()), "e": encode([]byte{1, 0, 1})},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(i.ecKey.X.FillBytes(make([]byte, size))), "y": encode(i.ecKey.Y.FillBytes(make([]byte, size)))},
+			{"kty": "oct", "kid": "hmac-1", "k": encode([]byte("secret"))},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		login, ok := i.codes[r.PostFormValue("code")]
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if id != "gobble" || secret != "secret" || !ok || encode(verifier[:]) != login[0] {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims := i.claims("alice", []string{"gobble-operators"})
+		claims["nonce"] = login[1]
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": i.sign(t, "RS256", claims)})
+	})
+
+	i.server = httptest.NewServer(mux)
+	t.Cleanup(i.server.Close)
+	return i
+}
+
+// claims returns valid claims for the passed user and groups.
+func (i *testIssuer) claims(name string, groups []string) map[string]any {
+	return map[string]any{
+		"iss":                i.server.URL,
+		"sub":                "sub-" + name,
+		"aud":                "gobble",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"preferred_username": name,
+		"groups":             groups,
+	}
+}
+
+// sign returns a JWT with the passed claims, signed with the passed algorithm and the matching key.
+func (i *testIssuer) sign(t *testing.T, alg string, claims map[string]any) string {
+	kid := i.kid
+	if alg == "ES256" {
+		kid = "ec-1"
+	}
+
+	return i.signWithHeader(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}, claims)
+}
+
+// signWithHeader returns a JWT with the passed header and claims, signed with the RSA or EC key for the algorithm in the header,
+// regardless of the key ID in it.
+func (i *testIssuer) signWithHeader(t *testing.T, h map[string]string, claims map[string]any) string {
+	header, _ := json.Marshal(h)
+	payload, _ := json.Marshal(claims)
+	input := encode(header) + "." + encode(payload)
+	hashed := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	var err error
+	switch h["alg"] {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, hashed[:])
+	case "ES256":
+		r, s, signErr := ecdsa.Sign(rand.Reader, i.ecKey, hashed[:])
+		sig, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), signErr
+	}
+	if err != nil {
+		t.Fatalf(`Signing token returned error: %v`, err)
+	}
+
+	return input + "." + encode(sig)
+}
+
+func (i *testIssuer) provider(t *testing.T) *Provider {
+	p, err := NewProvider(context.Background(), Config{
+		IssuerUrl:    i.server.URL,
+		ClientId:     "gobble",
+		ClientSecret: "secret",
+		RoleMapping:  map[string]auth.Role{"gobble-admins": auth.RoleAdmin, "gobble-operators": auth.RoleOperator},
+	})
+	if err != nil {
+		t.Fatalf(`NewProvider() returned error: %v`, err)
+	}
+
+	return p
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestNewProviderIssuerMismatch(t *testing.T) {
+	i := newTestIssuer(t)
+
+	_, err := NewProvider(context.Background(), Config{IssuerUrl: i.server.URL + "/", ClientId: "gobble"})
+	if err == nil {
+		t.Fatalf(`Expected NewProvider() to return error for an issuer that does not match the discovery document`)
+	}
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	i := newTestIssuer(t)
+	p := i.provider(t)
+
+	for _, alg := range []string{"RS256", "ES256"} {
+		u, err := p.AuthenticateToken(context.Background(), i.sign(t, alg, i.claims("alice", []string{"other", "gobble-admins", "gobble-operators"})))
+		if err != nil {
+			t.Fatalf(`AuthenticateToken() with %s token returned error: %v`, alg, err)
+		}
+
+		if u.Name != "alice" || u.Role != auth.RoleAdmin || u.Password != nil {
+			t.Fatalf(`AuthenticateToken() returned unexpected user: %v`, u)
+		}
+	}
+}
+
+func TestAuthenticateTokenInvalid(t *testing.T) {
+	i := newTestIssuer(t)
+	p := i.provider(t)
+
+	claims := func(key string, value any) map[string]any {
+		c := i.claims("alice", []string{"gobble-admins"})
+		c[key] = value
+		return c
+	}
+
+	valid := i.sign(t, "RS256", i.claims("alice", []string{"gobble-admins"}))
+	parts := strings.Split(valid, ".")
+	unsigned, _ := json.Marshal(map[string]string{"alg": "none"})
+	tampered, _ := json.Marshal(claims("preferred_username", "mallory"))
+
+	tests := map[string]string{
+		"expired":         i.sign(t, "RS256", claims("exp", time.Now().Add(-time.Hour).Unix())),
+		"not yet valid":   i.sign(t, "RS256", claims("nbf", time.Now().Add(time.Hour).Unix())),
+		"wrong audience":  i.sign(t, "RS256", claims("aud", []string{"other"})),
+		"wrong issuer":    i.sign(t, "RS256", claims("iss", "https://evil.example.local")),
+		"no role":         i.sign(t, "RS256", claims("groups", []string{"other"})),
+		"alg none":        encode(unsigned) + "." + parts[1] + ".",
+		"tampered":        parts[0] + "." + encode(tampered) + "." + parts[2],
+		"garbage":         "not-a-jwt",
+		"wrong algorithm": strings.Replace(valid, parts[0], encode([]byte(`{"alg":"ES256","kid":"rsa-1"}`)), 1),
+		// Validly signed, but by a key of another type than the one with the key ID
+		"ES256 for RSA key":  i.signWithHeader(t, map[string]string{"alg": "ES256", "kid": "rsa-1"}, claims("sub", "sub-alice")),
+		"RS256 for EC key":   i.signWithHeader(t, map[string]string{"alg": "RS256", "kid": "ec-1"}, claims("sub", "sub-alice")),
+		"HS256 for HMAC key": strings.Replace(valid, parts[0], encode([]byte(`{"alg":"HS256","kid":"hmac-1"}`)), 1),
+		"non-numeric exp":    i.sign(t, "RS256", claims("exp", "tomorrow")),
+		"non-numeric nbf":    i.sign(t, "RS256", claims("nbf", "yesterday")),
+		"missing exp":        i.sign(t, "RS256", claims("exp", nil)),
+		"other audiences":    i.sign(t, "RS256", claims("aud", []string{"other", "another"})),
+		"empty audiences":    i.sign(t, "RS256", claims("aud", []string{})),
+		"missing kid":        i.signWithHeader(t, map[string]string{"alg": "RS256"}, claims("sub", "sub-alice")),
+		"unknown kid":        i.signWithHeader(t, map[string]string{"alg": "RS256", "kid": "rsa-9"}, claims("sub", "sub-alice")),
+	}
+
+	for name, token := range tests {
+		if u, err := p.AuthenticateToken(context.Background(), token); err == nil {
+			t.Fatalf(`Expected AuthenticateToken() to return error for %s token, got: %v`, name, u)
+		}
+	}
+}
+
+func TestAuthenticateTokenAudiences(t *testing.T) {
+	i := newTestIssuer(t)
+	p := i.provider(t)
+
+	// Tokens can be issued for several audiences, as long as gobble is one of them
+	c := i.claims("alice", []string{"gobble-admins"})
+	c["aud"] = []string{"other", "gobble", "another"}
+	if _, err := p.AuthenticateToken(context.Background(), i.sign(t, "RS256", c)); err != nil {
+		t.Fatalf(`AuthenticateToken() with several audiences returned error: %v`, err)
+	}
+
+	// The audience has to match exactly, not e.g. be a number or a prefix
+	for _, aud := range []any{[]any{1, "gobble-other"}, "gobble-other", map[string]string{"gobble": "gobble"}} {
+		c["aud"] = aud
+		if _, err := p.AuthenticateToken(context.Background(), i.sign(t, "RS256", c)); !errors.Is(err, errWrongAudience) {
+			t.Fatalf(`AuthenticateToken() with audience %v returned %v, expected: %v`, aud, err, errWrongAudience)
+		}
+	}
+}
+
+func TestAuthenticateTokenMissingKid(t *testing.T) {
+	i := newTestIssuer(t)
+	claims := i.claims("alice", []string{"gobble-admins"})
+	noKid := i.signWithHeader(t, map[string]string{"alg": "RS256"}, claims)
+
+	// The provider has several keys, so it is ambiguous which one a token without a key ID should be checked against
+	if _, err := i.provider(t).AuthenticateToken(context.Background(), noKid); !errors.Is(err, errUnknownKey) {
+		t.Fatalf(`AuthenticateToken() without key ID for a provider with several keys returned %v, expected: %v`, err, errUnknownKey)
+	}
+
+	// With a single key without a key ID, tokens without a key ID are checked against that key
+	i.singleKey = true
+	p := i.provider(t)
+	if _, err := p.AuthenticateToken(context.Background(), noKid); err != nil {
+		t.Fatalf(`AuthenticateToken() without key ID for a provider with a single key returned error: %v`, err)
+	}
+
+	// Key IDs still have to match, the single key is not a fallback for unknown ones
+	if _, err := p.AuthenticateToken(context.Background(), i.sign(t, "RS256", claims)); !errors.Is(err, errUnknownKey) {
+		t.Fatalf(`AuthenticateToken() with an unknown key ID returned %v, expected: %v`, err, errUnknownKey)
+	}
+
+	// An empty key ID is the same as a missing one, so the token is only checked against the single key and not e.g. the EC key
+	tampered := i.signWithHeader(t, map[string]string{"alg": "ES256", "kid": ""}, claims)
+	if _, err := p.AuthenticateToken(context.Background(), tampered); !errors.Is(err, errInvalidSig) {
+		t.Fatalf(`AuthenticateToken() signed by another key without key ID returned %v, expected: %v`, err, errInvalidSig)
+	}
+}
+
+func TestVerifySignatureCurve(t *testing.T) {
+	i := newTestIssuer(t)
+	hashed := sha512.Sum384([]byte("input"))
+	r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, hashed[:])
+	if err != nil {
+		t.Fatalf(`Sign() returned error: %v`, err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	// The signature itself is valid, but ES384 is only defined for P-384, not for the P-256 key that made it
+	if err := verifySignature(&i.ecKey.PublicKey, "ES384", crypto.SHA384, hashed[:], sig); !errors.Is(err, errInvalidSig) {
+		t.Fatalf(`verifySignature() with ES384 for a P-256 key returned %v, expected: %v`, err, errInvalidSig)
+	}
+}
+
+func TestJwkPublicKeyRsaSize(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf(`GenerateKey() returned error: %v`, err)
+	}
+
+	k := jwk{Kty: "RSA", N: encode(small.N.Bytes()), E: encode([]byte{1, 0, 1})}
+	if _, err := k.publicKey(); err == nil {
+		t.Fatalf(`Expected jwk.publicKey() to return error for a %d bit RSA key`, small.N.BitLen())
+	}
+
+	k.N = encode(newTestIssuer(t).rsaKey.N.Bytes())
+	if _, err := k.publicKey(); err != nil {
+		t.Fatalf(`jwk.publicKey() for a 2048 bit RSA key returned error: %v`, err)
+	}
+}
+
+func TestAuthenticateTokenKeyRotation(t *testing.T) {
+	i := newTestIssuer(t)
+	p := i.provider(t)
+
+	// The provider rotates its key, which is only fetched again after the refresh interval
+	i.rsaKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	i.kid = "rsa-2"
+	token := i.sign(t, "RS256", i.claims("alice", []string{"gobble-admins"}))
+
+	if _, err := p.AuthenticateToken(context.Background(), token); !errors.Is(err, errUnknownKey) {
+		t.Fatalf(`AuthenticateToken() with a new key right after fetching the keys returned %v, expected: %v`, err, errUnknownKey)
+	}
+
+	p.now = func() time.Time { return time.Now().Add(keysRefreshInterval) }
+	if _, err := p.AuthenticateToken(context.Background(), token); err != nil {
+		t.Fatalf(`AuthenticateToken() with a new key after the refresh interval returned error: %v`, err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	i := newTestIssuer(t)
+	p := i.provider(t)
+	redirectUrl := "https://gobble.example.local/ui/login/oidc/callback"
+
+	l, err := NewLoginRequest()
+	if err != nil {
+		t.Fatalf(`NewLoginRequest() returned error: %v`, err)
+	}
+
+	// The user logs in at the provider, which remembers the code challenge and nonce for the code it hands out
+	authUrl, err := url.Parse(p.AuthCodeUrl(l, redirectUrl))
+	if err != nil {
+		t.Fatalf(`AuthCodeUrl() returned invalid URL: %v`, err)
+	}
+
+	q := authUrl.Query()
+	if q.Get("client_id") != "gobble" || q.Get("redirect_uri") != redirectUrl || q.Get("state") != l.State || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf(`AuthCodeUrl() returned unexpected URL: %s`, authUrl)
+	}
+	i.codes["code"] = [2]string{q.Get("code_challenge"), q.Get("nonce")}
+
+	u, err := p.Exchange(context.Background(), l, "code", redirectUrl)
+	if err != nil {
+		t.Fatalf(`Exchange() returned error: %v`, err)
+	}
+
+	if u.Name != "alice" || u.Role != auth.RoleOperator {
+		t.Fatalf(`Exchange() returned unexpected user: %v`, u)
+	}
+
+	if _, err := p.Exchange(context.Background(), l, "unknown", redirectUrl); err == nil {
+		t.Fatalf(`Expected Exchange() to return error for an unknown code`)
+	}
+
+	// A token that was issued for another login has a different nonce
+	other, _ := NewLoginRequest()
+	other.Verifier = l.Verifier
+	if _, err := p.Exchange(context.Background(), other, "code", redirectUrl); err == nil {
+		t.Fatalf(`Expected Exchange() to return error for a different nonce`)
+	}
+}
+
+func TestUserDefaultRole(t *testing.T) {
+	i := newTestIssuer(t)
+	p := i.provider(t)
+
+	c := claims(i.claims("bob", nil))
+	if _, err := p.user(c); !errors.Is(err, ErrNoRole) {
+		t.Fatalf(`user() without groups returned %v, expected: %v`, err, ErrNoRole)
+	}
+
+	p.config.DefaultRole = auth.RoleViewer
+	u, err := p.user(c)
+	if err != nil || u.Role != auth.RoleViewer {
+		t.Fatalf(`user() with default role = %v, %v, expected role: %v`, u, err, auth.RoleViewer)
+	}
+
+	// The ID is stable between logins
+	again, _ := p.user(c)
+	if u.Id != again.Id {
+		t.Fatalf(`user() returned different IDs for the same subject: %s, %s`, u.Id, again.Id)
+	}
+}
+
+func TestParseRoleMapping(t *testing.T) {
+	actual, err := ParseRoleMapping("gobble-admins=admin, gobble-ops = operator")
+	if err != nil || len(actual) != 2 || actual["gobble-admins"] != auth.RoleAdmin || actual["gobble-ops"] != auth.RoleOperator {
+		t.Fatalf(`ParseRoleMapping() = %v, %v`, actual, err)
+	}
+
+	for _, s := range []string{"gobble-admins", "=admin", "gobble-admins=root"} {
+		if actual, err := ParseRoleMapping(s); err == nil {
+			t.Fatalf(`Expected ParseRoleMapping() to return error for '%s', got: %v`, s, actual)
+		}
+	}
+}
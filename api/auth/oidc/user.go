@@ -0,0 +1,81 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/google/uuid"
+	"strings"
+)
+
+// user returns the user that the claims belong to. Its ID is derived from the issuer and subject, so it is stable between logins,
+// and its role is the highest role of the mapped groups that the user is in.
+func (p *Provider) user(c claims) (auth.ApiUser, error) {
+	sub, _ := c["sub"].(string)
+	if sub == "" {
+		return auth.ApiUser{}, errors.New("token does not contain a subject")
+	}
+
+	name, _ := c[p.config.UsernameClaim].(string)
+	if name == "" {
+		name = sub
+	}
+
+	role := p.config.DefaultRole
+	for _, g := range c.strings(p.config.GroupsClaim) {
+		r, ok := p.config.RoleMapping[g]
+		if ok && (role == "" || r.Allows(role)) {
+			role = r
+		}
+	}
+
+	if role == "" {
+		return auth.ApiUser{}, ErrNoRole
+	}
+
+	id := uuid.NewSHA1(uuid.NameSpaceURL, []byte(p.discovery.Issuer+"#"+sub))
+	return auth.NewApiUser(id, name, nil, role), nil
+}
+
+// strings returns the values of the passed claim, which is either a string or a list of strings.
+func (c claims) strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var values []string
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// ParseRoleMapping parses a comma-separated list of group=role pairs, e.g. 'gobble-admins=admin,gobble-operators=operator'.
+func ParseRoleMapping(s string) (map[string]auth.Role, error) {
+	mapping := make(map[string]auth.Role)
+	if strings.TrimSpace(s) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		group, role, ok := strings.Cut(pair, "=")
+		group = strings.TrimSpace(group)
+		if !ok || group == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, expected group=role", pair)
+		}
+
+		r, err := auth.ParseRole(strings.TrimSpace(role))
+		if err != nil {
+			return nil, err
+		}
+
+		mapping[group] = r
+	}
+
+	return mapping, nil
+}
@@ -1,6 +1,9 @@
 package auth
 
-import "github.com/google/uuid"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
 type ApiUserRepository interface {
 	GetApiUsers() ([]ApiUser, error)
@@ -9,3 +12,19 @@ type ApiUserRepository interface {
 	SetApiUser(a ApiUser) error
 	DeleteApiUserById(id uuid.UUID) error
 }
+
+type ApiTokenRepository interface {
+	GetApiTokensByUserId(userId uuid.UUID) ([]ApiToken, error)
+	GetApiTokenById(id uuid.UUID) (ApiToken, error)
+	GetApiTokenByHash(hash string) (ApiToken, error)
+	SetApiToken(t ApiToken) error
+	SetApiTokenLastUsed(id uuid.UUID, lastUsedAt time.Time) error
+	DeleteApiTokenById(id uuid.UUID) error
+}
+
+type SessionRepository interface {
+	GetSessionByHash(hash string) (Session, error)
+	SetSession(s Session) error
+	DeleteSessionById(id uuid.UUID) error
+	DeleteSessionsExpiredBefore(t time.Time) (int64, error)
+}
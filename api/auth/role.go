@@ -0,0 +1,44 @@
+package auth
+
+import "errors"
+
+// Role determines what an ApiUser is allowed to do. Every role is allowed to do everything that the roles below it can do.
+type Role string
+
+const (
+	// RoleViewer can view everything, but cannot change anything.
+	RoleViewer Role = "viewer"
+	// RoleOperator can also manage systems, including discovered systems and their next boot.
+	RoleOperator Role = "operator"
+	// RoleAdmin can also manage profiles, config templates, users and the settings.
+	RoleAdmin Role = "admin"
+)
+
+// Roles contains every valid Role, from the least to the most privileged.
+var Roles = []Role{RoleViewer, RoleOperator, RoleAdmin}
+
+// ParseRole parses s into a Role, and returns an error if it is not a valid role.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if r.level() < 0 {
+		return r, errors.New("invalid role " + s)
+	}
+
+	return r, nil
+}
+
+// Allows returns whether the role is allowed to do what the passed role is allowed to do.
+func (r Role) Allows(required Role) bool {
+	return r.level() >= 0 && r.level() >= required.level()
+}
+
+// level returns the position of the role in Roles, or -1 if it is not a valid role.
+func (r Role) level() int {
+	for i, v := range Roles {
+		if r == v {
+			return i
+		}
+	}
+
+	return -1
+}
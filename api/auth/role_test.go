@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"testing"
+)
+
+// memoryRepository is a simple in-memory ApiUserRepository for testing.
+type memoryRepository map[uuid.UUID]ApiUser
+
+func (r memoryRepository) GetApiUsers() ([]ApiUser, error) {
+	var users []ApiUser
+	for _, u := range r {
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (r memoryRepository) GetApiUserById(id uuid.UUID) (ApiUser, error) {
+	u, ok := r[id]
+	if !ok {
+		return u, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+func (r memoryRepository) GetApiUserByName(name string) (ApiUser, error) {
+	for _, u := range r {
+		if u.Name == name {
+			return u, nil
+		}
+	}
+	return ApiUser{}, repository.ErrNotFound
+}
+
+func (r memoryRepository) SetApiUser(a ApiUser) error {
+	r[a.Id] = a
+	return nil
+}
+
+func (r memoryRepository) DeleteApiUserById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func TestParseRole(t *testing.T) {
+	actual, err := ParseRole("operator")
+	if err != nil || actual != RoleOperator {
+		t.Fatalf(`ParseRole() = %v, %v, expected: %v, nil`, actual, err, RoleOperator)
+	}
+}
+
+func TestParseRoleInvalid(t *testing.T) {
+	for _, s := range []string{"", "root", "Admin"} {
+		actual, err := ParseRole(s)
+		if err == nil {
+			t.Fatalf(`Expected ParseRole() to return invalid role error for '%s', got: %v, %v`, s, actual, err)
+		}
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		expected bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleViewer, true},
+		{RoleOperator, RoleOperator, true},
+		{RoleOperator, RoleAdmin, false},
+		{RoleViewer, RoleViewer, true},
+		{RoleViewer, RoleOperator, false},
+		{Role(""), RoleViewer, false},
+	}
+
+	for _, test := range tests {
+		if actual := test.role.Allows(test.required); actual != test.expected {
+			t.Fatalf(`Role(%s).Allows(%s) = %v, expected: %v`, test.role, test.required, actual, test.expected)
+		}
+	}
+}
+
+func TestIsLastAdmin(t *testing.T) {
+	admin := NewApiUser(uuid.New(), "admin", nil, RoleAdmin)
+	viewer := NewApiUser(uuid.New(), "viewer", nil, RoleViewer)
+	r := memoryRepository{admin.Id: admin, viewer.Id: viewer}
+
+	if actual, err := IsLastAdmin(r, admin.Id); err != nil || !actual {
+		t.Fatalf(`IsLastAdmin() = %v, %v, expected: true, nil`, actual, err)
+	}
+
+	if actual, err := IsLastAdmin(r, viewer.Id); err != nil || actual {
+		t.Fatalf(`IsLastAdmin() = %v, %v, expected: false, nil`, actual, err)
+	}
+
+	other := NewApiUser(uuid.New(), "other", nil, RoleAdmin)
+	r[other.Id] = other
+	if actual, err := IsLastAdmin(r, admin.Id); err != nil || actual {
+		t.Fatalf(`IsLastAdmin() = %v, %v, expected: false, nil`, actual, err)
+	}
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+const (
+	// SessionCookieName is the name of the cookie that contains the session token of the UI.
+	SessionCookieName = "gobble_session"
+	// SessionLifetime is how long a UI session lasts before the user has to log in again.
+	SessionLifetime = 12 * time.Hour
+	// CsrfFieldName is the name of the form field that has to contain the CSRF token of the session.
+	CsrfFieldName = "csrf_token"
+)
+
+// Session is a logged-in UI session of an ApiUser. Only the hash of the session token in the cookie is stored.
+type Session struct {
+	Id     uuid.UUID
+	UserId uuid.UUID
+	Hash   string
+	// CsrfToken has to be sent with every form that changes something, so other sites cannot submit forms on behalf of the user.
+	CsrfToken string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	// External is whether the session belongs to a user of an external identity provider, such as OpenID Connect,
+	// who is not stored in gobble. Their name and role are stored in the session instead.
+	External bool
+	UserName string
+	Role     Role
+}
+
+func NewSession(id uuid.UUID, userId uuid.UUID, hash string, csrfToken string, createdAt time.Time, expiresAt time.Time) Session {
+	return Session{
+		Id:        id,
+		UserId:    userId,
+		Hash:      hash,
+		CsrfToken: csrfToken,
+		CreatedAt: createdAt,
+		ExpiresAt: expiresAt,
+	}
+}
+
+// StartSession creates a new session for the passed user, and returns it together with the session token for the cookie.
+func StartSession(sessions SessionRepository, u ApiUser, now time.Time) (Session, string, error) {
+	return startSession(sessions, u, false, now)
+}
+
+// StartExternalSession creates a new session for the passed user of an external identity provider, and returns it
+// together with the session token for the cookie. The name and role of the user are kept for the lifetime of the session.
+func StartExternalSession(sessions SessionRepository, u ApiUser, now time.Time) (Session, string, error) {
+	return startSession(sessions, u, true, now)
+}
+
+func startSession(sessions SessionRepository, u ApiUser, external bool, now time.Time) (Session, string, error) {
+	token, err := RandomHex(32)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	csrfToken, err := RandomHex(32)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	s := NewSession(uuid.New(), u.Id, hashToken(token), csrfToken, now, now.Add(SessionLifetime))
+	if external {
+		s.External = true
+		s.UserName = u.Name
+		s.Role = u.Role
+	}
+
+	err = sessions.SetSession(s)
+	if err != nil {
+		return Session{}, "", err
+	}
+
+	return s, token, nil
+}
+
+// IsExpired returns whether the session has expired at the passed time.
+func (s Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+// CheckCsrfToken returns whether the passed token is the CSRF token of the session.
+func (s Session) CheckCsrfToken(token string) bool {
+	return s.CsrfToken != "" && subtle.ConstantTimeCompare([]byte(s.CsrfToken), []byte(token)) == 1
+}
+
+// SetSessionCookie sets the cookie containing the session token. Secure cookies are only sent over HTTPS.
+func SetSessionCookie(w http.ResponseWriter, token string, expiresAt time.Time, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/ui/",
+		Expires:  expiresAt,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookie removes the cookie containing the session token.
+func ClearSessionCookie(w http.ResponseWriter, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/ui/",
+		MaxAge:   -1,
+		Secure:   secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memorySessionRepository is a simple in-memory SessionRepository for testing.
+type memorySessionRepository map[uuid.UUID]Session
+
+func (r memorySessionRepository) GetSessionByHash(hash string) (Session, error) {
+	for _, s := range r {
+		if s.Hash == hash {
+			return s, nil
+		}
+	}
+	return Session{}, repository.ErrNotFound
+}
+
+func (r memorySessionRepository) SetSession(s Session) error {
+	r[s.Id] = s
+	return nil
+}
+
+func (r memorySessionRepository) DeleteSessionById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func (r memorySessionRepository) DeleteSessionsExpiredBefore(t time.Time) (int64, error) {
+	var deleted int64
+	for id, s := range r {
+		if s.ExpiresAt.Before(t) {
+			delete(r, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// sessionRequest returns a request with the passed session token in the session cookie.
+func sessionRequest(method string, target string, token string, form url.Values) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: token})
+	return r
+}
+
+func TestStartSession(t *testing.T) {
+	sessions := memorySessionRepository{}
+	user := NewApiUser(uuid.New(), "user", nil, RoleViewer)
+	now := time.Now()
+
+	s, token, err := StartSession(sessions, user, now)
+	if err != nil {
+		t.Fatalf(`StartSession() returned error: %v`, err)
+	}
+
+	if s.UserId != user.Id || !s.ExpiresAt.Equal(now.Add(SessionLifetime)) || s.CsrfToken == "" {
+		t.Fatalf(`StartSession() returned unexpected session: %v`, s)
+	}
+
+	if s.Hash == token || s.Hash != hashToken(token) {
+		t.Fatalf(`Expected only the hash of the session token to be stored`)
+	}
+
+	if _, ok := sessions[s.Id]; !ok {
+		t.Fatalf(`Expected the session to be stored`)
+	}
+}
+
+func TestBrowserSessionAuth(t *testing.T) {
+	user := NewApiUser(uuid.New(), "user", nil, RoleViewer)
+	users := memoryRepository{user.Id: user}
+	sessions := memorySessionRepository{}
+
+	_, valid, _ := StartSession(sessions, user, time.Now())
+	_, expired, _ := StartSession(sessions, user, time.Now().Add(-SessionLifetime))
+
+	handler := BrowserSessionAuth(sessions, users, "/ui/login")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := UserFromContext(r.Context()); !ok || u.Id != user.Id {
+			t.Fatalf(`Expected user %s in the request context, got: %v, %v`, user.Id, u, ok)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		token    string
+		expected int
+	}{
+		{valid, http.StatusOK},
+		{expired, http.StatusSeeOther},
+		{"unknown", http.StatusSeeOther},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, sessionRequest(http.MethodGet, "/ui/systems?page=2", test.token, nil))
+
+		if w.Code != test.expected {
+			t.Fatalf(`Request with session token '%s' returned status %d, expected: %d`, test.token, w.Code, test.expected)
+		}
+
+		if w.Code == http.StatusSeeOther && w.Header().Get("Location") != "/ui/login?next=%2Fui%2Fsystems%3Fpage%3D2" {
+			t.Fatalf(`Request was redirected to '%s', expected the login page`, w.Header().Get("Location"))
+		}
+	}
+
+	if len(sessions) != 1 {
+		t.Fatalf(`Expected the expired session to be deleted, %d sessions left`, len(sessions))
+	}
+}
+
+func TestVerifyCsrfToken(t *testing.T) {
+	user := NewApiUser(uuid.New(), "user", nil, RoleViewer)
+	sessions := memorySessionRepository{}
+	s, token, _ := StartSession(sessions, user, time.Now())
+
+	handler := BrowserSessionAuth(sessions, memoryRepository{user.Id: user}, "/ui/login")(VerifyCsrfToken(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	tests := []struct {
+		method   string
+		form     url.Values
+		expected int
+	}{
+		{http.MethodGet, nil, http.StatusOK},
+		{http.MethodPost, url.Values{CsrfFieldName: {s.CsrfToken}}, http.StatusOK},
+		{http.MethodPost, url.Values{CsrfFieldName: {"wrong"}}, http.StatusForbidden},
+		{http.MethodPost, url.Values{}, http.StatusForbidden},
+		{http.MethodDelete, url.Values{}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, sessionRequest(test.method, "/ui/systems", token, test.form))
+
+		if w.Code != test.expected {
+			t.Fatalf(`%s request with form %v returned status %d, expected: %d`, test.method, test.form, w.Code, test.expected)
+		}
+	}
+}
+
+func TestBrowserSessionAuthExternal(t *testing.T) {
+	sessions := memorySessionRepository{}
+	user := NewApiUser(uuid.New(), "alice", nil, RoleOperator)
+	_, token, err := StartExternalSession(sessions, user, time.Now())
+	if err != nil {
+		t.Fatalf(`StartExternalSession() returned error: %v`, err)
+	}
+
+	// External users are not in the user repository, so they come from the session instead
+	handler := BrowserSessionAuth(sessions, memoryRepository{}, "/ui/login")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, ok := UserFromContext(r.Context())
+		if !ok || u.Id != user.Id || u.Name != user.Name || u.Role != user.Role {
+			t.Fatalf(`Expected user %v in the request context, got: %v, %v`, user, u, ok)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, sessionRequest(http.MethodGet, "/ui/", token, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf(`Request with external session returned status %d, expected: %d`, w.Code, http.StatusOK)
+	}
+}
@@ -58,3 +58,11 @@ func HTML(w http.ResponseWriter, code int, v string) error {
 	_, err := fmt.Fprint(w, v)
 	return err
 }
+
+// Content sends the passed content as-is, with the passed content type.
+func Content(w http.ResponseWriter, code int, contentType string, v string) error {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+	_, err := fmt.Fprint(w, v)
+	return err
+}
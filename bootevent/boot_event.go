@@ -0,0 +1,61 @@
+package bootevent
+
+import (
+	"github.com/google/uuid"
+	"net"
+	"time"
+)
+
+// Result is the outcome of a request for an iPXE config.
+type Result string
+
+const (
+	// ResultServed means that an iPXE script was served to the system.
+	ResultServed Result = "served"
+	// ResultNotFound means that the system is not registered and there is no default profile, so it was not booted.
+	ResultNotFound Result = "not-found"
+	// ResultError means that something went wrong while building or rendering the iPXE script.
+	ResultError Result = "error"
+)
+
+// BootEvent is a single request for an iPXE config, and what was served in response to it.
+type BootEvent struct {
+	Id   uuid.UUID
+	Time time.Time
+	Mac  net.HardwareAddr
+	// System is the registered system that booted, or uuid.Nil if the system is not registered.
+	System    uuid.UUID
+	ClientIp  net.IP
+	UserAgent string
+	Result    Result
+	// Profile is the profile that was served, or uuid.Nil if no profile was booted, e.g. when booting from the local disk.
+	Profile uuid.UUID
+	// BootMode is the boot mode that was served, or empty if nothing was served.
+	BootMode string
+	// ScriptHash is the SHA-256 hash of the iPXE script that was served, or empty if nothing was served.
+	ScriptHash string
+}
+
+func New(id uuid.UUID, t time.Time, mac net.HardwareAddr, system uuid.UUID, clientIp net.IP, userAgent string, result Result, profile uuid.UUID, bootMode string, scriptHash string) BootEvent {
+	return BootEvent{
+		Id:         id,
+		Time:       t,
+		Mac:        mac,
+		System:     system,
+		ClientIp:   clientIp,
+		UserAgent:  userAgent,
+		Result:     result,
+		Profile:    profile,
+		BootMode:   bootMode,
+		ScriptHash: scriptHash,
+	}
+}
+
+// ShortScriptHash returns the first 12 characters of the script hash, which is enough to tell scripts apart at a glance.
+func (e BootEvent) ShortScriptHash() string {
+	if len(e.ScriptHash) > 12 {
+		return e.ScriptHash[:12]
+	}
+
+	return e.ScriptHash
+}
@@ -0,0 +1,14 @@
+package bootevent
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Repository interface {
+	// GetBootEventsBySystemId returns the most recent boot events of the system, newest first, up to the passed limit.
+	GetBootEventsBySystemId(id uuid.UUID, limit int) ([]BootEvent, error)
+	RecordBootEvent(e BootEvent) error
+	// DeleteBootEventsBefore deletes all boot events older than the passed time, and returns how many were deleted.
+	DeleteBootEventsBefore(t time.Time) (int64, error)
+}
@@ -0,0 +1,2 @@
+-- Add an optional iPXE script template to profiles; an empty template means the default template is used.
+ALTER TABLE profile ADD COLUMN template text NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Kernel parameters are stored as an ordered list that may contain duplicate keys, e.g. multiple 'console=' parameters.
+-- Parameters set before this migration were written in random order; re-save profiles and systems to fix their order.
+-- The per-parameter length limit is lifted as well, since values like 'ip=' can easily exceed it.
+ALTER TABLE profile ALTER COLUMN kernelParameters TYPE text[];
+ALTER TABLE system ALTER COLUMN kernelParameters TYPE text[];
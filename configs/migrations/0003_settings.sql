@@ -0,0 +1,8 @@
+-- Global settings are stored in a single row, the default profile is booted by systems that are not registered.
+CREATE TABLE settings
+(
+    id             integer PRIMARY KEY CHECK (id = 1),
+    defaultProfile uuid REFERENCES profile (uuid) ON DELETE SET NULL
+);
+
+INSERT INTO settings (id, defaultProfile) VALUES (1, NULL);
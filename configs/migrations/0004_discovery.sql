@@ -0,0 +1,15 @@
+-- Systems that are not registered can be recorded when they request an iPXE config, so they can be promoted to a system later on.
+ALTER TABLE settings ADD COLUMN discoveryEnabled boolean NOT NULL DEFAULT false;
+
+CREATE TABLE discovered_system
+(
+    id         serial PRIMARY KEY,
+    uuid       uuid UNIQUE,
+    mac        macaddr UNIQUE,
+    firstSeen  timestamptz,
+    lastSeen   timestamptz,
+    sourceIp   inet,
+    vendor     varchar(128),
+    serial     varchar(128),
+    smbiosUuid varchar(64)
+);
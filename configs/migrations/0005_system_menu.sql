@@ -0,0 +1,10 @@
+-- Systems can be assigned multiple profiles, which are offered in an iPXE boot menu next to their default profile.
+ALTER TABLE system ADD COLUMN menuTimeout integer NOT NULL DEFAULT 0;
+
+CREATE TABLE system_profile
+(
+    system   uuid REFERENCES system (uuid) ON DELETE CASCADE,
+    profile  uuid REFERENCES profile (uuid) ON DELETE CASCADE,
+    position integer NOT NULL,
+    PRIMARY KEY (system, profile)
+);
@@ -0,0 +1,4 @@
+-- Systems can have a one-shot override of their boot configuration, which is cleared after it has been served once.
+ALTER TABLE system ADD COLUMN nextBoot boolean NOT NULL DEFAULT false;
+ALTER TABLE system ADD COLUMN nextBootProfile uuid REFERENCES profile (uuid) ON DELETE SET NULL;
+ALTER TABLE system ADD COLUMN nextBootKernelParameters text[];
@@ -0,0 +1,2 @@
+-- Systems can stay registered without booting their profile, e.g. by booting from their local disk instead.
+ALTER TABLE system ADD COLUMN bootMode varchar(16) NOT NULL DEFAULT 'profile';
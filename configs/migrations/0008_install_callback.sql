@@ -0,0 +1,3 @@
+-- Systems can report that their installation has completed using a per-boot token, after which they boot from their local disk.
+ALTER TABLE system ADD COLUMN installToken varchar(64) UNIQUE;
+ALTER TABLE system ADD COLUMN installedAt timestamptz;
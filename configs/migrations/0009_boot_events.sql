@@ -0,0 +1,20 @@
+-- Every request for an iPXE config is recorded as a boot event, which are removed again after the configured number of days.
+ALTER TABLE settings ADD COLUMN bootEventRetentionDays integer NOT NULL DEFAULT 30;
+
+CREATE TABLE boot_event
+(
+    id         serial PRIMARY KEY,
+    uuid       uuid UNIQUE,
+    time       timestamptz NOT NULL,
+    mac        macaddr,
+    system     uuid REFERENCES system (uuid) ON DELETE CASCADE,
+    clientIp   inet,
+    userAgent  varchar(256) NOT NULL DEFAULT '',
+    result     varchar(16) NOT NULL,
+    profile    uuid,
+    bootMode   varchar(16) NOT NULL DEFAULT '',
+    scriptHash varchar(64) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX boot_event_system_time ON boot_event (system, time);
+CREATE INDEX boot_event_time ON boot_event (time);
@@ -0,0 +1,17 @@
+-- Systems can be identified by multiple MAC addresses, their SMBIOS UUID, serial number, asset tag or hostname.
+ALTER TABLE system ADD COLUMN smbiosUuid uuid UNIQUE;
+ALTER TABLE system ADD COLUMN serial varchar(128) UNIQUE;
+ALTER TABLE system ADD COLUMN assetTag varchar(128) UNIQUE;
+ALTER TABLE system ADD COLUMN hostname varchar(253) UNIQUE;
+
+CREATE TABLE system_mac
+(
+    system   uuid REFERENCES system (uuid) ON DELETE CASCADE,
+    mac      macaddr UNIQUE,
+    position integer NOT NULL,
+    PRIMARY KEY (system, mac)
+);
+
+INSERT INTO system_mac (system, mac, position) SELECT uuid, mac, 0 FROM system WHERE mac IS NOT NULL;
+
+ALTER TABLE system DROP COLUMN mac;
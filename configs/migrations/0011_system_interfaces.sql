@@ -0,0 +1,6 @@
+-- The MAC addresses of systems become network interfaces, which can have a name and be marked as the boot interface.
+ALTER TABLE system_mac RENAME TO system_interface;
+ALTER TABLE system_interface ADD COLUMN name varchar(15) NOT NULL DEFAULT '';
+ALTER TABLE system_interface ADD COLUMN boot boolean NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX system_interface_boot ON system_interface (system) WHERE boot;
@@ -0,0 +1,7 @@
+-- Interfaces can have a static network configuration, which profiles can turn into kernel parameters for their network style.
+ALTER TABLE profile ADD COLUMN networkStyle varchar(32) NOT NULL DEFAULT '';
+
+ALTER TABLE system_interface ADD COLUMN address inet;
+ALTER TABLE system_interface ADD COLUMN gateway inet;
+ALTER TABLE system_interface ADD COLUMN nameservers inet[] NOT NULL DEFAULT '{}';
+ALTER TABLE system_interface ADD COLUMN hostname varchar(253) NOT NULL DEFAULT '';
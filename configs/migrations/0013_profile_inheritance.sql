@@ -0,0 +1,2 @@
+-- Profiles can inherit from a parent profile.
+ALTER TABLE profile ADD COLUMN parent uuid REFERENCES profile (uuid) ON DELETE RESTRICT;
@@ -0,0 +1,4 @@
+-- Profiles can load multiple initrds, which are stored as '<path> [name=<name>]'.
+ALTER TABLE profile ADD COLUMN initrds text[] NOT NULL DEFAULT '{}';
+UPDATE profile SET initrds = ARRAY[initrd] WHERE initrd IS NOT NULL AND initrd <> '';
+ALTER TABLE profile DROP COLUMN initrd;
@@ -0,0 +1,10 @@
+-- Systems and profiles can have metadata, and '${name}' references to variables are expanded in the kernel, initrds and kernel parameters.
+ALTER TABLE profile ADD COLUMN metadata jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE system ADD COLUMN metadata jsonb NOT NULL DEFAULT '{}';
+
+-- Existing '${' were passed on to iPXE as-is, e.g. '${net0/mac}', so escape them to keep them working
+UPDATE profile SET kernel = replace(kernel, '${', '$${'),
+                   initrds = ARRAY(SELECT replace(i, '${', '$${') FROM unnest(initrds) WITH ORDINALITY AS t(i, n) ORDER BY n),
+                   kernelParameters = ARRAY(SELECT replace(k, '${', '$${') FROM unnest(kernelParameters) WITH ORDINALITY AS t(k, n) ORDER BY n);
+UPDATE system SET kernelParameters = ARRAY(SELECT replace(k, '${', '$${') FROM unnest(kernelParameters) WITH ORDINALITY AS t(k, n) ORDER BY n),
+                  nextBootKernelParameters = ARRAY(SELECT replace(k, '${', '$${') FROM unnest(nextBootKernelParameters) WITH ORDINALITY AS t(k, n) ORDER BY n);
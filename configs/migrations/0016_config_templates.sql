@@ -0,0 +1,18 @@
+-- Config templates, e.g. kickstart files, can be attached to profiles and are rendered for every system that boots them.
+CREATE TABLE config_template
+(
+    id          serial PRIMARY KEY,
+    uuid        uuid UNIQUE,
+    name        varchar(64) UNIQUE,
+    description varchar(128),
+    type        varchar(32) NOT NULL,
+    content     text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE profile_config_template
+(
+    profile         uuid REFERENCES profile (uuid) ON DELETE CASCADE,
+    config_template uuid REFERENCES config_template (uuid) ON DELETE CASCADE,
+    position        integer NOT NULL,
+    PRIMARY KEY (profile, config_template)
+);
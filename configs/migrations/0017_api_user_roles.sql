@@ -0,0 +1,3 @@
+-- API users get a role; existing users could already do everything, so they become admins.
+ALTER TABLE api_user ADD COLUMN role varchar(16) NOT NULL DEFAULT 'admin';
+ALTER TABLE api_user ALTER COLUMN role DROP DEFAULT;
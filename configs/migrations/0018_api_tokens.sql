@@ -0,0 +1,13 @@
+-- Users can create named bearer tokens for the API; only their hashes are stored.
+CREATE TABLE api_token
+(
+    id         serial PRIMARY KEY,
+    uuid       uuid UNIQUE,
+    api_user   uuid NOT NULL REFERENCES api_user (uuid) ON DELETE CASCADE,
+    name       varchar(64) NOT NULL,
+    hash       varchar(64) UNIQUE NOT NULL,
+    createdAt  timestamptz NOT NULL,
+    expiresAt  timestamptz,
+    lastUsedAt timestamptz,
+    UNIQUE (api_user, name)
+);
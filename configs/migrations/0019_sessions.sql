@@ -0,0 +1,11 @@
+-- The UI uses cookie sessions instead of basic authentication; only the hashes of the session tokens are stored.
+CREATE TABLE session
+(
+    id        serial PRIMARY KEY,
+    uuid      uuid UNIQUE,
+    api_user  uuid NOT NULL REFERENCES api_user (uuid) ON DELETE CASCADE,
+    hash      varchar(64) UNIQUE NOT NULL,
+    csrfToken varchar(64) NOT NULL,
+    createdAt timestamptz NOT NULL,
+    expiresAt timestamptz NOT NULL
+);
@@ -0,0 +1,6 @@
+-- Users that log in through OpenID Connect are not stored, so their sessions contain their ID, name and role instead.
+ALTER TABLE session ALTER COLUMN api_user DROP NOT NULL;
+ALTER TABLE session ADD COLUMN externalUser uuid;
+ALTER TABLE session ADD COLUMN externalName varchar(255);
+ALTER TABLE session ADD COLUMN externalRole varchar(16);
+ALTER TABLE session ADD CONSTRAINT session_owner CHECK ((api_user IS NULL) <> (externalUser IS NULL));
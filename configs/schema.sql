@@ -6,29 +6,165 @@ CREATE TABLE profile
     name             varchar(64) UNIQUE,
     description      varchar(128),
     kernel           varchar(128),
-    initrd           varchar(128),
-    kernelParameters varchar(128)[]
+    initrds          text[] NOT NULL DEFAULT '{}',
+    kernelParameters text[],
+    template         text NOT NULL DEFAULT '',
+    networkStyle     varchar(32) NOT NULL DEFAULT '',
+    parent           uuid REFERENCES profile (uuid) ON DELETE RESTRICT,
+    metadata         jsonb NOT NULL DEFAULT '{}'
+);
+
+DROP TABLE IF EXISTS config_template;
+CREATE TABLE config_template
+(
+    id          serial PRIMARY KEY,
+    uuid        uuid UNIQUE,
+    name        varchar(64) UNIQUE,
+    description varchar(128),
+    type        varchar(32) NOT NULL,
+    content     text NOT NULL DEFAULT ''
+);
+
+DROP TABLE IF EXISTS profile_config_template;
+CREATE TABLE profile_config_template
+(
+    profile         uuid REFERENCES profile (uuid) ON DELETE CASCADE,
+    config_template uuid REFERENCES config_template (uuid) ON DELETE CASCADE,
+    position        integer NOT NULL,
+    PRIMARY KEY (profile, config_template)
 );
 
 DROP TABLE IF EXISTS system;
 CREATE TABLE system
 (
-    id               serial PRIMARY KEY,
-    uuid             uuid UNIQUE,
-    name             varchar(64) UNIQUE,
-    description      varchar(128),
-    profile          uuid REFERENCES profile (uuid) ON DELETE CASCADE,
-    mac              macaddr UNIQUE,
-    kernelParameters varchar(128)[]
+    id                       serial PRIMARY KEY,
+    uuid                     uuid UNIQUE,
+    name                     varchar(64) UNIQUE,
+    description              varchar(128),
+    profile                  uuid REFERENCES profile (uuid) ON DELETE CASCADE,
+    smbiosUuid               uuid UNIQUE,
+    serial                   varchar(128) UNIQUE,
+    assetTag                 varchar(128) UNIQUE,
+    hostname                 varchar(253) UNIQUE,
+    kernelParameters         text[],
+    menuTimeout              integer NOT NULL DEFAULT 0,
+    bootMode                 varchar(16) NOT NULL DEFAULT 'profile',
+    installToken             varchar(64) UNIQUE,
+    installedAt              timestamptz,
+    nextBoot                 boolean NOT NULL DEFAULT false,
+    nextBootProfile          uuid REFERENCES profile (uuid) ON DELETE SET NULL,
+    nextBootKernelParameters text[],
+    metadata                 jsonb NOT NULL DEFAULT '{}'
+);
+
+DROP TABLE IF EXISTS system_profile;
+CREATE TABLE system_profile
+(
+    system   uuid REFERENCES system (uuid) ON DELETE CASCADE,
+    profile  uuid REFERENCES profile (uuid) ON DELETE CASCADE,
+    position integer NOT NULL,
+    PRIMARY KEY (system, profile)
 );
 
+DROP TABLE IF EXISTS system_interface;
+CREATE TABLE system_interface
+(
+    system      uuid REFERENCES system (uuid) ON DELETE CASCADE,
+    mac         macaddr UNIQUE,
+    name        varchar(15) NOT NULL DEFAULT '',
+    boot        boolean NOT NULL DEFAULT false,
+    address     inet,
+    gateway     inet,
+    nameservers inet[] NOT NULL DEFAULT '{}',
+    hostname    varchar(253) NOT NULL DEFAULT '',
+    position    integer NOT NULL,
+    PRIMARY KEY (system, mac)
+);
+
+CREATE UNIQUE INDEX system_interface_boot ON system_interface (system) WHERE boot;
+
 DROP TABLE IF EXISTS api_user;
 CREATE TABLE api_user
 (
     id       serial PRIMARY KEY,
     uuid     uuid UNIQUE,
     name     varchar(64) UNIQUE,
-    password varchar
+    password varchar,
+    role     varchar(16) NOT NULL
+);
+
+INSERT INTO api_user (uuid, name, password, role) VALUES ('62fb65af-2d12-4758-93d6-7b58eadde3f1', 'admin', '$2a$10$dYnBNGXrDH/1Rf75zqkENelFhrmPEQrUTARkgYOFhKyGJn/nvi90e', 'admin');
+
+DROP TABLE IF EXISTS api_token;
+CREATE TABLE api_token
+(
+    id         serial PRIMARY KEY,
+    uuid       uuid UNIQUE,
+    api_user   uuid NOT NULL REFERENCES api_user (uuid) ON DELETE CASCADE,
+    name       varchar(64) NOT NULL,
+    hash       varchar(64) UNIQUE NOT NULL,
+    createdAt  timestamptz NOT NULL,
+    expiresAt  timestamptz,
+    lastUsedAt timestamptz,
+    UNIQUE (api_user, name)
+);
+
+DROP TABLE IF EXISTS session;
+CREATE TABLE session
+(
+    id           serial PRIMARY KEY,
+    uuid         uuid UNIQUE,
+    api_user     uuid REFERENCES api_user (uuid) ON DELETE CASCADE,
+    externalUser uuid,
+    externalName varchar(255),
+    externalRole varchar(16),
+    hash         varchar(64) UNIQUE NOT NULL,
+    csrfToken    varchar(64) NOT NULL,
+    createdAt    timestamptz NOT NULL,
+    expiresAt    timestamptz NOT NULL,
+    CONSTRAINT session_owner CHECK ((api_user IS NULL) <> (externalUser IS NULL))
+);
+
+DROP TABLE IF EXISTS settings;
+CREATE TABLE settings
+(
+    id                     integer PRIMARY KEY CHECK (id = 1),
+    defaultProfile         uuid REFERENCES profile (uuid) ON DELETE SET NULL,
+    discoveryEnabled       boolean NOT NULL DEFAULT false,
+    bootEventRetentionDays integer NOT NULL DEFAULT 30
+);
+
+INSERT INTO settings (id, defaultProfile, discoveryEnabled, bootEventRetentionDays) VALUES (1, NULL, false, 30);
+
+DROP TABLE IF EXISTS discovered_system;
+CREATE TABLE discovered_system
+(
+    id         serial PRIMARY KEY,
+    uuid       uuid UNIQUE,
+    mac        macaddr UNIQUE,
+    firstSeen  timestamptz,
+    lastSeen   timestamptz,
+    sourceIp   inet,
+    vendor     varchar(128),
+    serial     varchar(128),
+    smbiosUuid varchar(64)
+);
+
+DROP TABLE IF EXISTS boot_event;
+CREATE TABLE boot_event
+(
+    id         serial PRIMARY KEY,
+    uuid       uuid UNIQUE,
+    time       timestamptz NOT NULL,
+    mac        macaddr,
+    system     uuid REFERENCES system (uuid) ON DELETE CASCADE,
+    clientIp   inet,
+    userAgent  varchar(256) NOT NULL DEFAULT '',
+    result     varchar(16) NOT NULL,
+    profile    uuid,
+    bootMode   varchar(16) NOT NULL DEFAULT '',
+    scriptHash varchar(64) NOT NULL DEFAULT ''
 );
 
-INSERT INTO api_user (uuid, name, password) VALUES ('62fb65af-2d12-4758-93d6-7b58eadde3f1', 'admin', '$2a$10$dYnBNGXrDH/1Rf75zqkENelFhrmPEQrUTARkgYOFhKyGJn/nvi90e');
+CREATE INDEX boot_event_system_time ON boot_event (system, time);
+CREATE INDEX boot_event_time ON boot_event (time);
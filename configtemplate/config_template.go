@@ -0,0 +1,146 @@
+package configtemplate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"text/template"
+	"text/template/parse"
+)
+
+// ConfigTemplate is a file that installers fetch while installing a system, e.g. a kickstart file or an ignition config.
+// Its Content is a text/template that is rendered for every system, with the same data as the iPXE script of the system.
+type ConfigTemplate struct {
+	Id          uuid.UUID
+	Name        string
+	Description string
+	Type        Type
+	Content     string
+}
+
+func New(id uuid.UUID, name string, description string, t Type, content string) (ConfigTemplate, error) {
+	var c ConfigTemplate
+
+	if err := validateName(name); err != nil {
+		return c, err
+	}
+
+	if err := validateType(t); err != nil {
+		return c, err
+	}
+
+	if err := validateContent(content); err != nil {
+		return c, err
+	}
+
+	return ConfigTemplate{
+		Id:          id,
+		Name:        name,
+		Description: description,
+		Type:        t,
+		Content:     content,
+	}, nil
+}
+
+// UsesInstallToken returns whether the content references the install token of the system, as .InstallToken or .InstallCompleteUrl.
+// Such a config template is only served together with the install token that the system received with its iPXE script.
+// The parsed template is inspected, so the names in plain text or comments do not count.
+func (c ConfigTemplate) UsesInstallToken() bool {
+	tmpl, err := template.New("").Parse(c.Content)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && usesInstallToken(t.Tree.Root) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// usesInstallToken walks the parse tree below the node, and returns whether any field that it accesses is the install token.
+func usesInstallToken(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if usesInstallToken(child) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesInstallToken(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if usesInstallToken(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if usesInstallToken(arg) {
+				return true
+			}
+		}
+	case *parse.IfNode:
+		return usesInstallToken(n.Pipe) || usesInstallToken(n.List) || usesInstallToken(n.ElseList)
+	case *parse.RangeNode:
+		return usesInstallToken(n.Pipe) || usesInstallToken(n.List) || usesInstallToken(n.ElseList)
+	case *parse.WithNode:
+		return usesInstallToken(n.Pipe) || usesInstallToken(n.List) || usesInstallToken(n.ElseList)
+	case *parse.TemplateNode:
+		return usesInstallToken(n.Pipe)
+	case *parse.ChainNode:
+		return usesInstallToken(n.Node) || isInstallTokenField(n.Field)
+	case *parse.FieldNode:
+		return isInstallTokenField(n.Ident)
+	case *parse.VariableNode:
+		// The first identifier is the name of the variable itself, e.g. '$' in '$.InstallToken'
+		return isInstallTokenField(n.Ident[1:])
+	}
+
+	return false
+}
+
+// isInstallTokenField returns whether any of the identifiers of a field access is the install token.
+func isInstallTokenField(ident []string) bool {
+	for _, i := range ident {
+		if i == "InstallToken" || i == "InstallCompleteUrl" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateName checks that the name can be used as the file name in the URL that the config template is served on, e.g. 'ks.cfg'.
+func validateName(name string) error {
+	p := "^[a-zA-Z0-9-_.]{1,64}$"
+	matched, err := regexp.MatchString(p, name)
+	if err != nil {
+		return err
+	}
+
+	if !matched || name == "." || name == ".." {
+		return errors.New("name contains illegal characters")
+	}
+
+	return nil
+}
+
+func validateContent(content string) error {
+	_, err := template.New("").Parse(content)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	return nil
+}
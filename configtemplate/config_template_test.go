@@ -0,0 +1,93 @@
+package configtemplate
+
+import (
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+)
+
+func TestNewConfigTemplate(t *testing.T) {
+	expected := ConfigTemplate{
+		Id:          uuid.Nil,
+		Name:        "ks.cfg",
+		Description: "Kickstart",
+		Type:        TypeKickstart,
+		Content:     "network --hostname={{ .System.Name }}",
+	}
+
+	actual, err := New(uuid.Nil, "ks.cfg", "Kickstart", TypeKickstart, "network --hostname={{ .System.Name }}")
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`New() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestNewConfigTemplateInvalidName(t *testing.T) {
+	for _, name := range []string{"", "ks cfg", "ks/cfg", ".."} {
+		actual, err := New(uuid.Nil, name, "", TypeKickstart, "")
+		if err == nil {
+			t.Fatalf(`Expected New() to return invalid name error for '%s', got: %v, %v`, name, actual, err)
+		}
+	}
+}
+
+func TestNewConfigTemplateInvalidType(t *testing.T) {
+	actual, err := New(uuid.Nil, "ks.cfg", "", Type("invalid"), "")
+	if err == nil {
+		t.Fatalf(`Expected New() to return invalid type error, got: %v, %v`, actual, err)
+	}
+}
+
+func TestNewConfigTemplateInvalidContent(t *testing.T) {
+	actual, err := New(uuid.Nil, "ks.cfg", "", TypeKickstart, "{{ .System.Name")
+	if err == nil {
+		t.Fatalf(`Expected New() to return invalid template error, got: %v, %v`, actual, err)
+	}
+}
+
+func TestTypeContentType(t *testing.T) {
+	expected := map[Type]string{
+		TypeKickstart:    "text/plain",
+		TypeIgnition:     "application/json",
+		TypeAutounattend: "application/xml",
+	}
+
+	for typ, contentType := range expected {
+		if actual := typ.ContentType(); actual != contentType {
+			t.Fatalf(`Type(%s).ContentType() = %v, expected: %v`, typ, actual, contentType)
+		}
+	}
+}
+
+func TestConfigTemplateUsesInstallToken(t *testing.T) {
+	c := ConfigTemplate{Content: "%post\ncurl -X POST '{{ .InstallCompleteUrl }}'\n%end\n"}
+	if !c.UsesInstallToken() {
+		t.Fatalf(`ConfigTemplate.UsesInstallToken() = false, expected: true`)
+	}
+
+	// Nested references count as well
+	uses := []string{
+		"{{ if .System }}token={{ $.InstallToken }}{{ end }}",
+		"{{ with $c := . }}{{ $c.InstallCompleteUrl }}{{ end }}",
+		"{{ define \"post\" }}{{ .InstallCompleteUrl }}{{ end }}{{ template \"post\" . }}",
+		"{{ range .Menu }}{{ else }}{{ print .InstallToken }}{{ end }}",
+	}
+	for _, content := range uses {
+		c = ConfigTemplate{Content: content}
+		if !c.UsesInstallToken() {
+			t.Fatalf(`ConfigTemplate{Content: %q}.UsesInstallToken() = false, expected: true`, content)
+		}
+	}
+
+	// Only the fields that the template accesses count, not plain text or comments
+	unused := []string{
+		"network --hostname={{ .System.Name }}\n",
+		"# The .InstallToken is not used here\n",
+		"{{/* .InstallCompleteUrl */}}",
+	}
+	for _, content := range unused {
+		c = ConfigTemplate{Content: content}
+		if c.UsesInstallToken() {
+			t.Fatalf(`ConfigTemplate{Content: %q}.UsesInstallToken() = true, expected: false`, content)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package configtemplate
+
+import "github.com/google/uuid"
+
+type Repository interface {
+	GetConfigTemplates() ([]ConfigTemplate, error)
+	GetConfigTemplateById(id uuid.UUID) (ConfigTemplate, error)
+	GetConfigTemplateByName(name string) (ConfigTemplate, error)
+	SetConfigTemplate(c ConfigTemplate) error
+	DeleteConfigTemplateById(id uuid.UUID) error
+}
@@ -0,0 +1,50 @@
+package configtemplate
+
+import "errors"
+
+// Type is the kind of installer configuration that a ConfigTemplate contains. It determines the content type it is served with.
+type Type string
+
+const (
+	TypeKickstart         Type = "kickstart"
+	TypePreseed           Type = "preseed"
+	TypeCloudInitUserData Type = "cloud-init-user-data"
+	TypeCloudInitMetaData Type = "cloud-init-meta-data"
+	TypeIgnition          Type = "ignition"
+	TypeAutounattend      Type = "autounattend"
+)
+
+// Types contains every valid Type.
+var Types = []Type{TypeKickstart, TypePreseed, TypeCloudInitUserData, TypeCloudInitMetaData, TypeIgnition, TypeAutounattend}
+
+// ParseType parses s into a Type, and returns an error if it is not a valid type.
+func ParseType(s string) (Type, error) {
+	t := Type(s)
+	if err := validateType(t); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// ContentType returns the MIME type that a rendered config template of the Type is served with.
+func (t Type) ContentType() string {
+	switch t {
+	case TypeIgnition:
+		return "application/json"
+	case TypeAutounattend:
+		return "application/xml"
+	default:
+		return "text/plain"
+	}
+}
+
+func validateType(t Type) error {
+	for _, v := range Types {
+		if t == v {
+			return nil
+		}
+	}
+
+	return errors.New("invalid config template type " + string(t))
+}
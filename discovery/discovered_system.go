@@ -0,0 +1,54 @@
+package discovery
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"net"
+	"time"
+)
+
+// MaxDiscoveredSystems is the maximum number of discovered systems that are kept.
+// Anyone can request an iPXE config with a made-up MAC address, so the discovered systems must not grow without bound.
+const MaxDiscoveredSystems = 1000
+
+// Retention is how long a discovered system is kept after it was last seen.
+const Retention = 30 * 24 * time.Hour
+
+var ErrLimitReached = errors.New("the maximum number of discovered systems has been reached")
+
+// DiscoveredSystem is a system that requested an iPXE config while it was not registered yet.
+// It can be promoted into a system.System once someone has decided what it should boot.
+type DiscoveredSystem struct {
+	Id        uuid.UUID
+	Mac       net.HardwareAddr
+	FirstSeen time.Time
+	LastSeen  time.Time
+	SourceIp  net.IP
+	// The fields below are reported by iPXE itself, and can not be trusted to be valid or even present
+	Vendor     string
+	Serial     string
+	SmbiosUuid string
+}
+
+func New(id uuid.UUID, mac net.HardwareAddr, firstSeen time.Time, lastSeen time.Time, sourceIp net.IP, vendor string, serial string, smbiosUuid string) DiscoveredSystem {
+	return DiscoveredSystem{
+		Id:         id,
+		Mac:        mac,
+		FirstSeen:  firstSeen,
+		LastSeen:   lastSeen,
+		SourceIp:   sourceIp,
+		Vendor:     vendor,
+		Serial:     serial,
+		SmbiosUuid: smbiosUuid,
+	}
+}
+
+// ParsedSmbiosUuid returns the SMBIOS UUID that iPXE reported, or uuid.Nil if it is empty or not a valid UUID.
+func (d DiscoveredSystem) ParsedSmbiosUuid() uuid.UUID {
+	id, err := uuid.Parse(d.SmbiosUuid)
+	if err != nil {
+		return uuid.Nil
+	}
+
+	return id
+}
@@ -0,0 +1,18 @@
+package discovery
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Repository interface {
+	GetDiscoveredSystems() ([]DiscoveredSystem, error)
+	GetDiscoveredSystemById(id uuid.UUID) (DiscoveredSystem, error)
+	// RecordDiscoveredSystem stores the discovered system, or updates the existing one with the same MAC address.
+	// The ID and first seen timestamp of an existing discovered system are kept.
+	// A new discovered system is not stored if there already are MaxDiscoveredSystems, in which case ErrLimitReached is returned.
+	RecordDiscoveredSystem(d DiscoveredSystem) error
+	DeleteDiscoveredSystemById(id uuid.UUID) error
+	// DeleteDiscoveredSystemsLastSeenBefore deletes all discovered systems that were last seen before the passed time, and returns how many were deleted.
+	DeleteDiscoveredSystemsLastSeenBefore(t time.Time) (int64, error)
+}
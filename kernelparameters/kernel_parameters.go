@@ -1,12 +1,65 @@
 package kernelparameters
 
 import (
-	"fmt"
-	"regexp"
 	"strings"
+	"unicode"
 )
 
-type KernelParameters map[string]string
+// KernelParameter is a single kernel parameter, e.g. 'quiet' or 'console=ttyS0,115200'.
+// Parameters without a value, like 'quiet', have an empty Value. Parameters with an empty value, like 'console=',
+// also have an empty Value, but have HasValue set, since the kernel treats them differently.
+//
+// A negated parameter, written as '!quiet' or '!console=tty0', is never passed to the kernel;
+// it removes matching parameters from the earlier sets when merging (see MergeKernelParameters).
+type KernelParameter struct {
+	Key   string
+	Value string
+	// HasValue is whether the parameter is assigned a value with '=', even if that value is empty.
+	// Parameters with a non-empty Value always have a value, regardless of HasValue.
+	HasValue bool
+	Negated  bool
+}
+
+// String returns the string representation of the KernelParameter, e.g. 'initrd=initrd'.
+// Values containing whitespace are quoted, e.g. 'rd.break="pre mount"'.
+func (p KernelParameter) String() string {
+	key := p.Key
+	if p.Negated {
+		key = "!" + key
+	}
+
+	if !p.hasValue() {
+		return key
+	}
+
+	if strings.IndexFunc(p.Value, unicode.IsSpace) >= 0 {
+		return key + "=\"" + p.Value + "\""
+	}
+
+	return key + "=" + p.Value
+}
+
+// hasValue returns whether the parameter is assigned a value, e.g. 'console=ttyS0' or 'console=', as opposed to just 'console'.
+func (p KernelParameter) hasValue() bool {
+	return p.HasValue || p.Value != ""
+}
+
+// removedBy returns whether p is removed by any of the passed negated parameters.
+// A negation without a value removes all parameters with the same key, a negation with a value only removes exact matches,
+// so '!console=' only removes 'console=' with an empty value.
+func (p KernelParameter) removedBy(negations KernelParameters) bool {
+	for _, n := range negations {
+		if p.Key == n.Key && (!n.hasValue() || (p.hasValue() && p.Value == n.Value)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KernelParameters is an ordered list of kernel parameters.
+// Duplicate keys are allowed and kept in order, since the kernel (and e.g. the last 'console=' parameter) relies on that.
+type KernelParameters []KernelParameter
 
 // String returns the string representation of the KernelParameters, e.g. 'initrd=initrd quiet splash'
 func (k KernelParameters) String() string {
@@ -15,66 +68,116 @@ func (k KernelParameters) String() string {
 
 // StringSlice returns the set of KernelParameters as a slice of strings, e.g. ["initrd=initrd", "quiet", "splash"]
 func (k KernelParameters) StringSlice() []string {
-	var s []string
+	s := make([]string, 0, len(k))
 
-	for k, v := range k {
-		if len(v) > 0 {
-			s = append(s, fmt.Sprintf("%s=%s", k, v))
-		} else {
-			s = append(s, k)
-		}
+	for _, p := range k {
+		s = append(s, p.String())
 	}
 
 	return s
 }
 
-// ParseString will parse and validate s into a set of KernelParameters, and return an error if it encounters an invalid kernel parameter.
+// ParseString will parse and validate s as a kernel command line into a set of KernelParameters, and return an error if it encounters an invalid kernel parameter.
+// It follows the quoting rules of the Linux kernel: parameters are separated by whitespace, unless the whitespace is enclosed in double quotes.
 func ParseString(s string) (KernelParameters, error) {
-	return ParseStringSlice(strings.Split(s, " "))
+	args, err := splitCommandLine(s)
+	if err != nil {
+		return KernelParameters{}, err
+	}
+
+	return ParseStringSlice(args)
 }
 
 // ParseStringSlice will parse and validate s into a set of KernelParameters, and return an error if it encounters an invalid kernel parameter.
+// Every entry in s must be a single kernel parameter; whitespace in a value has to be quoted, e.g. 'rd.break="pre mount"'.
 func ParseStringSlice(s []string) (KernelParameters, error) {
-	kp := make(KernelParameters)
+	kp := make(KernelParameters, 0, len(s))
 
 	for _, v := range s {
-		err := validateParameter(v)
+		// Older versions stored an empty parameter when none were given, so just skip those
+		if v == "" {
+			continue
+		}
+
+		p, err := parseParameter(v)
 		if err != nil {
 			return kp, err
 		}
 
-		splitStr := strings.Split(v, "=")
-		if len(splitStr) > 1 {
-			// key value parameter, e.g. 'initrd=initrd'
-			kp[splitStr[0]] = splitStr[1]
-		} else {
-			// just a value, e.g. 'quiet'
-			kp[splitStr[0]] = ""
-		}
+		kp = append(kp, p)
 	}
 
 	return kp, nil
 }
 
-func validateParameter(v string) error {
-	pattern := "\\s|=.*="
-	matched, err := regexp.MatchString(pattern, v)
-	if err != nil {
-		return err
+// splitCommandLine splits s into separate parameters on whitespace that is not enclosed in double quotes.
+// The quotes themselves are kept, they are stripped when parsing the separate parameters.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inQuote := false
+
+	for _, r := range s {
+		if unicode.IsSpace(r) && !inQuote {
+			if current.Len() > 0 {
+				args = append(args, current.String())
+				current.Reset()
+			}
+			continue
+		}
+
+		if r == '"' {
+			inQuote = !inQuote
+		}
+
+		current.WriteRune(r)
 	}
-	if matched {
-		return NewInvalidParameterError(v)
+
+	if inQuote {
+		return args, NewInvalidParameterError(current.String())
+	}
+
+	if current.Len() > 0 {
+		args = append(args, current.String())
 	}
 
-	return nil
+	return args, nil
 }
 
-// MergeKernelParameters merges multiple sets of KernelParameters, with values from maps being overwritten in the order that they're passed into the function
-func MergeKernelParameters(kp1 KernelParameters, kp2 ...KernelParameters) KernelParameters {
-	for _, kpMap := range kp2 {
-		for k, v := range kpMap {
-			kp1[k] = v
-		}
+// parseParameter parses a single kernel parameter, e.g. 'quiet', 'inst.ks=http://x/ks.cfg?a=b' or 'rd.break="pre mount"'.
+// Like the kernel, only the first '=' separates the key from the value, and quotes around the parameter or the value are stripped.
+// A leading '!' marks the parameter as negated, e.g. '!quiet'.
+func parseParameter(v string) (KernelParameter, error) {
+	var p KernelParameter
+
+	args, err := splitCommandLine(v)
+	if err != nil || len(args) != 1 || args[0] != v {
+		return p, NewInvalidParameterError(v)
+	}
+
+	s := v
+	if strings.HasPrefix(s, "!") {
+		p.Negated = true
+		s = s[1:]
+	}
+
+	// The entire parameter may be quoted, e.g. '"rd.break=pre mount"'
+	if strings.HasPrefix(s, "\"") {
+		s = strings.TrimSuffix(s[1:], "\"")
 	}
-	return kp1
+
+	key, value, hasValue := strings.Cut(s, "=")
+	if strings.HasPrefix(value, "\"") {
+		value = strings.TrimSuffix(value[1:], "\"")
+	}
+
+	// Quotes cannot be escaped on the kernel command line, so any remaining quotes or whitespace in the key are invalid
+	if key == "" || strings.ContainsAny(key, "\"!") || strings.IndexFunc(key, unicode.IsSpace) >= 0 || strings.ContainsAny(value, "\"") {
+		return p, NewInvalidParameterError(v)
+	}
+
+	p.Key = key
+	p.Value = value
+	p.HasValue = hasValue
+	return p, nil
 }
@@ -8,8 +8,8 @@ import (
 
 func TestKernelParameters_String(t *testing.T) {
 	v := KernelParameters{
-		"test1": "",
-		"test2": "value",
+		{Key: "test1"},
+		{Key: "test2", Value: "value", HasValue: true},
 	}
 
 	expected := "test1 test2=value"
@@ -21,8 +21,8 @@ func TestKernelParameters_String(t *testing.T) {
 
 func TestKernelParameters_StringSlice(t *testing.T) {
 	v := KernelParameters{
-		"test1": "",
-		"test2": "value",
+		{Key: "test1"},
+		{Key: "test2", Value: "value", HasValue: true},
 	}
 
 	expected := []string{"test1", "test2=value"}
@@ -32,12 +32,42 @@ func TestKernelParameters_StringSlice(t *testing.T) {
 	}
 }
 
+func TestKernelParameters_StringSliceKeepsOrder(t *testing.T) {
+	v := KernelParameters{
+		{Key: "console", Value: "tty0", HasValue: true},
+		{Key: "quiet"},
+		{Key: "console", Value: "ttyS0,115200", HasValue: true},
+	}
+
+	expected := []string{"console=tty0", "quiet", "console=ttyS0,115200"}
+	for i := 0; i < 10; i++ {
+		actual := v.StringSlice()
+		if !reflect.DeepEqual(actual, expected) {
+			t.Fatalf(`KernelParameters.StringSlice() = %s, expected: %s`, actual, expected)
+		}
+	}
+}
+
 func TestParseString(t *testing.T) {
 	v := "test1 test2=value"
 
 	expected := KernelParameters{
-		"test1": "",
-		"test2": "value",
+		{Key: "test1"},
+		{Key: "test2", Value: "value", HasValue: true},
+	}
+
+	actual, err := ParseString(v)
+	if !reflect.DeepEqual(actual, expected) || err != nil {
+		t.Fatalf(`ParseString() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestParseStringDuplicateKeys(t *testing.T) {
+	v := "console=tty0 console=ttyS0,115200"
+
+	expected := KernelParameters{
+		{Key: "console", Value: "tty0", HasValue: true},
+		{Key: "console", Value: "ttyS0,115200", HasValue: true},
 	}
 
 	actual, err := ParseString(v)
@@ -52,7 +82,7 @@ func TestParseStringInvalidParameter(t *testing.T) {
 
 	v := "===invalid test2=value"
 
-	expectedValue := make(KernelParameters)
+	expectedValue := KernelParameters{}
 
 	actualValue, err := ParseString(v)
 	if err == nil || !errors.As(err, &actualErr) {
@@ -67,8 +97,8 @@ func TestParseStringSlice(t *testing.T) {
 	}
 
 	expected := KernelParameters{
-		"test1": "",
-		"test2": "value",
+		{Key: "test1"},
+		{Key: "test2", Value: "value", HasValue: true},
 	}
 
 	actual, err := ParseStringSlice(v)
@@ -86,7 +116,7 @@ func TestParseStringSliceInvalidParameter(t *testing.T) {
 		"test2=value",
 	}
 
-	expectedValue := make(KernelParameters)
+	expectedValue := KernelParameters{}
 
 	actualValue, err := ParseStringSlice(v)
 	if err == nil || !errors.As(err, &actualErr) {
@@ -94,27 +124,141 @@ func TestParseStringSliceInvalidParameter(t *testing.T) {
 	}
 }
 
-func TestMergeKernelParameters(t *testing.T) {
-	kp1 := KernelParameters{
-		"test1": "",
-		"test2": "value",
-		"test3": "value2",
+func TestParseStringQuotedAndMultiEquals(t *testing.T) {
+	v := `ip=10.0.0.5::10.0.0.1:255.255.255.0:host:eth0:off  inst.ks=http://x/ks.cfg?a=b rd.break="pre mount" "rd.shell=a b"	quiet`
+
+	expected := KernelParameters{
+		{Key: "ip", Value: "10.0.0.5::10.0.0.1:255.255.255.0:host:eth0:off", HasValue: true},
+		{Key: "inst.ks", Value: "http://x/ks.cfg?a=b", HasValue: true},
+		{Key: "rd.break", Value: "pre mount", HasValue: true},
+		{Key: "rd.shell", Value: "a b", HasValue: true},
+		{Key: "quiet"},
+	}
+
+	actual, err := ParseString(v)
+	if !reflect.DeepEqual(actual, expected) || err != nil {
+		t.Fatalf(`ParseString() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestParseStringEmpty(t *testing.T) {
+	expected := KernelParameters{}
+
+	actual, err := ParseString("  ")
+	if !reflect.DeepEqual(actual, expected) || err != nil {
+		t.Fatalf(`ParseString() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestParseStringUnterminatedQuote(t *testing.T) {
+	var actualErr *InvalidParameterError
+
+	actualValue, err := ParseString(`quiet rd.break="pre mount`)
+	if err == nil || !errors.As(err, &actualErr) {
+		t.Fatalf(`Expected ParseString() to return unterminated quote error, got: %v, %v`, actualValue, err)
+	}
+}
+
+func TestParseStringRoundTrip(t *testing.T) {
+	v := `console=tty0 console=ttyS0,115200 inst.ks=http://x/ks.cfg?a=b rd.break="pre mount" quiet`
+
+	kp, err := ParseString(v)
+	if err != nil {
+		t.Fatalf(`ParseString() returned error: %v`, err)
+	}
+
+	actual := kp.String()
+	if actual != v {
+		t.Fatalf(`KernelParameters.String() = %s, expected: %s`, actual, v)
+	}
+
+	reparsed, err := ParseString(actual)
+	if !reflect.DeepEqual(reparsed, kp) || err != nil {
+		t.Fatalf(`ParseString() = %v, %v, expected: %v, nil`, reparsed, err, kp)
+	}
+}
+
+func TestParseStringSliceQuotedValue(t *testing.T) {
+	v := []string{
+		`rd.break="pre mount"`,
+		"inst.ks=http://x/ks.cfg?a=b",
 	}
 
-	kp2 := KernelParameters{
-		"test2": "newvalue",
-		"test4": "value3",
+	expected := KernelParameters{
+		{Key: "rd.break", Value: "pre mount", HasValue: true},
+		{Key: "inst.ks", Value: "http://x/ks.cfg?a=b", HasValue: true},
 	}
 
+	actual, err := ParseStringSlice(v)
+	if !reflect.DeepEqual(actual, expected) || err != nil {
+		t.Fatalf(`ParseStringSlice() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+
+	if !reflect.DeepEqual(actual.StringSlice(), v) {
+		t.Fatalf(`KernelParameters.StringSlice() = %v, expected: %v`, actual.StringSlice(), v)
+	}
+}
+
+func TestParseStringNegated(t *testing.T) {
+	v := `!quiet !console=tty0 !rd.break="pre mount"`
+
 	expected := KernelParameters{
-		"test1": "",
-		"test2": "newvalue",
-		"test3": "value2",
-		"test4": "value3",
+		{Key: "quiet", Negated: true},
+		{Key: "console", Value: "tty0", HasValue: true, Negated: true},
+		{Key: "rd.break", Value: "pre mount", HasValue: true, Negated: true},
 	}
 
-	actual := MergeKernelParameters(kp1, kp2)
-	if !reflect.DeepEqual(actual, expected) {
-		t.Fatalf(`MergeKernelParameters() = %v, expected: %v`, actual, expected)
+	actual, err := ParseString(v)
+	if !reflect.DeepEqual(actual, expected) || err != nil {
+		t.Fatalf(`ParseString() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+
+	if actual.String() != v {
+		t.Fatalf(`KernelParameters.String() = %s, expected: %s`, actual.String(), v)
+	}
+}
+
+func TestParseStringInvalidNegation(t *testing.T) {
+	var actualErr *InvalidParameterError
+
+	for _, v := range []string{"!", "!!quiet", "!=value"} {
+		actualValue, err := ParseString(v)
+		if err == nil || !errors.As(err, &actualErr) {
+			t.Fatalf(`Expected ParseString(%s) to return invalid parameter error, got: %v, %v`, v, actualValue, err)
+		}
+	}
+}
+
+func TestParseStringEmptyValueRoundTrip(t *testing.T) {
+	v := `console= quiet !console= !splash`
+
+	kp, err := ParseString(v)
+	if err != nil {
+		t.Fatalf(`ParseString() returned error: %v`, err)
+	}
+
+	expected := KernelParameters{
+		{Key: "console", HasValue: true},
+		{Key: "quiet"},
+		{Key: "console", HasValue: true, Negated: true},
+		{Key: "splash", Negated: true},
+	}
+	if !reflect.DeepEqual(kp, expected) {
+		t.Fatalf(`ParseString() = %#v, expected: %#v`, kp, expected)
+	}
+
+	if actual := kp.String(); actual != v {
+		t.Fatalf(`KernelParameters.String() = %s, expected: %s`, actual, v)
+	}
+}
+
+func TestMergeKernelParametersEmptyValueNegation(t *testing.T) {
+	base, _ := ParseString("console= console=tty0 console")
+
+	// '!console=' only removes the parameter with the empty value, not the other ones with the same key
+	negation, _ := ParseString("!console=")
+	expected := "console=tty0 console"
+	if actual := MergeKernelParameters(base, negation).String(); actual != expected {
+		t.Fatalf(`MergeKernelParameters() = %s, expected: %s`, actual, expected)
 	}
 }
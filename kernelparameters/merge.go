@@ -0,0 +1,109 @@
+package kernelparameters
+
+// Source is a named set of KernelParameters that is used as input for Merge, e.g. the parameters of a profile or a system.
+type Source struct {
+	Name             string
+	KernelParameters KernelParameters
+}
+
+func NewSource(name string, kp KernelParameters) Source {
+	return Source{
+		Name:             name,
+		KernelParameters: kp,
+	}
+}
+
+// EffectiveKernelParameter is a merged kernel parameter, together with the name of the Source that it came from.
+type EffectiveKernelParameter struct {
+	KernelParameter
+	Source string
+}
+
+// EffectiveKernelParameters is the ordered result of merging multiple sources of KernelParameters.
+type EffectiveKernelParameters []EffectiveKernelParameter
+
+// String returns the string representation of the merged parameters, e.g. 'initrd=initrd quiet splash'
+func (e EffectiveKernelParameters) String() string {
+	return e.KernelParameters().String()
+}
+
+// KernelParameters returns the merged KernelParameters, without the information about where they came from.
+func (e EffectiveKernelParameters) KernelParameters() KernelParameters {
+	kp := make(KernelParameters, 0, len(e))
+
+	for _, p := range e {
+		kp = append(kp, p.KernelParameter)
+	}
+
+	return kp
+}
+
+// Merge merges multiple sources of KernelParameters into a new set, in the order that they're passed into the function,
+// and keeps track of which source every resulting parameter came from.
+//
+// If a later source contains a key, all occurrences of that key in the earlier sources are replaced by the occurrences from the later source,
+// at the position of the first earlier occurrence. Keys that did not occur before are appended.
+// Negated parameters in a later source remove the matching parameters from the earlier sources before any of its other parameters are applied,
+// and are never part of the result themselves. None of the passed sources are modified.
+func Merge(sources ...Source) EffectiveKernelParameters {
+	merged := make(EffectiveKernelParameters, 0)
+
+	for _, s := range sources {
+		merged = mergeSource(merged, s)
+	}
+
+	return merged
+}
+
+// MergeKernelParameters merges multiple sets of KernelParameters into a new set, in the order that they're passed into the function.
+// It follows the same rules as Merge, but does not keep track of where the parameters came from. None of the passed sets are modified.
+func MergeKernelParameters(kp1 KernelParameters, kp2 ...KernelParameters) KernelParameters {
+	sources := []Source{NewSource("", kp1)}
+	for _, kp := range kp2 {
+		sources = append(sources, NewSource("", kp))
+	}
+
+	return Merge(sources...).KernelParameters()
+}
+
+// mergeSource returns a new set of EffectiveKernelParameters with the parameters in the source replacing the ones with the same key in base.
+func mergeSource(base EffectiveKernelParameters, source Source) EffectiveKernelParameters {
+	var negations KernelParameters
+	overrides := make(map[string]EffectiveKernelParameters)
+	for _, p := range source.KernelParameters {
+		if p.Negated {
+			negations = append(negations, p)
+			continue
+		}
+		overrides[p.Key] = append(overrides[p.Key], EffectiveKernelParameter{p, source.Name})
+	}
+
+	merged := make(EffectiveKernelParameters, 0, len(base)+len(source.KernelParameters))
+	inserted := make(map[string]bool)
+
+	for _, p := range base {
+		if p.removedBy(negations) {
+			continue
+		}
+
+		o, ok := overrides[p.Key]
+		if !ok {
+			merged = append(merged, p)
+			continue
+		}
+
+		// Insert all overriding occurrences at the position of the first occurrence, and drop the rest
+		if !inserted[p.Key] {
+			merged = append(merged, o...)
+			inserted[p.Key] = true
+		}
+	}
+
+	for _, p := range source.KernelParameters {
+		if !p.Negated && !inserted[p.Key] {
+			merged = append(merged, EffectiveKernelParameter{p, source.Name})
+		}
+	}
+
+	return merged
+}
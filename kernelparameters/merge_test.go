@@ -0,0 +1,256 @@
+package kernelparameters
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeKernelParameters(t *testing.T) {
+	kp1 := KernelParameters{
+		{Key: "test1"},
+		{Key: "test2", Value: "value"},
+		{Key: "test3", Value: "value2"},
+	}
+
+	kp2 := KernelParameters{
+		{Key: "test2", Value: "newvalue"},
+		{Key: "test4", Value: "value3"},
+	}
+
+	expected := KernelParameters{
+		{Key: "test1"},
+		{Key: "test2", Value: "newvalue"},
+		{Key: "test3", Value: "value2"},
+		{Key: "test4", Value: "value3"},
+	}
+
+	actual := MergeKernelParameters(kp1, kp2)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`MergeKernelParameters() = %v, expected: %v`, actual, expected)
+	}
+}
+
+func TestMergeKernelParametersDuplicateKeys(t *testing.T) {
+	kp1 := KernelParameters{
+		{Key: "console", Value: "tty0"},
+		{Key: "quiet"},
+		{Key: "console", Value: "ttyS0"},
+	}
+
+	kp2 := KernelParameters{
+		{Key: "console", Value: "tty1"},
+		{Key: "console", Value: "ttyS1,115200"},
+	}
+
+	kp3 := KernelParameters{
+		{Key: "splash"},
+		{Key: "splash"},
+	}
+
+	expected := KernelParameters{
+		{Key: "console", Value: "tty1"},
+		{Key: "console", Value: "ttyS1,115200"},
+		{Key: "quiet"},
+		{Key: "splash"},
+		{Key: "splash"},
+	}
+
+	actual := MergeKernelParameters(kp1, kp2, kp3)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`MergeKernelParameters() = %v, expected: %v`, actual, expected)
+	}
+}
+
+func TestMergeKernelParametersPrecedence(t *testing.T) {
+	profile := KernelParameters{
+		{Key: "quiet"},
+		{Key: "splash"},
+		{Key: "console", Value: "tty0"},
+		{Key: "console", Value: "ttyS0,115200"},
+		{Key: "root", Value: "/dev/sda1"},
+	}
+
+	tests := []struct {
+		name     string
+		system   KernelParameters
+		expected KernelParameters
+	}{
+		{
+			name:     "no system parameters",
+			system:   KernelParameters{},
+			expected: profile,
+		},
+		{
+			name:   "negation removes parameter without value",
+			system: KernelParameters{{Key: "quiet", Negated: true}},
+			expected: KernelParameters{
+				{Key: "splash"},
+				{Key: "console", Value: "tty0"},
+				{Key: "console", Value: "ttyS0,115200"},
+				{Key: "root", Value: "/dev/sda1"},
+			},
+		},
+		{
+			name:   "negation without value removes all occurrences",
+			system: KernelParameters{{Key: "console", Negated: true}},
+			expected: KernelParameters{
+				{Key: "quiet"},
+				{Key: "splash"},
+				{Key: "root", Value: "/dev/sda1"},
+			},
+		},
+		{
+			name:   "negation with value only removes exact match",
+			system: KernelParameters{{Key: "console", Value: "tty0", Negated: true}},
+			expected: KernelParameters{
+				{Key: "quiet"},
+				{Key: "splash"},
+				{Key: "console", Value: "ttyS0,115200"},
+				{Key: "root", Value: "/dev/sda1"},
+			},
+		},
+		{
+			name:     "negation of unknown parameter is ignored",
+			system:   KernelParameters{{Key: "nomodeset", Negated: true}},
+			expected: profile,
+		},
+		{
+			name: "negation is applied before additions in the same set",
+			system: KernelParameters{
+				{Key: "console", Value: "ttyS1", Negated: true},
+				{Key: "console", Negated: true},
+				{Key: "console", Value: "ttyS1"},
+			},
+			expected: KernelParameters{
+				{Key: "quiet"},
+				{Key: "splash"},
+				{Key: "root", Value: "/dev/sda1"},
+				{Key: "console", Value: "ttyS1"},
+			},
+		},
+		{
+			name: "override and negation combined",
+			system: KernelParameters{
+				{Key: "splash", Negated: true},
+				{Key: "root", Value: "/dev/nvme0n1p1"},
+			},
+			expected: KernelParameters{
+				{Key: "quiet"},
+				{Key: "console", Value: "tty0"},
+				{Key: "console", Value: "ttyS0,115200"},
+				{Key: "root", Value: "/dev/nvme0n1p1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := MergeKernelParameters(profile, tt.system)
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Fatalf(`MergeKernelParameters() = %v, expected: %v`, actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeKernelParametersNegationInFirstSet(t *testing.T) {
+	kp1 := KernelParameters{
+		{Key: "quiet", Negated: true},
+		{Key: "splash"},
+	}
+
+	expected := KernelParameters{
+		{Key: "splash"},
+	}
+
+	actual := MergeKernelParameters(kp1)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`MergeKernelParameters() = %v, expected: %v`, actual, expected)
+	}
+}
+
+func TestMergeKernelParametersLaterSetRestoresNegated(t *testing.T) {
+	kp1 := KernelParameters{{Key: "quiet"}}
+	kp2 := KernelParameters{{Key: "quiet", Negated: true}}
+	kp3 := KernelParameters{{Key: "quiet"}}
+
+	expected := KernelParameters{{Key: "quiet"}}
+
+	actual := MergeKernelParameters(kp1, kp2, kp3)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`MergeKernelParameters() = %v, expected: %v`, actual, expected)
+	}
+}
+
+func TestMergeKernelParametersDoesNotModifyInput(t *testing.T) {
+	kp1 := KernelParameters{
+		{Key: "quiet"},
+		{Key: "console", Value: "tty0"},
+	}
+
+	kp2 := KernelParameters{
+		{Key: "quiet", Negated: true},
+		{Key: "console", Value: "ttyS0"},
+		{Key: "splash"},
+	}
+
+	expected1 := KernelParameters{
+		{Key: "quiet"},
+		{Key: "console", Value: "tty0"},
+	}
+
+	expected2 := KernelParameters{
+		{Key: "quiet", Negated: true},
+		{Key: "console", Value: "ttyS0"},
+		{Key: "splash"},
+	}
+
+	_ = MergeKernelParameters(kp1, kp2)
+	if !reflect.DeepEqual(kp1, expected1) || !reflect.DeepEqual(kp2, expected2) {
+		t.Fatalf(`MergeKernelParameters() modified its input: %v, %v, expected: %v, %v`, kp1, kp2, expected1, expected2)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	profile := NewSource("profile:ubuntu", KernelParameters{
+		{Key: "quiet"},
+		{Key: "splash"},
+		{Key: "console", Value: "tty0"},
+	})
+
+	system := NewSource("system:web01", KernelParameters{
+		{Key: "splash", Negated: true},
+		{Key: "console", Value: "ttyS0,115200"},
+		{Key: "nomodeset"},
+	})
+
+	expected := EffectiveKernelParameters{
+		{KernelParameter: KernelParameter{Key: "quiet"}, Source: "profile:ubuntu"},
+		{KernelParameter: KernelParameter{Key: "console", Value: "ttyS0,115200"}, Source: "system:web01"},
+		{KernelParameter: KernelParameter{Key: "nomodeset"}, Source: "system:web01"},
+	}
+
+	actual := Merge(profile, system)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`Merge() = %v, expected: %v`, actual, expected)
+	}
+
+	expectedKp := KernelParameters{
+		{Key: "quiet"},
+		{Key: "console", Value: "ttyS0,115200"},
+		{Key: "nomodeset"},
+	}
+
+	if !reflect.DeepEqual(actual.KernelParameters(), expectedKp) {
+		t.Fatalf(`EffectiveKernelParameters.KernelParameters() = %v, expected: %v`, actual.KernelParameters(), expectedKp)
+	}
+}
+
+func TestMergeNoSources(t *testing.T) {
+	expected := EffectiveKernelParameters{}
+
+	actual := Merge()
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`Merge() = %v, expected: %v`, actual, expected)
+	}
+}
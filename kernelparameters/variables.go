@@ -0,0 +1,36 @@
+package kernelparameters
+
+import (
+	"fmt"
+	"github.com/evanebb/gobble/variables"
+)
+
+// ValidateVariables returns an error if the value of any of the parameters contains an invalid variable reference, see variables.Validate.
+func (k KernelParameters) ValidateVariables() error {
+	for _, p := range k {
+		if err := variables.Validate(p.Value); err != nil {
+			return fmt.Errorf("kernel parameter %s: %w", p.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// Expand returns a copy of the EffectiveKernelParameters, with the variables in the values of the parameters replaced by their values.
+func (e EffectiveKernelParameters) Expand(v variables.Variables) (EffectiveKernelParameters, error) {
+	expanded := make(EffectiveKernelParameters, 0, len(e))
+
+	for _, p := range e {
+		value, err := variables.Expand(p.Value, v)
+		if err != nil {
+			return expanded, fmt.Errorf("kernel parameter %s from %s: %w", p.Key, p.Source, err)
+		}
+
+		// A value that expands to nothing is still assigned, e.g. 'console=${console}' becomes 'console='
+		p.HasValue = p.hasValue()
+		p.Value = value
+		expanded = append(expanded, p)
+	}
+
+	return expanded, nil
+}
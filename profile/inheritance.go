@@ -0,0 +1,141 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+)
+
+// ErrInheritanceCycle is returned when a profile would (indirectly) inherit from itself.
+var ErrInheritanceCycle = errors.New("profile cannot inherit from itself")
+
+// ErrHasChildren is returned when a profile cannot be deleted, because other profiles inherit from it.
+var ErrHasChildren = errors.New("profile is the parent of other profiles")
+
+// Ancestors returns the chain of parents of the passed profile, starting with its direct parent.
+// If the chain contains a cycle, ErrInheritanceCycle is returned.
+func Ancestors(r Repository, p Profile) ([]Profile, error) {
+	var ancestors []Profile
+	seen := map[uuid.UUID]bool{p.Id: true}
+
+	for parentId := p.Parent; parentId != uuid.Nil; {
+		if seen[parentId] {
+			return ancestors, ErrInheritanceCycle
+		}
+		seen[parentId] = true
+
+		parent, err := r.GetProfileById(parentId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return ancestors, fmt.Errorf("parent profile %s does not exist: %w", parentId, err)
+			}
+			return ancestors, err
+		}
+
+		ancestors = append(ancestors, parent)
+		parentId = parent.Parent
+	}
+
+	return ancestors, nil
+}
+
+// ValidateParent checks that the parent of the passed profile exists, and that setting it does not create an inheritance cycle.
+// This has to be checked against the repository, since the other profiles in the chain can not be known by New.
+func ValidateParent(r Repository, p Profile) error {
+	_, err := Ancestors(r, p)
+	return err
+}
+
+// Resolve returns the effective profile that is booted for the passed profile, with everything it inherits from its parents filled in.
+// The kernel, initrds, template and network style of the closest profile in the chain that sets them are used,
+// and the kernel parameters, metadata and config templates of all profiles in the chain are merged, starting with the topmost parent.
+// The returned profile keeps the ID and name of the passed profile, and has no parent.
+func Resolve(r Repository, p Profile) (Profile, error) {
+	ancestors, err := Ancestors(r, p)
+	if err != nil {
+		return p, err
+	}
+
+	resolved := p
+	resolved.Parent = uuid.Nil
+
+	var kp []kernelparameters.KernelParameters
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		kp = append(kp, ancestors[i].KernelParameters)
+	}
+	kp = append(kp, p.KernelParameters)
+	resolved.KernelParameters = kernelparameters.MergeKernelParameters(kp[0], kp[1:]...)
+
+	// Metadata is merged the same way, so children can override the values of their parents
+	if len(ancestors) > 0 {
+		metadata := variables.Metadata{}
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			metadata = metadata.Merge(ancestors[i].Metadata)
+		}
+		resolved.Metadata = metadata.Merge(p.Metadata)
+	}
+
+	// The config templates of all profiles in the chain are available, starting with the ones of the topmost parent
+	if len(ancestors) > 0 {
+		var configTemplates []uuid.UUID
+		for i := len(ancestors) - 1; i >= 0; i-- {
+			configTemplates = appendMissing(configTemplates, ancestors[i].ConfigTemplates)
+		}
+		resolved.ConfigTemplates = appendMissing(configTemplates, p.ConfigTemplates)
+	}
+
+	for _, a := range ancestors {
+		if resolved.Kernel == "" {
+			resolved.Kernel = a.Kernel
+		}
+		if len(resolved.Initrds) == 0 {
+			resolved.Initrds = a.Initrds
+		}
+		if resolved.Template == "" {
+			resolved.Template = a.Template
+		}
+		if resolved.NetworkStyle == NetworkStyleNone {
+			resolved.NetworkStyle = a.NetworkStyle
+		}
+	}
+
+	return resolved, nil
+}
+
+// appendMissing appends the IDs that are not in ids yet.
+func appendMissing(ids []uuid.UUID, other []uuid.UUID) []uuid.UUID {
+	for _, id := range other {
+		found := false
+		for _, existing := range ids {
+			if existing == id {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// HasChildren returns whether any profile inherits from the profile with the passed ID.
+func HasChildren(r Repository, id uuid.UUID) (bool, error) {
+	profiles, err := r.GetProfiles()
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range profiles {
+		if p.Parent == id {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
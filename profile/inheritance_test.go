@@ -0,0 +1,138 @@
+package profile
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+)
+
+// memoryRepository is a simple in-memory Repository for testing.
+type memoryRepository map[uuid.UUID]Profile
+
+func (r memoryRepository) GetProfiles() ([]Profile, error) {
+	var profiles []Profile
+	for _, p := range r {
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+func (r memoryRepository) GetProfileById(id uuid.UUID) (Profile, error) {
+	p, ok := r[id]
+	if !ok {
+		return p, repository.ErrNotFound
+	}
+	return p, nil
+}
+
+func (r memoryRepository) SetProfile(p Profile) error {
+	r[p.Id] = p
+	return nil
+}
+
+func (r memoryRepository) DeleteProfileById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func TestNewProfileInheritsKernelAndInitrd(t *testing.T) {
+	actual, err := New(uuid.New(), "TestProfile", "", "", nil, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.New(), nil, nil)
+	if err != nil {
+		t.Fatalf(`New() = %v, %v, expected: Profile, nil`, actual, err)
+	}
+}
+
+func TestNewProfileOwnParent(t *testing.T) {
+	id := uuid.New()
+	actual, err := New(id, "TestProfile", "", "kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "", NetworkStyleNone, id, nil, nil)
+	if !errors.Is(err, ErrInheritanceCycle) {
+		t.Fatalf(`New() = %v, %v, expected: Profile{}, %v`, actual, err, ErrInheritanceCycle)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	kickstart, userData := uuid.New(), uuid.New()
+	base := Profile{
+		Id:               uuid.New(),
+		Name:             "base",
+		Kernel:           "kernel",
+		Initrds:          Initrds{{Path: "initrd"}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "quiet"}, {Key: "console", Value: "tty0"}},
+		Template:         "template",
+		NetworkStyle:     NetworkStyleDracut,
+		Metadata:         variables.Metadata{"os": "fedora", "version": "39"},
+		ConfigTemplates:  []uuid.UUID{kickstart},
+	}
+	middle := Profile{
+		Id:               uuid.New(),
+		Name:             "middle",
+		Initrds:          Initrds{{Path: "other-initrd"}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "console", Value: "ttyS0"}},
+		Parent:           base.Id,
+	}
+	child := Profile{
+		Id:               uuid.New(),
+		Name:             "child",
+		KernelParameters: kernelparameters.KernelParameters{{Key: "quiet", Negated: true}, {Key: "splash"}},
+		Parent:           middle.Id,
+		Metadata:         variables.Metadata{"version": "40"},
+		ConfigTemplates:  []uuid.UUID{userData, kickstart},
+	}
+	r := memoryRepository{base.Id: base, middle.Id: middle, child.Id: child}
+
+	expected := Profile{
+		Id:               child.Id,
+		Name:             "child",
+		Kernel:           "kernel",
+		Initrds:          Initrds{{Path: "other-initrd"}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "console", Value: "ttyS0"}, {Key: "splash"}},
+		Template:         "template",
+		NetworkStyle:     NetworkStyleDracut,
+		Metadata:         variables.Metadata{"os": "fedora", "version": "40"},
+		ConfigTemplates:  []uuid.UUID{kickstart, userData},
+	}
+
+	actual, err := Resolve(r, child)
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`Resolve() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestValidateParentCycle(t *testing.T) {
+	a := Profile{Id: uuid.New(), Name: "a"}
+	b := Profile{Id: uuid.New(), Name: "b", Parent: a.Id}
+	r := memoryRepository{a.Id: a, b.Id: b}
+
+	// Making a inherit from b would create the cycle a -> b -> a
+	a.Parent = b.Id
+	err := ValidateParent(r, a)
+	if !errors.Is(err, ErrInheritanceCycle) {
+		t.Fatalf(`ValidateParent() = %v, expected: %v`, err, ErrInheritanceCycle)
+	}
+}
+
+func TestValidateParentNotFound(t *testing.T) {
+	p := Profile{Id: uuid.New(), Name: "p", Parent: uuid.New()}
+	err := ValidateParent(memoryRepository{}, p)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf(`ValidateParent() = %v, expected: %v`, err, repository.ErrNotFound)
+	}
+}
+
+func TestHasChildren(t *testing.T) {
+	a := Profile{Id: uuid.New(), Name: "a"}
+	b := Profile{Id: uuid.New(), Name: "b", Parent: a.Id}
+	r := memoryRepository{a.Id: a, b.Id: b}
+
+	if ok, err := HasChildren(r, a.Id); err != nil || !ok {
+		t.Fatalf(`HasChildren() = %v, %v, expected: true, nil`, ok, err)
+	}
+
+	if ok, err := HasChildren(r, b.Id); err != nil || ok {
+		t.Fatalf(`HasChildren() = %v, %v, expected: false, nil`, ok, err)
+	}
+}
@@ -0,0 +1,154 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Initrd is an image that is loaded by iPXE next to the kernel, e.g. an initramfs, CPU microcode or a file for wimboot.
+type Initrd struct {
+	Path string
+	// Name is the optional name that the image is made available under, e.g. 'boot.wim' for wimboot.
+	Name string
+}
+
+// Initrds are the images of a profile, in the order they are loaded.
+type Initrds []Initrd
+
+// ParseInitrds parses initrds from a string containing one initrd per line, in the format '<path> [name=<name>]'.
+// Empty lines are ignored.
+func ParseInitrds(s string) (Initrds, error) {
+	var initrds Initrds
+
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		i, err := parseInitrd(fields)
+		if err != nil {
+			return initrds, fmt.Errorf("invalid initrd '%s': %w", strings.TrimSpace(line), err)
+		}
+
+		initrds = append(initrds, i)
+	}
+
+	return initrds, nil
+}
+
+// ParseInitrdsStringSlice parses initrds from a slice containing one initrd per element, in the format accepted by ParseInitrd.
+func ParseInitrdsStringSlice(s []string) (Initrds, error) {
+	initrds := make(Initrds, 0, len(s))
+	for _, v := range s {
+		i, err := ParseInitrd(v)
+		if err != nil {
+			return initrds, fmt.Errorf("invalid initrd '%s': %w", v, err)
+		}
+		initrds = append(initrds, i)
+	}
+	return initrds, nil
+}
+
+// ParseInitrd parses a single initrd in the format '<path> [name=<name>]', see ParseInitrds.
+func ParseInitrd(s string) (Initrd, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return Initrd{}, errors.New("initrd cannot be empty")
+	}
+
+	return parseInitrd(fields)
+}
+
+// parseInitrd parses a single initrd from the whitespace-separated fields of its line, see ParseInitrds.
+func parseInitrd(fields []string) (Initrd, error) {
+	i := Initrd{Path: fields[0]}
+
+	for _, f := range fields[1:] {
+		key, value, isOption := strings.Cut(f, "=")
+		if !isOption || key != "name" {
+			return i, errors.New("unknown option " + f)
+		}
+		i.Name = value
+	}
+
+	return i, nil
+}
+
+// String returns the initrd in the format accepted by ParseInitrd.
+func (i Initrd) String() string {
+	if i.Name == "" {
+		return i.Path
+	}
+	return i.Path + " name=" + i.Name
+}
+
+// String returns the initrds in the format accepted by ParseInitrds.
+func (initrds Initrds) String() string {
+	return strings.Join(initrds.StringSlice(), "\n")
+}
+
+// StringSlice returns every initrd in the format accepted by ParseInitrd.
+func (initrds Initrds) StringSlice() []string {
+	s := make([]string, 0, len(initrds))
+	for _, i := range initrds {
+		s = append(s, i.String())
+	}
+	return s
+}
+
+func validateInitrds(initrds Initrds) error {
+	names := make(map[string]bool)
+
+	for _, i := range initrds {
+		if err := validateInitrd(i.Path); err != nil {
+			return err
+		}
+
+		if i.Name != "" {
+			if err := validateInitrdName(i.Name); err != nil {
+				return err
+			}
+
+			if names[i.Name] {
+				return errors.New("initrd name " + i.Name + " is used more than once")
+			}
+			names[i.Name] = true
+		}
+	}
+
+	return nil
+}
+
+func validateInitrd(initrd string) error {
+	if initrd == "" {
+		return errors.New("initrd cannot be empty")
+	}
+
+	p := "\\s"
+	matched, err := regexp.MatchString(p, initrd)
+	if err != nil {
+		return err
+	}
+	if matched {
+		return errors.New("initrd contains illegal characters")
+	}
+
+	return nil
+}
+
+func validateInitrdName(name string) error {
+	p := `^[a-zA-Z0-9_.-]{1,64}$`
+	matched, err := regexp.MatchString(p, name)
+	if err != nil {
+		return err
+	}
+
+	if !matched {
+		return errors.New("invalid initrd name " + name)
+	}
+
+	return nil
+}
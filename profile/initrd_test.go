@@ -0,0 +1,60 @@
+package profile
+
+import (
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+)
+
+func TestParseInitrds(t *testing.T) {
+	s := "intel-ucode.img\n\ninitrd.img\nhttp://example.com/boot.wim name=boot.wim\n"
+	expected := Initrds{
+		{Path: "intel-ucode.img"},
+		{Path: "initrd.img"},
+		{Path: "http://example.com/boot.wim", Name: "boot.wim"},
+	}
+
+	actual, err := ParseInitrds(s)
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`ParseInitrds() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestParseInitrdsInvalid(t *testing.T) {
+	for _, s := range []string{"initrd.img boot.wim", "initrd.img label=boot.wim"} {
+		actual, err := ParseInitrds(s)
+		if err == nil {
+			t.Fatalf(`ParseInitrds(%q) = %v, %v, expected: Initrds{}, error`, s, actual, err)
+		}
+	}
+}
+
+func TestInitrdsString(t *testing.T) {
+	expected := "intel-ucode.img\nboot.wim name=boot.wim"
+
+	initrds, err := ParseInitrds(expected)
+	if err != nil {
+		t.Fatalf(`String(): failed to parse initrds`)
+	}
+
+	actual := initrds.String()
+	if actual != expected {
+		t.Fatalf(`String() = %q, expected: %q`, actual, expected)
+	}
+}
+
+func TestNewProfileDuplicateInitrdName(t *testing.T) {
+	initrds := Initrds{{Path: "BCD", Name: "BCD"}, {Path: "other-BCD", Name: "BCD"}}
+	actual, err := New(uuid.Nil, "TestProfile", "", "wimboot", initrds, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
+	if err == nil {
+		t.Fatalf(`Expected New() to return duplicate initrd name error, got: %v, %v`, actual, err)
+	}
+}
+
+func TestProfileInitrd(t *testing.T) {
+	p := Profile{Initrds: Initrds{{Path: "intel-ucode.img"}, {Path: "initrd.img"}}}
+	if actual := p.Initrd(); actual != "intel-ucode.img" {
+		t.Fatalf(`Initrd() = %q, expected: %q`, actual, "intel-ucode.img")
+	}
+}
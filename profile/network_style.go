@@ -0,0 +1,39 @@
+package profile
+
+import "errors"
+
+// NetworkStyle determines the kernel parameters that are generated from the network configuration of the interfaces of a system.
+// Installers and initramfs implementations expect their static network configuration in different parameters.
+type NetworkStyle string
+
+const (
+	// NetworkStyleNone does not generate any network kernel parameters.
+	NetworkStyleNone NetworkStyle = ""
+	// NetworkStyleDracut generates the 'ip=', 'ifname=' and 'nameserver=' parameters that are used by dracut, e.g. for Fedora, RHEL and CoreOS.
+	NetworkStyleDracut NetworkStyle = "dracut"
+	// NetworkStyleDebianInstaller generates the 'netcfg/*' parameters that are used by the Debian and Ubuntu installer.
+	NetworkStyleDebianInstaller NetworkStyle = "debian-installer"
+)
+
+// NetworkStyles contains every valid NetworkStyle.
+var NetworkStyles = []NetworkStyle{NetworkStyleNone, NetworkStyleDracut, NetworkStyleDebianInstaller}
+
+// ParseNetworkStyle parses s into a NetworkStyle, and returns an error if it is not a valid network style.
+func ParseNetworkStyle(s string) (NetworkStyle, error) {
+	n := NetworkStyle(s)
+	if err := validateNetworkStyle(n); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+func validateNetworkStyle(n NetworkStyle) error {
+	for _, v := range NetworkStyles {
+		if n == v {
+			return nil
+		}
+	}
+
+	return errors.New("invalid network style " + string(n))
+}
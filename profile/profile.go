@@ -2,9 +2,12 @@ package profile
 
 import (
 	"errors"
+	"fmt"
 	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
 	"regexp"
+	"text/template"
 )
 
 type Profile struct {
@@ -12,22 +15,64 @@ type Profile struct {
 	Name             string
 	Description      string
 	Kernel           string
-	Initrd           string
+	Initrds          Initrds
 	KernelParameters kernelparameters.KernelParameters
+	// Template is an optional text/template iPXE script that is used instead of the default script when rendering the PxeConfig.
+	Template string
+	// NetworkStyle determines which kernel parameters are generated from the network configuration of the system that boots the profile.
+	NetworkStyle NetworkStyle
+	// Parent is the profile that this profile inherits from, or uuid.Nil if it does not have one.
+	// Empty fields are inherited from the parent, and the kernel parameters are merged with the ones of the parent (see Resolve).
+	Parent uuid.UUID
+	// Metadata is user-defined data that is available as variables in the kernel, initrds and kernel parameters, e.g. '${os_version}'.
+	Metadata variables.Metadata
+	// ConfigTemplates are the IDs of the config templates, e.g. kickstart files, that are served to systems that boot the profile.
+	ConfigTemplates []uuid.UUID
 }
 
-func New(id uuid.UUID, name string, description string, kernel string, initrd string, kernelParameters kernelparameters.KernelParameters) (Profile, error) {
+func New(id uuid.UUID, name string, description string, kernel string, initrds Initrds, kernelParameters kernelparameters.KernelParameters, tmpl string, networkStyle NetworkStyle, parent uuid.UUID, metadata variables.Metadata, configTemplates []uuid.UUID) (Profile, error) {
 	var p Profile
 
 	if err := validateName(name); err != nil {
 		return p, err
 	}
 
-	if err := validateKernel(kernel); err != nil {
+	if err := validateParent(id, parent); err != nil {
 		return p, err
 	}
 
-	if err := validateInitrd(initrd); err != nil {
+	// Profiles with a parent can inherit their kernel and initrds from it
+	if parent == uuid.Nil || kernel != "" {
+		if err := validateKernel(kernel); err != nil {
+			return p, err
+		}
+	}
+
+	if parent == uuid.Nil && len(initrds) == 0 {
+		return p, errors.New("profile needs at least one initrd")
+	}
+
+	if err := validateInitrds(initrds); err != nil {
+		return p, err
+	}
+
+	if err := validateVariables(kernel, initrds, kernelParameters); err != nil {
+		return p, err
+	}
+
+	if err := variables.ValidateMetadata(metadata); err != nil {
+		return p, err
+	}
+
+	if err := validateTemplate(tmpl); err != nil {
+		return p, err
+	}
+
+	if err := validateConfigTemplates(configTemplates); err != nil {
+		return p, err
+	}
+
+	if err := validateNetworkStyle(networkStyle); err != nil {
 		return p, err
 	}
 
@@ -36,11 +81,41 @@ func New(id uuid.UUID, name string, description string, kernel string, initrd st
 		Name:             name,
 		Description:      description,
 		Kernel:           kernel,
-		Initrd:           initrd,
+		Initrds:          initrds,
 		KernelParameters: kernelParameters,
+		Template:         tmpl,
+		NetworkStyle:     networkStyle,
+		Parent:           parent,
+		Metadata:         metadata,
+		ConfigTemplates:  configTemplates,
 	}, nil
 }
 
+// Initrd returns the path of the first initrd of the profile, or an empty string if it has none.
+// This keeps templates that were written for profiles with a single initrd working, e.g. 'initrd {{ .Profile.Initrd }}'.
+func (p Profile) Initrd() string {
+	if len(p.Initrds) == 0 {
+		return ""
+	}
+	return p.Initrds[0].Path
+}
+
+// HasConfigTemplate returns whether the config template with the passed ID is attached to the profile.
+func (p Profile) HasConfigTemplate(id uuid.UUID) bool {
+	for _, c := range p.ConfigTemplates {
+		if c == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasParent returns whether the profile inherits from another profile.
+func (p Profile) HasParent() bool {
+	return p.Parent != uuid.Nil
+}
+
 func validateName(name string) error {
 	p := "^[a-zA-Z0-9-_.()]{1,64}$"
 	matched, err := regexp.MatchString(p, name)
@@ -55,6 +130,14 @@ func validateName(name string) error {
 	return nil
 }
 
+func validateParent(id uuid.UUID, parent uuid.UUID) error {
+	if parent != uuid.Nil && parent == id {
+		return ErrInheritanceCycle
+	}
+
+	return nil
+}
+
 func validateKernel(kernel string) error {
 	if kernel == "" {
 		return errors.New("kernel cannot be empty")
@@ -72,18 +155,44 @@ func validateKernel(kernel string) error {
 	return nil
 }
 
-func validateInitrd(initrd string) error {
-	if initrd == "" {
-		return errors.New("initrd cannot be empty")
+// validateVariables checks the variable references in the kernel, the paths of the initrds and the kernel parameters.
+// Whether the referenced variables exist can only be checked when a system boots the profile.
+func validateVariables(kernel string, initrds Initrds, kernelParameters kernelparameters.KernelParameters) error {
+	if err := variables.Validate(kernel); err != nil {
+		return fmt.Errorf("kernel: %w", err)
 	}
 
-	p := "\\s"
-	matched, err := regexp.MatchString(p, initrd)
+	for _, i := range initrds {
+		if err := variables.Validate(i.Path); err != nil {
+			return fmt.Errorf("initrd %s: %w", i.Path, err)
+		}
+	}
+
+	return kernelParameters.ValidateVariables()
+}
+
+func validateTemplate(t string) error {
+	// An empty template means the default template will be used
+	if t == "" {
+		return nil
+	}
+
+	_, err := template.New("").Parse(t)
 	if err != nil {
-		return err
+		return fmt.Errorf("invalid template: %w", err)
 	}
-	if matched {
-		return errors.New("initrd contains illegal characters")
+
+	return nil
+}
+
+// validateConfigTemplates checks that every config template is only attached once.
+func validateConfigTemplates(configTemplates []uuid.UUID) error {
+	seen := make(map[uuid.UUID]bool)
+	for _, id := range configTemplates {
+		if seen[id] {
+			return errors.New("config template " + id.String() + " is attached more than once")
+		}
+		seen[id] = true
 	}
 
 	return nil
@@ -13,47 +13,71 @@ func TestNewProfile(t *testing.T) {
 		Name:             "TestProfile",
 		Description:      "",
 		Kernel:           "kernel",
-		Initrd:           "initrd",
+		Initrds:          Initrds{{Path: "initrd"}},
 		KernelParameters: kernelparameters.KernelParameters{},
+		Template:         "",
 	}
 
-	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", "initrd", kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
 	if err != nil || !reflect.DeepEqual(actual, expected) {
 		t.Fatalf(`New() = %v, %v, expected: %v, nil`, actual, err, expected)
 	}
 }
 
 func TestNewProfileInvalidName(t *testing.T) {
-	actual, err := New(uuid.Nil, "invalid name", "", "kernel", "initrd", kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "invalid name", "", "kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
 	if err == nil {
 		t.Fatalf(`Expected New() to return invalid name error, got: %v, %v`, actual, err)
 	}
 }
 
 func TestNewProfileInvalidKernel(t *testing.T) {
-	actual, err := New(uuid.Nil, "TestProfile", "", "invalid kernel", "initrd", kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "TestProfile", "", "invalid kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
 	if err == nil {
 		t.Fatalf(`Expected New() to return invalid kernel error, got: %v, %v`, actual, err)
 	}
 }
 
 func TestNewProfileEmptyKernel(t *testing.T) {
-	actual, err := New(uuid.Nil, "TestProfile", "", "", "initrd", kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "TestProfile", "", "", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
 	if err == nil {
 		t.Fatalf(`Expected New() to return empty kernel error, got: %v, %v`, actual, err)
 	}
 }
 
 func TestNewProfileInvalidInitrd(t *testing.T) {
-	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", "invalid initrd", kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", Initrds{{Path: "invalid initrd"}}, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
 	if err == nil {
 		t.Fatalf(`Expected New() to return invalid initrd error, got: %v, %v`, actual, err)
 	}
 }
 
 func TestNewProfileEmptyInitrd(t *testing.T) {
-	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", "", kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", nil, kernelparameters.KernelParameters{}, "", NetworkStyleNone, uuid.Nil, nil, nil)
 	if err == nil {
 		t.Fatalf(`Expected New() to return empty initrd error, got: %v, %v`, actual, err)
 	}
 }
+
+func TestNewProfileWithTemplate(t *testing.T) {
+	tmpl := "#!ipxe\nkernel {{ .Profile.Kernel }}\nboot\n"
+
+	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, tmpl, NetworkStyleNone, uuid.Nil, nil, nil)
+	if err != nil || actual.Template != tmpl {
+		t.Fatalf(`New() = %v, %v, expected template: %v, nil`, actual, err, tmpl)
+	}
+}
+
+func TestNewProfileInvalidTemplate(t *testing.T) {
+	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "{{ .Profile.Kernel ", NetworkStyleNone, uuid.Nil, nil, nil)
+	if err == nil {
+		t.Fatalf(`Expected New() to return invalid template error, got: %v, %v`, actual, err)
+	}
+}
+
+func TestNewProfileInvalidNetworkStyle(t *testing.T) {
+	actual, err := New(uuid.Nil, "TestProfile", "", "kernel", Initrds{{Path: "initrd"}}, kernelparameters.KernelParameters{}, "", "kickstart", uuid.Nil, nil, nil)
+	if err == nil {
+		t.Fatalf(`Expected New() to return invalid network style error, got: %v, %v`, actual, err)
+	}
+}
@@ -3,3 +3,6 @@ package repository
 import "errors"
 
 var ErrNotFound = errors.New("the requested resource does not exist")
+
+// ErrConflict is returned when a resource cannot be stored because it conflicts with another one, e.g. when a unique value is already in use.
+var ErrConflict = errors.New("the resource conflicts with an existing one")
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type ApiTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewApiTokenRepository(db *pgxpool.Pool) (ApiTokenRepository, error) {
+	return ApiTokenRepository{db: db}, nil
+}
+
+type postgresApiToken struct {
+	Id         uint
+	UUID       uuid.UUID
+	ApiUser    uuid.UUID
+	Name       string
+	Hash       string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+}
+
+// apiTokenColumns are the columns that are selected for every API token, in the order of postgresApiToken.scanTargets.
+const apiTokenColumns = "id, uuid, api_user, name, hash, createdAt, expiresAt, lastUsedAt"
+
+// scanTargets returns the fields of the postgresApiToken to scan the apiTokenColumns into.
+func (pt *postgresApiToken) scanTargets() []any {
+	return []any{&pt.Id, &pt.UUID, &pt.ApiUser, &pt.Name, &pt.Hash, &pt.CreatedAt, &pt.ExpiresAt, &pt.LastUsedAt}
+}
+
+// toApiToken converts the postgresApiToken into an auth.ApiToken.
+func (pt postgresApiToken) toApiToken() (auth.ApiToken, error) {
+	var expiresAt time.Time
+	if pt.ExpiresAt != nil {
+		expiresAt = *pt.ExpiresAt
+	}
+
+	t, err := auth.NewApiToken(pt.UUID, pt.ApiUser, pt.Name, pt.Hash, pt.CreatedAt, expiresAt)
+	if err != nil {
+		return t, err
+	}
+
+	if pt.LastUsedAt != nil {
+		t.LastUsedAt = *pt.LastUsedAt
+	}
+
+	return t, nil
+}
+
+func (r ApiTokenRepository) GetApiTokensByUserId(userId uuid.UUID) ([]auth.ApiToken, error) {
+	var tokens []auth.ApiToken
+
+	stmt := "SELECT " + apiTokenColumns + " FROM api_token WHERE api_user = $1 ORDER BY createdAt"
+	rows, err := r.db.Query(context.Background(), stmt, userId)
+	if err != nil {
+		return tokens, err
+	}
+
+	for rows.Next() {
+		var pt postgresApiToken
+
+		err = rows.Scan(pt.scanTargets()...)
+		if err != nil {
+			return tokens, err
+		}
+
+		t, err := pt.toApiToken()
+		if err != nil {
+			return tokens, err
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	return tokens, nil
+}
+
+func (r ApiTokenRepository) GetApiTokenById(id uuid.UUID) (auth.ApiToken, error) {
+	return r.getApiToken("SELECT "+apiTokenColumns+" FROM api_token WHERE uuid = $1", id)
+}
+
+func (r ApiTokenRepository) GetApiTokenByHash(hash string) (auth.ApiToken, error) {
+	return r.getApiToken("SELECT "+apiTokenColumns+" FROM api_token WHERE hash = $1", hash)
+}
+
+// getApiToken returns the single API token that is selected by stmt.
+func (r ApiTokenRepository) getApiToken(stmt string, args ...any) (auth.ApiToken, error) {
+	var pt postgresApiToken
+
+	err := r.db.QueryRow(context.Background(), stmt, args...).Scan(pt.scanTargets()...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return auth.ApiToken{}, repository.ErrNotFound
+		}
+		return auth.ApiToken{}, err
+	}
+
+	return pt.toApiToken()
+}
+
+func (r ApiTokenRepository) SetApiToken(t auth.ApiToken) error {
+	var expiresAt *time.Time
+	if !t.ExpiresAt.IsZero() {
+		expiresAt = &t.ExpiresAt
+	}
+
+	stmt := "INSERT INTO api_token (uuid, api_user, name, hash, createdAt, expiresAt) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (uuid) DO UPDATE SET name = $3, expiresAt = $6"
+	_, err := r.db.Exec(context.Background(), stmt, t.Id, t.UserId, t.Name, t.Hash, t.CreatedAt, expiresAt)
+	return err
+}
+
+func (r ApiTokenRepository) SetApiTokenLastUsed(id uuid.UUID, lastUsedAt time.Time) error {
+	stmt := "UPDATE api_token SET lastUsedAt = $2 WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id, lastUsedAt)
+	return err
+}
+
+func (r ApiTokenRepository) DeleteApiTokenById(id uuid.UUID) error {
+	stmt := "DELETE FROM api_token WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id)
+	return err
+}
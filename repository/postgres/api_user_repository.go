@@ -23,12 +23,13 @@ type postgresApiUser struct {
 	UUID     uuid.UUID
 	Name     string
 	Password []byte
+	Role     string
 }
 
 func (r ApiUserRepository) GetApiUsers() ([]auth.ApiUser, error) {
 	var users []auth.ApiUser
 
-	stmt := "SELECT id, uuid, name, password FROM api_user"
+	stmt := "SELECT id, uuid, name, password, role FROM api_user"
 	rows, err := r.db.Query(context.Background(), stmt)
 	if err != nil {
 		return users, err
@@ -38,12 +39,12 @@ func (r ApiUserRepository) GetApiUsers() ([]auth.ApiUser, error) {
 		var u auth.ApiUser
 		var pu postgresApiUser
 
-		err = rows.Scan(&pu.Id, &pu.UUID, &pu.Name, &pu.Password)
+		err = rows.Scan(&pu.Id, &pu.UUID, &pu.Name, &pu.Password, &pu.Role)
 		if err != nil {
 			return users, err
 		}
 
-		u = auth.NewApiUser(pu.UUID, pu.Name, pu.Password)
+		u = auth.NewApiUser(pu.UUID, pu.Name, pu.Password, auth.Role(pu.Role))
 		users = append(users, u)
 	}
 
@@ -54,8 +55,8 @@ func (r ApiUserRepository) GetApiUserById(id uuid.UUID) (auth.ApiUser, error) {
 	var a auth.ApiUser
 	var pa postgresApiUser
 
-	stmt := "SELECT id, uuid, name, password FROM api_user WHERE uuid = $1"
-	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&pa.Id, &pa.UUID, &pa.Name, &pa.Password)
+	stmt := "SELECT id, uuid, name, password, role FROM api_user WHERE uuid = $1"
+	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&pa.Id, &pa.UUID, &pa.Name, &pa.Password, &pa.Role)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return a, repository.ErrNotFound
@@ -63,15 +64,15 @@ func (r ApiUserRepository) GetApiUserById(id uuid.UUID) (auth.ApiUser, error) {
 		return a, err
 	}
 
-	return auth.NewApiUser(pa.UUID, pa.Name, pa.Password), nil
+	return auth.NewApiUser(pa.UUID, pa.Name, pa.Password, auth.Role(pa.Role)), nil
 }
 
 func (r ApiUserRepository) GetApiUserByName(name string) (auth.ApiUser, error) {
 	var a auth.ApiUser
 	var pa postgresApiUser
 
-	stmt := "SELECT id, uuid, name, password FROM api_user WHERE name = $1"
-	err := r.db.QueryRow(context.Background(), stmt, name).Scan(&pa.Id, &pa.UUID, &pa.Name, &pa.Password)
+	stmt := "SELECT id, uuid, name, password, role FROM api_user WHERE name = $1"
+	err := r.db.QueryRow(context.Background(), stmt, name).Scan(&pa.Id, &pa.UUID, &pa.Name, &pa.Password, &pa.Role)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return a, repository.ErrNotFound
@@ -79,12 +80,12 @@ func (r ApiUserRepository) GetApiUserByName(name string) (auth.ApiUser, error) {
 		return a, err
 	}
 
-	return auth.NewApiUser(pa.UUID, pa.Name, pa.Password), nil
+	return auth.NewApiUser(pa.UUID, pa.Name, pa.Password, auth.Role(pa.Role)), nil
 }
 
 func (r ApiUserRepository) SetApiUser(a auth.ApiUser) error {
-	stmt := "INSERT INTO api_user (uuid, name, password) VALUES ($1, $2, $3) ON CONFLICT (uuid) DO UPDATE SET name = $2, password = $3"
-	_, err := r.db.Exec(context.Background(), stmt, a.Id, a.Name, a.Password)
+	stmt := "INSERT INTO api_user (uuid, name, password, role) VALUES ($1, $2, $3, $4) ON CONFLICT (uuid) DO UPDATE SET name = $2, password = $3, role = $4"
+	_, err := r.db.Exec(context.Background(), stmt, a.Id, a.Name, a.Password, string(a.Role))
 	return err
 }
 
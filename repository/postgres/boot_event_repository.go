@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"context"
+	"github.com/evanebb/gobble/bootevent"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"net"
+	"time"
+)
+
+type BootEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewBootEventRepository(db *pgxpool.Pool) (BootEventRepository, error) {
+	return BootEventRepository{db: db}, nil
+}
+
+type postgresBootEvent struct {
+	Id         uint
+	UUID       uuid.UUID
+	Time       time.Time
+	Mac        net.HardwareAddr
+	System     *uuid.UUID
+	ClientIp   net.IP
+	UserAgent  string
+	Result     string
+	Profile    *uuid.UUID
+	BootMode   string
+	ScriptHash string
+}
+
+func (r BootEventRepository) GetBootEventsBySystemId(id uuid.UUID, limit int) ([]bootevent.BootEvent, error) {
+	var events []bootevent.BootEvent
+
+	stmt := "SELECT id, uuid, time, mac, system, clientIp, userAgent, result, profile, bootMode, scriptHash FROM boot_event WHERE system = $1 ORDER BY time DESC LIMIT $2"
+	rows, err := r.db.Query(context.Background(), stmt, id, limit)
+	if err != nil {
+		return events, err
+	}
+
+	for rows.Next() {
+		var pe postgresBootEvent
+
+		err = rows.Scan(&pe.Id, &pe.UUID, &pe.Time, &pe.Mac, &pe.System, &pe.ClientIp, &pe.UserAgent, &pe.Result, &pe.Profile, &pe.BootMode, &pe.ScriptHash)
+		if err != nil {
+			return events, err
+		}
+
+		system := uuid.Nil
+		if pe.System != nil {
+			system = *pe.System
+		}
+
+		profile := uuid.Nil
+		if pe.Profile != nil {
+			profile = *pe.Profile
+		}
+
+		events = append(events, bootevent.New(pe.UUID, pe.Time, pe.Mac, system, pe.ClientIp, pe.UserAgent, bootevent.Result(pe.Result), profile, pe.BootMode, pe.ScriptHash))
+	}
+
+	return events, nil
+}
+
+func (r BootEventRepository) RecordBootEvent(e bootevent.BootEvent) error {
+	var system *uuid.UUID
+	if e.System != uuid.Nil {
+		system = &e.System
+	}
+
+	var profile *uuid.UUID
+	if e.Profile != uuid.Nil {
+		profile = &e.Profile
+	}
+
+	stmt := "INSERT INTO boot_event (uuid, time, mac, system, clientIp, userAgent, result, profile, bootMode, scriptHash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	_, err := r.db.Exec(context.Background(), stmt, e.Id, e.Time, e.Mac, system, e.ClientIp, e.UserAgent, string(e.Result), profile, e.BootMode, e.ScriptHash)
+	return err
+}
+
+func (r BootEventRepository) DeleteBootEventsBefore(t time.Time) (int64, error) {
+	stmt := "DELETE FROM boot_event WHERE time < $1"
+	tag, err := r.db.Exec(context.Background(), stmt, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
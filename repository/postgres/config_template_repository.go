@@ -0,0 +1,101 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/evanebb/gobble/configtemplate"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ConfigTemplateRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewConfigTemplateRepository(db *pgxpool.Pool) (ConfigTemplateRepository, error) {
+	return ConfigTemplateRepository{db: db}, nil
+}
+
+type postgresConfigTemplate struct {
+	Id          uint
+	UUID        uuid.UUID
+	Name        string
+	Description string
+	Type        string
+	Content     string
+}
+
+func (r ConfigTemplateRepository) GetConfigTemplates() ([]configtemplate.ConfigTemplate, error) {
+	var templates []configtemplate.ConfigTemplate
+
+	stmt := "SELECT id, uuid, name, description, type, content FROM config_template"
+	rows, err := r.db.Query(context.Background(), stmt)
+	if err != nil {
+		return templates, err
+	}
+
+	for rows.Next() {
+		var c configtemplate.ConfigTemplate
+		var pc postgresConfigTemplate
+
+		err = rows.Scan(&pc.Id, &pc.UUID, &pc.Name, &pc.Description, &pc.Type, &pc.Content)
+		if err != nil {
+			return templates, err
+		}
+
+		c, err = configtemplate.New(pc.UUID, pc.Name, pc.Description, configtemplate.Type(pc.Type), pc.Content)
+		if err != nil {
+			return templates, err
+		}
+
+		templates = append(templates, c)
+	}
+
+	return templates, nil
+}
+
+func (r ConfigTemplateRepository) GetConfigTemplateById(id uuid.UUID) (configtemplate.ConfigTemplate, error) {
+	return r.getConfigTemplate("SELECT id, uuid, name, description, type, content FROM config_template WHERE uuid = $1", id)
+}
+
+func (r ConfigTemplateRepository) GetConfigTemplateByName(name string) (configtemplate.ConfigTemplate, error) {
+	return r.getConfigTemplate("SELECT id, uuid, name, description, type, content FROM config_template WHERE name = $1", name)
+}
+
+// getConfigTemplate returns the single config template that is selected by stmt.
+func (r ConfigTemplateRepository) getConfigTemplate(stmt string, args ...any) (configtemplate.ConfigTemplate, error) {
+	var c configtemplate.ConfigTemplate
+	var pc postgresConfigTemplate
+
+	err := r.db.QueryRow(context.Background(), stmt, args...).Scan(&pc.Id, &pc.UUID, &pc.Name, &pc.Description, &pc.Type, &pc.Content)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c, repository.ErrNotFound
+		}
+		return c, err
+	}
+
+	return configtemplate.New(pc.UUID, pc.Name, pc.Description, configtemplate.Type(pc.Type), pc.Content)
+}
+
+func (r ConfigTemplateRepository) SetConfigTemplate(c configtemplate.ConfigTemplate) error {
+	stmt := "INSERT INTO config_template (uuid, name, description, type, content) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (uuid) DO UPDATE set name = $2, description = $3, type = $4, content = $5"
+	_, err := r.db.Exec(context.Background(), stmt, c.Id, c.Name, c.Description, string(c.Type), c.Content)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r ConfigTemplateRepository) DeleteConfigTemplateById(id uuid.UUID) error {
+	stmt := "DELETE FROM config_template WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/evanebb/gobble/discovery"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"net"
+	"time"
+)
+
+type DiscoveryRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDiscoveryRepository(db *pgxpool.Pool) (DiscoveryRepository, error) {
+	return DiscoveryRepository{db: db}, nil
+}
+
+type postgresDiscoveredSystem struct {
+	Id         uint
+	UUID       uuid.UUID
+	Mac        net.HardwareAddr
+	FirstSeen  time.Time
+	LastSeen   time.Time
+	SourceIp   net.IP
+	Vendor     string
+	Serial     string
+	SmbiosUuid string
+}
+
+func (r DiscoveryRepository) GetDiscoveredSystems() ([]discovery.DiscoveredSystem, error) {
+	var discovered []discovery.DiscoveredSystem
+
+	stmt := "SELECT id, uuid, mac, firstSeen, lastSeen, sourceIp, vendor, serial, smbiosUuid FROM discovered_system ORDER BY lastSeen DESC"
+	rows, err := r.db.Query(context.Background(), stmt)
+	if err != nil {
+		return discovered, err
+	}
+
+	for rows.Next() {
+		var pd postgresDiscoveredSystem
+
+		err = rows.Scan(&pd.Id, &pd.UUID, &pd.Mac, &pd.FirstSeen, &pd.LastSeen, &pd.SourceIp, &pd.Vendor, &pd.Serial, &pd.SmbiosUuid)
+		if err != nil {
+			return discovered, err
+		}
+
+		discovered = append(discovered, discovery.New(pd.UUID, pd.Mac, pd.FirstSeen, pd.LastSeen, pd.SourceIp, pd.Vendor, pd.Serial, pd.SmbiosUuid))
+	}
+
+	return discovered, nil
+}
+
+func (r DiscoveryRepository) GetDiscoveredSystemById(id uuid.UUID) (discovery.DiscoveredSystem, error) {
+	var d discovery.DiscoveredSystem
+	var pd postgresDiscoveredSystem
+
+	stmt := "SELECT id, uuid, mac, firstSeen, lastSeen, sourceIp, vendor, serial, smbiosUuid FROM discovered_system WHERE uuid = $1"
+	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&pd.Id, &pd.UUID, &pd.Mac, &pd.FirstSeen, &pd.LastSeen, &pd.SourceIp, &pd.Vendor, &pd.Serial, &pd.SmbiosUuid)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, repository.ErrNotFound
+		}
+		return d, err
+	}
+
+	return discovery.New(pd.UUID, pd.Mac, pd.FirstSeen, pd.LastSeen, pd.SourceIp, pd.Vendor, pd.Serial, pd.SmbiosUuid), nil
+}
+
+func (r DiscoveryRepository) RecordDiscoveredSystem(d discovery.DiscoveredSystem) error {
+	stmt := "UPDATE discovered_system SET lastSeen = $2, sourceIp = $3, vendor = $4, serial = $5, smbiosUuid = $6 WHERE mac = $1"
+	tag, err := r.db.Exec(context.Background(), stmt, d.Mac, d.LastSeen, d.SourceIp, d.Vendor, d.Serial, d.SmbiosUuid)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	// New discovered systems are only inserted as long as the limit has not been reached yet
+	stmt = "INSERT INTO discovered_system (uuid, mac, firstSeen, lastSeen, sourceIp, vendor, serial, smbiosUuid) SELECT $1, $2, $3, $4, $5, $6, $7, $8 WHERE (SELECT count(*) FROM discovered_system) < $9 ON CONFLICT (mac) DO UPDATE SET lastSeen = $4, sourceIp = $5, vendor = $6, serial = $7, smbiosUuid = $8"
+	tag, err = r.db.Exec(context.Background(), stmt, d.Id, d.Mac, d.FirstSeen, d.LastSeen, d.SourceIp, d.Vendor, d.Serial, d.SmbiosUuid, discovery.MaxDiscoveredSystems)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return discovery.ErrLimitReached
+	}
+
+	return nil
+}
+
+func (r DiscoveryRepository) DeleteDiscoveredSystemById(id uuid.UUID) error {
+	stmt := "DELETE FROM discovered_system WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id)
+	return err
+}
+
+func (r DiscoveryRepository) DeleteDiscoveredSystemsLastSeenBefore(t time.Time) (int64, error) {
+	stmt := "DELETE FROM discovered_system WHERE lastSeen < $1"
+	tag, err := r.db.Exec(context.Background(), stmt, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,22 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"github.com/evanebb/gobble/repository"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// uniqueViolation is the SQLSTATE that Postgres returns when a unique constraint is violated.
+const uniqueViolation = "23505"
+
+// mapConflict returns a repository.ErrConflict that describes the conflicting value if err is a unique constraint violation,
+// or err itself otherwise.
+func mapConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s", repository.ErrConflict, pgErr.Detail)
+	}
+
+	return err
+}
@@ -25,14 +25,28 @@ type postgresProfile struct {
 	Name             string
 	Description      string
 	Kernel           string
-	Initrd           string
+	Initrds          []string
 	KernelParameters []string
+	Template         string
+	NetworkStyle     string
+	Parent           *uuid.UUID
+	Metadata         map[string]string
+	ConfigTemplates  []uuid.UUID
+}
+
+// parent returns the parent of the postgresProfile, which is NULL if it does not have one.
+func (pp postgresProfile) parent() uuid.UUID {
+	if pp.Parent == nil {
+		return uuid.Nil
+	}
+
+	return *pp.Parent
 }
 
 func (r ProfileRepository) GetProfiles() ([]profile.Profile, error) {
 	var profiles []profile.Profile
 
-	stmt := "SELECT id, uuid, name, description, kernel, initrd, kernelParameters FROM profile"
+	stmt := "SELECT id, uuid, name, description, kernel, initrds, kernelParameters, template, networkStyle, parent, metadata, ARRAY(SELECT config_template FROM profile_config_template WHERE profile_config_template.profile = profile.uuid ORDER BY position) FROM profile"
 	rows, err := r.db.Query(context.Background(), stmt)
 	if err != nil {
 		return profiles, err
@@ -42,7 +56,7 @@ func (r ProfileRepository) GetProfiles() ([]profile.Profile, error) {
 		var pr profile.Profile
 		var pp postgresProfile
 
-		err = rows.Scan(&pp.Id, &pp.UUID, &pp.Name, &pp.Description, &pp.Kernel, &pp.Initrd, &pp.KernelParameters)
+		err = rows.Scan(&pp.Id, &pp.UUID, &pp.Name, &pp.Description, &pp.Kernel, &pp.Initrds, &pp.KernelParameters, &pp.Template, &pp.NetworkStyle, &pp.Parent, &pp.Metadata, &pp.ConfigTemplates)
 		if err != nil {
 			return profiles, err
 		}
@@ -52,7 +66,12 @@ func (r ProfileRepository) GetProfiles() ([]profile.Profile, error) {
 			return profiles, err
 		}
 
-		pr, err = profile.New(pp.UUID, pp.Name, pp.Description, pp.Kernel, pp.Initrd, kp)
+		initrds, err := profile.ParseInitrdsStringSlice(pp.Initrds)
+		if err != nil {
+			return profiles, err
+		}
+
+		pr, err = profile.New(pp.UUID, pp.Name, pp.Description, pp.Kernel, initrds, kp, pp.Template, profile.NetworkStyle(pp.NetworkStyle), pp.parent(), pp.Metadata, pp.ConfigTemplates)
 		if err != nil {
 			return profiles, err
 		}
@@ -67,8 +86,8 @@ func (r ProfileRepository) GetProfileById(id uuid.UUID) (profile.Profile, error)
 	var pr profile.Profile
 	var pp postgresProfile
 
-	stmt := "SELECT id, uuid, name, description, kernel, initrd, kernelParameters FROM profile WHERE uuid = $1"
-	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&pp.Id, &pp.UUID, &pp.Name, &pp.Description, &pp.Kernel, &pp.Initrd, &pp.KernelParameters)
+	stmt := "SELECT id, uuid, name, description, kernel, initrds, kernelParameters, template, networkStyle, parent, metadata, ARRAY(SELECT config_template FROM profile_config_template WHERE profile_config_template.profile = profile.uuid ORDER BY position) FROM profile WHERE uuid = $1"
+	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&pp.Id, &pp.UUID, &pp.Name, &pp.Description, &pp.Kernel, &pp.Initrds, &pp.KernelParameters, &pp.Template, &pp.NetworkStyle, &pp.Parent, &pp.Metadata, &pp.ConfigTemplates)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return pr, repository.ErrNotFound
@@ -82,17 +101,49 @@ func (r ProfileRepository) GetProfileById(id uuid.UUID) (profile.Profile, error)
 		return pr, err
 	}
 
-	return profile.New(pp.UUID, pp.Name, pp.Description, pp.Kernel, pp.Initrd, kp)
+	initrds, err := profile.ParseInitrdsStringSlice(pp.Initrds)
+	if err != nil {
+		return pr, err
+	}
+
+	return profile.New(pp.UUID, pp.Name, pp.Description, pp.Kernel, initrds, kp, pp.Template, profile.NetworkStyle(pp.NetworkStyle), pp.parent(), pp.Metadata, pp.ConfigTemplates)
 }
 
 func (r ProfileRepository) SetProfile(p profile.Profile) error {
-	stmt := "INSERT INTO profile (uuid, name, description, kernel, initrd, kernelParameters) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (uuid) DO UPDATE set name = $2, description = $3, kernel = $4, initrd = $5, kernelParameters = $6"
-	_, err := r.db.Exec(context.Background(), stmt, p.Id, p.Name, p.Description, p.Kernel, p.Initrd, p.KernelParameters.StringSlice())
+	ctx := context.Background()
+
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
+	// This is a no-op if the transaction has been committed
+	defer tx.Rollback(ctx)
 
-	return nil
+	var parent *uuid.UUID
+	if p.Parent != uuid.Nil {
+		parent = &p.Parent
+	}
+
+	stmt := "INSERT INTO profile (uuid, name, description, kernel, initrds, kernelParameters, template, networkStyle, parent, metadata) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT (uuid) DO UPDATE set name = $2, description = $3, kernel = $4, initrds = $5, kernelParameters = $6, template = $7, networkStyle = $8, parent = $9, metadata = $10"
+	_, err = tx.Exec(ctx, stmt, p.Id, p.Name, p.Description, p.Kernel, p.Initrds.StringSlice(), p.KernelParameters.StringSlice(), p.Template, string(p.NetworkStyle), parent, toJsonMetadata(p.Metadata))
+	if err != nil {
+		return err
+	}
+
+	// Replace the config templates as a whole, so their order is kept
+	_, err = tx.Exec(ctx, "DELETE FROM profile_config_template WHERE profile = $1", p.Id)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range p.ConfigTemplates {
+		_, err = tx.Exec(ctx, "INSERT INTO profile_config_template (profile, config_template, position) VALUES ($1, $2, $3)", p.Id, c, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r ProfileRepository) DeleteProfileById(id uuid.UUID) error {
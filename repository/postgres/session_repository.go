@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+type SessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) (SessionRepository, error) {
+	return SessionRepository{db: db}, nil
+}
+
+// postgresSession is a session as stored in the database. Sessions of local users reference the user in ApiUser,
+// while sessions of external users store their ID, name and role in the External columns.
+type postgresSession struct {
+	Id           uint
+	UUID         uuid.UUID
+	ApiUser      *uuid.UUID
+	ExternalUser *uuid.UUID
+	ExternalName *string
+	ExternalRole *string
+	Hash         string
+	CsrfToken    string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
+func (r SessionRepository) GetSessionByHash(hash string) (auth.Session, error) {
+	var ps postgresSession
+
+	stmt := "SELECT id, uuid, api_user, externalUser, externalName, externalRole, hash, csrfToken, createdAt, expiresAt FROM session WHERE hash = $1"
+	err := r.db.QueryRow(context.Background(), stmt, hash).Scan(&ps.Id, &ps.UUID, &ps.ApiUser, &ps.ExternalUser, &ps.ExternalName, &ps.ExternalRole, &ps.Hash, &ps.CsrfToken, &ps.CreatedAt, &ps.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return auth.Session{}, repository.ErrNotFound
+		}
+		return auth.Session{}, err
+	}
+
+	if ps.ApiUser != nil {
+		return auth.NewSession(ps.UUID, *ps.ApiUser, ps.Hash, ps.CsrfToken, ps.CreatedAt, ps.ExpiresAt), nil
+	}
+
+	if ps.ExternalUser == nil || ps.ExternalName == nil || ps.ExternalRole == nil {
+		return auth.Session{}, errors.New("session has no user")
+	}
+
+	role, err := auth.ParseRole(*ps.ExternalRole)
+	if err != nil {
+		return auth.Session{}, err
+	}
+
+	s := auth.NewSession(ps.UUID, *ps.ExternalUser, ps.Hash, ps.CsrfToken, ps.CreatedAt, ps.ExpiresAt)
+	s.External = true
+	s.UserName = *ps.ExternalName
+	s.Role = role
+	return s, nil
+}
+
+func (r SessionRepository) SetSession(s auth.Session) error {
+	var ps postgresSession
+	if s.External {
+		role := string(s.Role)
+		ps.ExternalUser = &s.UserId
+		ps.ExternalName = &s.UserName
+		ps.ExternalRole = &role
+	} else {
+		ps.ApiUser = &s.UserId
+	}
+
+	stmt := "INSERT INTO session (uuid, api_user, externalUser, externalName, externalRole, hash, csrfToken, createdAt, expiresAt) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT (uuid) DO UPDATE SET expiresAt = $9"
+	_, err := r.db.Exec(context.Background(), stmt, s.Id, ps.ApiUser, ps.ExternalUser, ps.ExternalName, ps.ExternalRole, s.Hash, s.CsrfToken, s.CreatedAt, s.ExpiresAt)
+	return err
+}
+
+func (r SessionRepository) DeleteSessionById(id uuid.UUID) error {
+	stmt := "DELETE FROM session WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id)
+	return err
+}
+
+func (r SessionRepository) DeleteSessionsExpiredBefore(t time.Time) (int64, error) {
+	stmt := "DELETE FROM session WHERE expiresAt < $1"
+	tag, err := r.db.Exec(context.Background(), stmt, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
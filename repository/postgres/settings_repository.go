@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/evanebb/gobble/settings"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SettingsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSettingsRepository(db *pgxpool.Pool) (SettingsRepository, error) {
+	return SettingsRepository{db: db}, nil
+}
+
+type postgresSettings struct {
+	DefaultProfile         *uuid.UUID
+	DiscoveryEnabled       bool
+	BootEventRetentionDays uint
+}
+
+// The settings are stored as a single row with a fixed ID
+const settingsId = 1
+
+func (r SettingsRepository) GetSettings() (settings.Settings, error) {
+	var ps postgresSettings
+
+	stmt := "SELECT defaultProfile, discoveryEnabled, bootEventRetentionDays FROM settings WHERE id = $1"
+	err := r.db.QueryRow(context.Background(), stmt, settingsId).Scan(&ps.DefaultProfile, &ps.DiscoveryEnabled, &ps.BootEventRetentionDays)
+	if err != nil {
+		// Nothing has been configured yet, so just return the defaults
+		if errors.Is(err, pgx.ErrNoRows) {
+			return settings.New(uuid.Nil, false, settings.DefaultBootEventRetentionDays), nil
+		}
+		return settings.Settings{}, err
+	}
+
+	defaultProfile := uuid.Nil
+	if ps.DefaultProfile != nil {
+		defaultProfile = *ps.DefaultProfile
+	}
+
+	return settings.New(defaultProfile, ps.DiscoveryEnabled, ps.BootEventRetentionDays), nil
+}
+
+func (r SettingsRepository) SetSettings(s settings.Settings) error {
+	var defaultProfile *uuid.UUID
+	if s.DefaultProfile != uuid.Nil {
+		defaultProfile = &s.DefaultProfile
+	}
+
+	stmt := "INSERT INTO settings (id, defaultProfile, discoveryEnabled, bootEventRetentionDays) VALUES ($1, $2, $3, $4) ON CONFLICT (id) DO UPDATE SET defaultProfile = $2, discoveryEnabled = $3, bootEventRetentionDays = $4"
+	_, err := r.db.Exec(context.Background(), stmt, settingsId, defaultProfile, s.DiscoveryEnabled, s.BootEventRetentionDays)
+	return err
+}
@@ -6,10 +6,13 @@ import (
 	"github.com/evanebb/gobble/kernelparameters"
 	"github.com/evanebb/gobble/repository"
 	"github.com/evanebb/gobble/system"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"net"
+	"strings"
+	"time"
 )
 
 type SystemRepository struct {
@@ -21,39 +24,218 @@ func NewSystemRepository(db *pgxpool.Pool) (SystemRepository, error) {
 }
 
 type postgresSystem struct {
-	Id               uint
-	UUID             uuid.UUID
-	Name             string
-	Description      string
-	Profile          uuid.UUID
-	Mac              net.HardwareAddr
-	KernelParameters []string
+	Id                       uint
+	UUID                     uuid.UUID
+	Name                     string
+	Description              string
+	Profile                  uuid.UUID
+	Interfaces               []postgresInterface
+	SmbiosUuid               *uuid.UUID
+	Serial                   *string
+	AssetTag                 *string
+	Hostname                 *string
+	KernelParameters         []string
+	MenuProfiles             []uuid.UUID
+	MenuTimeout              uint
+	BootMode                 string
+	InstalledAt              *time.Time
+	NextBoot                 bool
+	NextBootProfile          *uuid.UUID
+	NextBootKernelParameters []string
+	Metadata                 map[string]string
+}
+
+// systemColumns are the columns that are selected for every system, in the order of postgresSystem.scanTargets.
+// The interfaces and menu profiles are stored separately in the system_interface and system_profile tables.
+const systemColumns = "id, uuid, name, description, profile, " +
+	"COALESCE((SELECT json_agg(json_build_object('mac', mac, 'name', name, 'boot', boot, 'address', address, 'gateway', gateway, 'nameservers', nameservers, 'hostname', hostname) ORDER BY position) FROM system_interface WHERE system_interface.system = system.uuid), '[]'), " +
+	"smbiosUuid, serial, assetTag, hostname, kernelParameters, ARRAY(SELECT profile FROM system_profile WHERE system_profile.system = system.uuid ORDER BY position), menuTimeout, bootMode, installedAt, nextBoot, nextBootProfile, nextBootKernelParameters, metadata"
+
+// scanTargets returns the fields of the postgresSystem to scan the systemColumns into.
+func (ps *postgresSystem) scanTargets() []any {
+	return []any{&ps.Id, &ps.UUID, &ps.Name, &ps.Description, &ps.Profile, &ps.Interfaces, &ps.SmbiosUuid, &ps.Serial, &ps.AssetTag, &ps.Hostname, &ps.KernelParameters, &ps.MenuProfiles, &ps.MenuTimeout, &ps.BootMode, &ps.InstalledAt, &ps.NextBoot, &ps.NextBootProfile, &ps.NextBootKernelParameters, &ps.Metadata}
+}
+
+// toSystem converts the postgresSystem into a system.System.
+func (ps postgresSystem) toSystem() (system.System, error) {
+	// If this errors someone directly inserted garbage into the database :(
+	kp, err := kernelparameters.ParseStringSlice(ps.KernelParameters)
+	if err != nil {
+		return system.System{}, err
+	}
+
+	identifiers := system.Identifiers{
+		Serial:   fromNullString(ps.Serial),
+		AssetTag: fromNullString(ps.AssetTag),
+		Hostname: fromNullString(ps.Hostname),
+	}
+	for _, pi := range ps.Interfaces {
+		i, err := pi.toInterface()
+		if err != nil {
+			return system.System{}, err
+		}
+		identifiers.Interfaces = append(identifiers.Interfaces, i)
+	}
+	if ps.SmbiosUuid != nil {
+		identifiers.SmbiosUuid = *ps.SmbiosUuid
+	}
+
+	sys, err := system.New(ps.UUID, ps.Name, ps.Description, ps.Profile, identifiers, kp, ps.MenuProfiles, ps.MenuTimeout, system.BootMode(ps.BootMode), ps.Metadata)
+	if err != nil {
+		return sys, err
+	}
+
+	if ps.InstalledAt != nil {
+		sys.InstalledAt = *ps.InstalledAt
+	}
+
+	if ps.NextBoot {
+		n, err := newNextBootFromPostgres(ps.NextBootProfile, ps.NextBootKernelParameters)
+		if err != nil {
+			return sys, err
+		}
+		sys.NextBoot = &n
+	}
+
+	return sys, nil
+}
+
+// postgresInterface is a row of the system_interface table, which is selected as JSON together with the system it belongs to.
+type postgresInterface struct {
+	Mac         string   `json:"mac"`
+	Name        string   `json:"name"`
+	Boot        bool     `json:"boot"`
+	Address     *string  `json:"address"`
+	Gateway     *string  `json:"gateway"`
+	Nameservers []string `json:"nameservers"`
+	Hostname    string   `json:"hostname"`
+}
+
+// toInterface converts the postgresInterface into a system.Interface.
+func (pi postgresInterface) toInterface() (system.Interface, error) {
+	var i system.Interface
+	var err error
+
+	i.Mac, err = net.ParseMAC(pi.Mac)
+	if err != nil {
+		return i, err
+	}
+
+	i.Name = pi.Name
+	i.Boot = pi.Boot
+	i.Network.Hostname = pi.Hostname
+
+	// Postgres leaves out the prefix length of inet values that are a single host, e.g. the gateway
+	if pi.Address != nil {
+		i.Network.Address, i.Network.Netmask, err = parseInet(*pi.Address)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	if pi.Gateway != nil {
+		i.Network.Gateway, _, err = parseInet(*pi.Gateway)
+		if err != nil {
+			return i, err
+		}
+	}
+
+	for _, ns := range pi.Nameservers {
+		ip, _, err := parseInet(ns)
+		if err != nil {
+			return i, err
+		}
+		i.Network.Nameservers = append(i.Network.Nameservers, ip)
+	}
+
+	return i, nil
+}
+
+// parseInet parses the text representation of a Postgres inet value into the address and its netmask.
+func parseInet(s string) (net.IP, net.IPMask, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, nil, errors.New("invalid inet value " + s)
+		}
+
+		if ip.To4() != nil {
+			return ip, net.CIDRMask(32, 32), nil
+		}
+		return ip, net.CIDRMask(128, 128), nil
+	}
+
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ip, ipNet.Mask, nil
+}
+
+// newNextBootFromPostgres converts the stored next boot columns of a system into a system.NextBoot.
+func newNextBootFromPostgres(profile *uuid.UUID, kernelParameters []string) (system.NextBoot, error) {
+	var n system.NextBoot
+
+	kp, err := kernelparameters.ParseStringSlice(kernelParameters)
+	if err != nil {
+		return n, err
+	}
+
+	// The profile is set to NULL when it is deleted, in which case the profile of the system is booted instead
+	n.KernelParameters = kp
+	if profile != nil {
+		n.Profile = *profile
+	}
+
+	return n, nil
+}
+
+// toNullString converts an empty string to NULL, so optional unique columns can be left empty for multiple systems.
+func toNullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+// fromNullString converts a NULL column to an empty string.
+func fromNullString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+// toJsonMetadata converts nil metadata to an empty map, so it is stored as an empty JSON object instead of null.
+func toJsonMetadata(m variables.Metadata) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+
+	return m
 }
 
 func (r SystemRepository) GetSystems() ([]system.System, error) {
 	var systems []system.System
 
-	stmt := "SELECT id, uuid, name, description, profile, mac, kernelParameters FROM system"
+	stmt := "SELECT " + systemColumns + " FROM system"
 	rows, err := r.db.Query(context.Background(), stmt)
 	if err != nil {
 		return systems, err
 	}
 
 	for rows.Next() {
-		var sys system.System
 		var ps postgresSystem
 
-		err = rows.Scan(&ps.Id, &ps.UUID, &ps.Name, &ps.Description, &ps.Profile, &ps.Mac, &ps.KernelParameters)
+		err = rows.Scan(ps.scanTargets()...)
 		if err != nil {
 			return systems, err
 		}
 
-		kp, err := kernelparameters.ParseStringSlice(ps.KernelParameters)
-		if err != nil {
-			return systems, err
-		}
-
-		sys, err = system.New(ps.UUID, ps.Name, ps.Description, ps.Profile, ps.Mac, kp)
+		sys, err := ps.toSystem()
 		if err != nil {
 			return systems, err
 		}
@@ -64,12 +246,13 @@ func (r SystemRepository) GetSystems() ([]system.System, error) {
 	return systems, nil
 }
 
-func (r SystemRepository) GetSystemByMacAddress(mac net.HardwareAddr) (system.System, error) {
+// getSystemWhere returns the single system matching the passed condition, which can refer to the argument as $1.
+func (r SystemRepository) getSystemWhere(condition string, arg any) (system.System, error) {
 	var sys system.System
 	var ps postgresSystem
 
-	stmt := "SELECT id, uuid, name, description, profile, mac, kernelParameters FROM system WHERE mac = $1"
-	err := r.db.QueryRow(context.Background(), stmt, mac).Scan(&ps.Id, &ps.UUID, &ps.Name, &ps.Description, &ps.Profile, &ps.Mac, &ps.KernelParameters)
+	stmt := "SELECT " + systemColumns + " FROM system WHERE " + condition
+	err := r.db.QueryRow(context.Background(), stmt, arg).Scan(ps.scanTargets()...)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return sys, repository.ErrNotFound
@@ -77,40 +260,92 @@ func (r SystemRepository) GetSystemByMacAddress(mac net.HardwareAddr) (system.Sy
 		return sys, err
 	}
 
-	// If this errors someone directly inserted garbage into the database :(
-	kp, err := kernelparameters.ParseStringSlice(ps.KernelParameters)
-	if err != nil {
-		return sys, err
-	}
+	return ps.toSystem()
+}
+
+func (r SystemRepository) GetSystemByMacAddress(mac net.HardwareAddr) (system.System, error) {
+	return r.getSystemWhere("uuid = (SELECT system FROM system_interface WHERE mac = $1)", mac)
+}
+
+func (r SystemRepository) GetSystemBySmbiosUuid(smbiosUuid uuid.UUID) (system.System, error) {
+	return r.getSystemWhere("smbiosUuid = $1", smbiosUuid)
+}
 
-	return system.New(ps.UUID, ps.Name, ps.Description, ps.Profile, ps.Mac, kp)
+func (r SystemRepository) GetSystemBySerial(serial string) (system.System, error) {
+	return r.getSystemWhere("serial = $1", serial)
+}
+
+func (r SystemRepository) GetSystemByAssetTag(assetTag string) (system.System, error) {
+	return r.getSystemWhere("assetTag = $1", assetTag)
+}
+
+func (r SystemRepository) GetSystemByHostname(hostname string) (system.System, error) {
+	return r.getSystemWhere("hostname = $1", hostname)
 }
 
 func (r SystemRepository) GetSystemById(id uuid.UUID) (system.System, error) {
-	var sys system.System
-	var ps postgresSystem
+	return r.getSystemWhere("uuid = $1", id)
+}
+
+func (r SystemRepository) SetSystem(s system.System) error {
+	ctx := context.Background()
 
-	stmt := "SELECT id, uuid, name, description, profile, mac, kernelParameters FROM system WHERE uuid = $1"
-	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&ps.Id, &ps.UUID, &ps.Name, &ps.Description, &ps.Profile, &ps.Mac, &ps.KernelParameters)
+	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return sys, repository.ErrNotFound
+		return err
+	}
+	// This is a no-op if the transaction has been committed
+	defer tx.Rollback(ctx)
+
+	var smbiosUuid *uuid.UUID
+	if s.Identifiers.SmbiosUuid != uuid.Nil {
+		smbiosUuid = &s.Identifiers.SmbiosUuid
+	}
+
+	stmt := "INSERT INTO system (uuid, name, description, profile, smbiosUuid, serial, assetTag, hostname, kernelParameters, menuTimeout, bootMode, metadata) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) ON CONFLICT (uuid) DO UPDATE set name = $2, description = $3, profile = $4, smbiosUuid = $5, serial = $6, assetTag = $7, hostname = $8, kernelParameters = $9, menuTimeout = $10, bootMode = $11, metadata = $12"
+	_, err = tx.Exec(ctx, stmt, s.Id, s.Name, s.Description, s.Profile, smbiosUuid, toNullString(s.Identifiers.Serial), toNullString(s.Identifiers.AssetTag), toNullString(s.Identifiers.Hostname), s.KernelParameters.StringSlice(), s.MenuTimeout, string(s.BootMode), toJsonMetadata(s.Metadata))
+	if err != nil {
+		return mapConflict(err)
+	}
+
+	// Replace the interfaces as a whole, so their order is kept
+	_, err = tx.Exec(ctx, "DELETE FROM system_interface WHERE system = $1", s.Id)
+	if err != nil {
+		return err
+	}
+
+	for i, iface := range s.Identifiers.Interfaces {
+		var gateway string
+		if iface.Network.Gateway != nil {
+			gateway = iface.Network.Gateway.String()
+		}
+
+		stmt := "INSERT INTO system_interface (system, mac, name, boot, address, gateway, nameservers, hostname, position) VALUES ($1, $2, $3, $4, NULLIF($5, '')::inet, NULLIF($6, '')::inet, $7::text[]::inet[], $8, $9)"
+		_, err = tx.Exec(ctx, stmt, s.Id, iface.Mac, iface.Name, iface.Boot, iface.Network.AddressString(), gateway, iface.Network.NameserverStrings(), iface.Network.Hostname, i)
+		if err != nil {
+			return mapConflict(err)
 		}
-		return sys, err
 	}
 
-	// If this errors someone directly inserted garbage into the database :(
-	kp, err := kernelparameters.ParseStringSlice(ps.KernelParameters)
+	// Replace the menu profiles as a whole, so their order is kept
+	_, err = tx.Exec(ctx, "DELETE FROM system_profile WHERE system = $1", s.Id)
 	if err != nil {
-		return sys, err
+		return err
 	}
 
-	return system.New(ps.UUID, ps.Name, ps.Description, ps.Profile, ps.Mac, kp)
+	for i, p := range s.MenuProfiles {
+		_, err = tx.Exec(ctx, "INSERT INTO system_profile (system, profile, position) VALUES ($1, $2, $3)", s.Id, p, i)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (r SystemRepository) SetSystem(s system.System) error {
-	stmt := "INSERT INTO system (uuid, name, description, profile, mac, kernelParameters) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (uuid) DO UPDATE set name = $2, description = $3, profile = $4, mac = $5, kernelParameters = $6"
-	_, err := r.db.Exec(context.Background(), stmt, s.Id, s.Name, s.Description, s.Profile, s.Mac, s.KernelParameters.StringSlice())
+func (r SystemRepository) DeleteSystemById(id uuid.UUID) error {
+	stmt := "DELETE FROM system WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id)
 	if err != nil {
 		return err
 	}
@@ -118,8 +353,27 @@ func (r SystemRepository) SetSystem(s system.System) error {
 	return nil
 }
 
-func (r SystemRepository) DeleteSystemById(id uuid.UUID) error {
-	stmt := "DELETE FROM system WHERE uuid = $1"
+func (r SystemRepository) SetNextBoot(id uuid.UUID, n system.NextBoot) error {
+	var profile *uuid.UUID
+	if n.Profile != uuid.Nil {
+		profile = &n.Profile
+	}
+
+	stmt := "UPDATE system SET nextBoot = true, nextBootProfile = $2, nextBootKernelParameters = $3 WHERE uuid = $1"
+	tag, err := r.db.Exec(context.Background(), stmt, id, profile, n.KernelParameters.StringSlice())
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r SystemRepository) ClearNextBoot(id uuid.UUID) error {
+	stmt := "UPDATE system SET nextBoot = false, nextBootProfile = NULL, nextBootKernelParameters = NULL WHERE uuid = $1"
 	_, err := r.db.Exec(context.Background(), stmt, id)
 	if err != nil {
 		return err
@@ -127,3 +381,61 @@ func (r SystemRepository) DeleteSystemById(id uuid.UUID) error {
 
 	return nil
 }
+
+func (r SystemRepository) ConsumeNextBoot(id uuid.UUID) (system.NextBoot, error) {
+	var n system.NextBoot
+	var profile *uuid.UUID
+	var kernelParameters []string
+
+	// The row is locked while it is being cleared, so concurrent requests cannot both receive the pending next boot
+	stmt := `UPDATE system SET nextBoot = false, nextBootProfile = NULL, nextBootKernelParameters = NULL
+		FROM (SELECT uuid, nextBootProfile, nextBootKernelParameters FROM system WHERE uuid = $1 AND nextBoot FOR UPDATE) AS old
+		WHERE system.uuid = old.uuid
+		RETURNING old.nextBootProfile, old.nextBootKernelParameters`
+	err := r.db.QueryRow(context.Background(), stmt, id).Scan(&profile, &kernelParameters)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return n, repository.ErrNotFound
+		}
+		return n, err
+	}
+
+	return newNextBootFromPostgres(profile, kernelParameters)
+}
+
+func (r SystemRepository) SetInstallToken(id uuid.UUID, tokenHash string) error {
+	stmt := "UPDATE system SET installToken = $2 WHERE uuid = $1"
+	_, err := r.db.Exec(context.Background(), stmt, id, tokenHash)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r SystemRepository) HasInstallToken(id uuid.UUID, tokenHash string) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT 1 FROM system WHERE uuid = $1 AND installToken = $2)"
+	err := r.db.QueryRow(context.Background(), stmt, id, tokenHash).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (r SystemRepository) CompleteInstall(tokenHash string, at time.Time) (uuid.UUID, error) {
+	var id uuid.UUID
+
+	stmt := "UPDATE system SET bootMode = $2, installedAt = $3, installToken = NULL WHERE installToken = $1 RETURNING uuid"
+	err := r.db.QueryRow(context.Background(), stmt, tokenHash, string(system.BootModeLocalDisk), at).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return id, repository.ErrNotFound
+		}
+		return id, err
+	}
+
+	return id, nil
+}
@@ -3,8 +3,11 @@ package server
 import (
 	"errors"
 	"flag"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/api/auth/oidc"
 	"os"
 	"strconv"
+	"strings"
 )
 
 var ErrIncompleteDatabaseCredentials = errors.New("incomplete or no database credentials supplied")
@@ -19,6 +22,10 @@ type AppConfig struct {
 	httpsCertFile string
 	httpsKeyFile  string
 	listenAddress string
+	externalUrl   string
+	secureCookies bool
+	oidcEnabled   bool
+	oidc          oidc.Config
 }
 
 func NewAppConfig() (AppConfig, error) {
@@ -49,6 +56,17 @@ func NewAppConfig() (AppConfig, error) {
 		a.listenAddress = listenAddress
 	}
 
+	a.externalUrl = os.Getenv("GOBBLE_EXTERNAL_URL")
+
+	a.oidc.IssuerUrl = os.Getenv("GOBBLE_OIDC_ISSUER_URL")
+	a.oidc.ClientId = os.Getenv("GOBBLE_OIDC_CLIENT_ID")
+	a.oidc.ClientSecret = os.Getenv("GOBBLE_OIDC_CLIENT_SECRET")
+	a.oidc.Audience = os.Getenv("GOBBLE_OIDC_AUDIENCE")
+	a.oidc.UsernameClaim = os.Getenv("GOBBLE_OIDC_USERNAME_CLAIM")
+	a.oidc.GroupsClaim = os.Getenv("GOBBLE_OIDC_GROUPS_CLAIM")
+	roleMapping := os.Getenv("GOBBLE_OIDC_ROLE_MAPPING")
+	defaultRole := os.Getenv("GOBBLE_OIDC_DEFAULT_ROLE")
+
 	// Parse command line flags
 	flag.StringVar(&a.dbUser, "db-user", a.dbUser, "the database user")
 	flag.StringVar(&a.dbPass, "db-pass", a.dbPass, "the database password")
@@ -58,6 +76,15 @@ func NewAppConfig() (AppConfig, error) {
 	flag.StringVar(&a.httpsCertFile, "https-cert-file", a.httpsCertFile, "the TLS certificate file to use for HTTPS")
 	flag.StringVar(&a.httpsKeyFile, "https-key-file", a.httpsKeyFile, "the TLS certificate key file to use for HTTPS")
 	flag.StringVar(&a.listenAddress, "listen-address", a.listenAddress, "the address that the application should listen on")
+	flag.StringVar(&a.externalUrl, "external-url", a.externalUrl, "the URL that systems use to reach the application, e.g. http://gobble.example.local; defaults to the URL of the incoming request")
+	flag.StringVar(&a.oidc.IssuerUrl, "oidc-issuer-url", a.oidc.IssuerUrl, "the URL of the OpenID Connect provider to log in with; leave empty to disable OpenID Connect")
+	flag.StringVar(&a.oidc.ClientId, "oidc-client-id", a.oidc.ClientId, "the OpenID Connect client ID")
+	flag.StringVar(&a.oidc.ClientSecret, "oidc-client-secret", a.oidc.ClientSecret, "the OpenID Connect client secret")
+	flag.StringVar(&a.oidc.Audience, "oidc-audience", a.oidc.Audience, "the audience that JWTs for the API must be issued for; defaults to the client ID")
+	flag.StringVar(&a.oidc.UsernameClaim, "oidc-username-claim", a.oidc.UsernameClaim, "the claim that contains the user name; defaults to preferred_username")
+	flag.StringVar(&a.oidc.GroupsClaim, "oidc-groups-claim", a.oidc.GroupsClaim, "the claim that contains the groups of the user; defaults to groups")
+	flag.StringVar(&roleMapping, "oidc-role-mapping", roleMapping, "the roles of OpenID Connect groups, e.g. gobble-admins=admin,gobble-operators=operator")
+	flag.StringVar(&defaultRole, "oidc-default-role", defaultRole, "the role of OpenID Connect users that are not in a mapped group; leave empty to refuse them")
 	flag.Parse()
 
 	if a.dbUser == "" || a.dbPass == "" || a.dbHost == "" || a.dbName == "" {
@@ -80,5 +107,28 @@ func NewAppConfig() (AppConfig, error) {
 		}
 	}
 
+	// Callback URLs are built by appending a path to the external URL
+	a.externalUrl = strings.TrimSuffix(a.externalUrl, "/")
+
+	// OpenID Connect is enabled by configuring an issuer
+	if a.oidc.IssuerUrl != "" {
+		a.oidcEnabled = true
+
+		a.oidc.RoleMapping, err = oidc.ParseRoleMapping(roleMapping)
+		if err != nil {
+			return a, err
+		}
+
+		if defaultRole != "" {
+			a.oidc.DefaultRole, err = auth.ParseRole(defaultRole)
+			if err != nil {
+				return a, err
+			}
+		}
+	}
+
+	// Session cookies should only be sent over HTTPS, either served directly or through a proxy in front of the application
+	a.secureCookies = a.httpsEnabled || strings.HasPrefix(a.externalUrl, "https://")
+
 	return a, nil
 }
@@ -0,0 +1,235 @@
+package api_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+/*
+ * Request and response structures, and their supporting functions
+ */
+
+// tokenRequest is the JSON representation of an auth.ApiToken that is accepted by the API.
+type tokenRequest struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// expiresAt returns the expiry in the tokenRequest, or the zero time if the token should not expire.
+func (req tokenRequest) expiresAt() time.Time {
+	if req.ExpiresAt == nil {
+		return time.Time{}
+	}
+
+	return *req.ExpiresAt
+}
+
+// tokenResponse is the JSON representation of an auth.ApiToken that is returned by the API.
+type tokenResponse struct {
+	Id         uuid.UUID  `json:"id"`
+	User       uuid.UUID  `json:"user"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// newTokenResponse accepts an auth.ApiToken, and casts it into a tokenResponse.
+func newTokenResponse(t auth.ApiToken) tokenResponse {
+	return tokenResponse{
+		Id:         t.Id,
+		User:       t.UserId,
+		Name:       t.Name,
+		CreatedAt:  t.CreatedAt,
+		ExpiresAt:  newNullTimeResponse(t.ExpiresAt),
+		LastUsedAt: newNullTimeResponse(t.LastUsedAt),
+	}
+}
+
+// createdTokenResponse is the JSON representation of a newly created auth.ApiToken, which is the only time the token itself is returned.
+type createdTokenResponse struct {
+	tokenResponse
+	Token string `json:"token"`
+}
+
+// newNullTimeResponse returns the time, or nil if it is the zero time.
+func newNullTimeResponse(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
+
+/*
+ * HTTP handlers
+ */
+
+// ApiTokenHandlerGroup is a group of http.HandlerFunc functions related to API tokens
+type ApiTokenHandlerGroup struct {
+	apiTokenRepo auth.ApiTokenRepository
+	apiUserRepo  auth.ApiUserRepository
+}
+
+func NewApiTokenHandlerGroup(tr auth.ApiTokenRepository, ar auth.ApiUserRepository) ApiTokenHandlerGroup {
+	return ApiTokenHandlerGroup{tr, ar}
+}
+
+// GetOwnTokens returns the API tokens of the authenticated user.
+func (h ApiTokenHandlerGroup) GetOwnTokens(w http.ResponseWriter, r *http.Request) error {
+	u, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		return NewHTTPError(errors.New("no authenticated user"), http.StatusUnauthorized)
+	}
+
+	return h.getTokens(w, u.Id)
+}
+
+// CreateOwnToken creates a new API token for the authenticated user. The token is only returned in this response.
+func (h ApiTokenHandlerGroup) CreateOwnToken(w http.ResponseWriter, r *http.Request) error {
+	u, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		return NewHTTPError(errors.New("no authenticated user"), http.StatusUnauthorized)
+	}
+
+	// Users of an external identity provider are not stored, so tokens cannot belong to them
+	_, err := h.apiUserRepo.GetApiUserById(u.Id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(errors.New("API tokens can only be created for local users"), http.StatusBadRequest)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	var req tokenRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	token, err := auth.GenerateApiToken()
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	t, err := auth.NewApiToken(uuid.New(), u.Id, req.Name, auth.HashApiToken(token), time.Now(), req.expiresAt())
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	// Token names are unique per user, so they can be told apart when listing them
+	tokens, err := h.apiTokenRepo.GetApiTokensByUserId(u.Id)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+	for _, existing := range tokens {
+		if existing.Name == t.Name {
+			return NewHTTPError(errors.New("a token with the name "+t.Name+" already exists"), http.StatusConflict)
+		}
+	}
+
+	err = h.apiTokenRepo.SetApiToken(t)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusCreated, createdTokenResponse{newTokenResponse(t), token})
+}
+
+// DeleteOwnToken revokes an API token of the authenticated user.
+func (h ApiTokenHandlerGroup) DeleteOwnToken(w http.ResponseWriter, r *http.Request) error {
+	u, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		return NewHTTPError(errors.New("no authenticated user"), http.StatusUnauthorized)
+	}
+
+	tokenId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	return h.deleteToken(w, u.Id, tokenId)
+}
+
+// GetUserTokens returns the API tokens of the user with the passed ID.
+func (h ApiTokenHandlerGroup) GetUserTokens(w http.ResponseWriter, r *http.Request) error {
+	userId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	_, err = h.apiUserRepo.GetApiUserById(userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return h.getTokens(w, userId)
+}
+
+// DeleteUserToken revokes an API token of the user with the passed ID.
+func (h ApiTokenHandlerGroup) DeleteUserToken(w http.ResponseWriter, r *http.Request) error {
+	userId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	tokenIdString := chi.URLParam(r, "tokenUuid")
+	tokenId, err := uuid.Parse(tokenIdString)
+	if err != nil {
+		return NewHTTPError(errors.New("["+tokenIdString+"] is not a valid UUID"), http.StatusBadRequest)
+	}
+
+	return h.deleteToken(w, userId, tokenId)
+}
+
+// getTokens responds with the API tokens of the user with the passed ID.
+func (h ApiTokenHandlerGroup) getTokens(w http.ResponseWriter, userId uuid.UUID) error {
+	tokens, err := h.apiTokenRepo.GetApiTokensByUserId(userId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	resp := make([]tokenResponse, 0)
+	for _, t := range tokens {
+		resp = append(resp, newTokenResponse(t))
+	}
+
+	return response.Success(w, http.StatusOK, resp)
+}
+
+// deleteToken revokes the API token with the passed ID, if it belongs to the user with the passed ID.
+func (h ApiTokenHandlerGroup) deleteToken(w http.ResponseWriter, userId uuid.UUID, tokenId uuid.UUID) error {
+	t, err := h.apiTokenRepo.GetApiTokenById(tokenId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	// Tokens of other users are treated as if they do not exist
+	if t.UserId != userId {
+		return NewHTTPError(repository.ErrNotFound, http.StatusNotFound)
+	}
+
+	err = h.apiTokenRepo.DeleteApiTokenById(tokenId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	// No data to return, just pass nil
+	return response.Success(w, http.StatusNoContent, nil)
+}
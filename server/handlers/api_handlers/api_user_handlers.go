@@ -20,12 +20,23 @@ import (
 type userRequest struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// role returns the role in the userRequest, or the passed fallback role if none was passed.
+func (req userRequest) role(fallback auth.Role) (auth.Role, error) {
+	if req.Role == "" {
+		return fallback, nil
+	}
+
+	return auth.ParseRole(req.Role)
 }
 
 // userResponse is the JSON representation of an auth.ApiUser that is returned by the API.
 type userResponse struct {
 	Id   uuid.UUID `json:"id"`
 	Name string    `json:"name"`
+	Role string    `json:"role"`
 }
 
 // newUserResponse accepts an auth.ApiUser, and casts it into a userResponse.
@@ -33,6 +44,7 @@ func newUserResponse(a auth.ApiUser) userResponse {
 	return userResponse{
 		Id:   a.Id,
 		Name: a.Name,
+		Role: string(a.Role),
 	}
 }
 
@@ -92,12 +104,18 @@ func (h ApiUserHandlerGroup) CreateUser(w http.ResponseWriter, r *http.Request)
 
 	userID := uuid.New()
 
+	// New users get the least privileged role, unless another one was passed
+	role, err := req.role(auth.RoleViewer)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
 	pass, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
 	}
 
-	a := auth.NewApiUser(userID, req.Name, pass)
+	a := auth.NewApiUser(userID, req.Name, pass, role)
 	err = h.apiUserRepo.SetApiUser(a)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -121,12 +139,33 @@ func (h ApiUserHandlerGroup) PutUser(w http.ResponseWriter, r *http.Request) err
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	// Existing users keep their current role if none was passed, so leaving it out does not demote them
+	fallback := auth.RoleViewer
+	current, err := h.apiUserRepo.GetApiUserById(userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+	if err == nil {
+		fallback = current.Role
+	}
+
+	role, err := req.role(fallback)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	if role != auth.RoleAdmin {
+		if err := h.checkLastAdmin(userID); err != nil {
+			return err
+		}
+	}
+
 	pass, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
 	}
 
-	a := auth.NewApiUser(userID, req.Name, pass)
+	a := auth.NewApiUser(userID, req.Name, pass, role)
 	err = h.apiUserRepo.SetApiUser(a)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -141,6 +180,10 @@ func (h ApiUserHandlerGroup) DeleteUser(w http.ResponseWriter, r *http.Request)
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	if err := h.checkLastAdmin(userID); err != nil {
+		return err
+	}
+
 	err = h.apiUserRepo.DeleteApiUserById(userID)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -148,3 +191,17 @@ func (h ApiUserHandlerGroup) DeleteUser(w http.ResponseWriter, r *http.Request)
 
 	return response.Success(w, http.StatusNoContent, nil)
 }
+
+// checkLastAdmin returns an error if the user with the passed ID is the last admin, so nobody would be able to manage users anymore.
+func (h ApiUserHandlerGroup) checkLastAdmin(id uuid.UUID) error {
+	isLastAdmin, err := auth.IsLastAdmin(h.apiUserRepo, id)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	if isLastAdmin {
+		return NewHTTPError(auth.ErrLastAdmin, http.StatusConflict)
+	}
+
+	return nil
+}
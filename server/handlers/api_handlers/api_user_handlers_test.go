@@ -0,0 +1,86 @@
+package api_handlers
+
+import (
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// apiUserRepository is a simple in-memory auth.ApiUserRepository for testing.
+type apiUserRepository map[uuid.UUID]auth.ApiUser
+
+func (r apiUserRepository) GetApiUsers() ([]auth.ApiUser, error) {
+	var users []auth.ApiUser
+	for _, a := range r {
+		users = append(users, a)
+	}
+	return users, nil
+}
+
+func (r apiUserRepository) GetApiUserById(id uuid.UUID) (auth.ApiUser, error) {
+	a, ok := r[id]
+	if !ok {
+		return a, repository.ErrNotFound
+	}
+	return a, nil
+}
+
+func (r apiUserRepository) GetApiUserByName(name string) (auth.ApiUser, error) {
+	for _, a := range r {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return auth.ApiUser{}, repository.ErrNotFound
+}
+
+func (r apiUserRepository) SetApiUser(a auth.ApiUser) error {
+	r[a.Id] = a
+	return nil
+}
+
+func (r apiUserRepository) DeleteApiUserById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func TestPutUserRole(t *testing.T) {
+	admin := auth.NewApiUser(uuid.New(), "admin", nil, auth.RoleAdmin)
+	operator := auth.NewApiUser(uuid.New(), "operator", nil, auth.RoleOperator)
+	created := uuid.New()
+	users := apiUserRepository{admin.Id: admin, operator.Id: operator}
+
+	h := NewApiUserHandlerGroup(users)
+	router := chi.NewRouter()
+	router.Put("/api/users/{uuid}", ErrorHandler(h.PutUser))
+
+	cases := []struct {
+		id       uuid.UUID
+		body     string
+		expected auth.Role
+	}{
+		// Existing users keep their role if none is passed
+		{operator.Id, `{"name": "operator", "password": "secret"}`, auth.RoleOperator},
+		{admin.Id, `{"name": "admin", "password": "secret"}`, auth.RoleAdmin},
+		{operator.Id, `{"name": "operator", "password": "secret", "role": "viewer"}`, auth.RoleViewer},
+		// Users that do not exist yet are viewers by default, like for POST
+		{created, `{"name": "new", "password": "secret"}`, auth.RoleViewer},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/users/"+c.id.String(), strings.NewReader(c.body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("PutUser() with %s returned %d: %s", c.body, w.Code, w.Body.String())
+		}
+
+		if actual := users[c.id].Role; actual != c.expected {
+			t.Fatalf("PutUser() with %s set role %s, expected %s", c.body, actual, c.expected)
+		}
+	}
+}
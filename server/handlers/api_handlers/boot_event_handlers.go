@@ -0,0 +1,110 @@
+package api_handlers
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/bootevent"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/evanebb/gobble/system"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+ * Request and response structures, and their supporting functions
+ */
+
+// bootEventResponse is the JSON representation of a bootevent.BootEvent that is returned by the API.
+type bootEventResponse struct {
+	Id         uuid.UUID  `json:"id"`
+	Time       time.Time  `json:"time"`
+	Mac        string     `json:"mac"`
+	ClientIp   string     `json:"clientIp"`
+	UserAgent  string     `json:"userAgent"`
+	Result     string     `json:"result"`
+	Profile    *uuid.UUID `json:"profile"`
+	BootMode   string     `json:"bootMode"`
+	ScriptHash string     `json:"scriptHash"`
+}
+
+// newBootEventResponse accepts a bootevent.BootEvent, and casts it into a bootEventResponse.
+func newBootEventResponse(e bootevent.BootEvent) bootEventResponse {
+	var clientIp string
+	if e.ClientIp != nil {
+		clientIp = e.ClientIp.String()
+	}
+
+	var profile *uuid.UUID
+	if e.Profile != uuid.Nil {
+		profile = &e.Profile
+	}
+
+	return bootEventResponse{
+		Id:         e.Id,
+		Time:       e.Time,
+		Mac:        e.Mac.String(),
+		ClientIp:   clientIp,
+		UserAgent:  e.UserAgent,
+		Result:     string(e.Result),
+		Profile:    profile,
+		BootMode:   e.BootMode,
+		ScriptHash: e.ScriptHash,
+	}
+}
+
+/*
+ * HTTP handlers
+ */
+
+// defaultBootEventLimit is the number of boot events that are returned if no limit is passed.
+const defaultBootEventLimit = 100
+
+// BootEventHandlerGroup is a group of http.HandlerFunc functions related to the boot history of systems
+type BootEventHandlerGroup struct {
+	bootEventRepo bootevent.Repository
+	systemRepo    system.Repository
+}
+
+func NewBootEventHandlerGroup(br bootevent.Repository, sr system.Repository) BootEventHandlerGroup {
+	return BootEventHandlerGroup{br, sr}
+}
+
+// GetSystemBootEvents returns the most recent boot events of the system, newest first.
+// The number of returned events can be changed using the 'limit' query parameter.
+func (h BootEventHandlerGroup) GetSystemBootEvents(w http.ResponseWriter, r *http.Request) error {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	limit := defaultBootEventLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return NewHTTPError(errors.New("limit must be a positive number"), http.StatusBadRequest)
+		}
+	}
+
+	_, err = h.systemRepo.GetSystemById(systemId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	events, err := h.bootEventRepo.GetBootEventsBySystemId(systemId, limit)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	resp := make([]bootEventResponse, 0)
+	for _, e := range events {
+		resp = append(resp, newBootEventResponse(e))
+	}
+
+	return response.Success(w, http.StatusOK, resp)
+}
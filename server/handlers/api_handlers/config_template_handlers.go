@@ -0,0 +1,228 @@
+package api_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/configtemplate"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+/*
+ * Request and response structures, and their supporting functions
+ */
+
+// configTemplateRequest is the JSON representation of a configtemplate.ConfigTemplate that is accepted by the API.
+type configTemplateRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Content     string `json:"content"`
+}
+
+// configTemplateResponse is the JSON representation of a configtemplate.ConfigTemplate that is returned by the API.
+type configTemplateResponse struct {
+	Id          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Type        string    `json:"type"`
+	Content     string    `json:"content"`
+}
+
+// newConfigTemplateResponse accepts a configtemplate.ConfigTemplate, and casts it into a configTemplateResponse.
+func newConfigTemplateResponse(c configtemplate.ConfigTemplate) configTemplateResponse {
+	return configTemplateResponse{
+		Id:          c.Id,
+		Name:        c.Name,
+		Description: c.Description,
+		Type:        string(c.Type),
+		Content:     c.Content,
+	}
+}
+
+/*
+ * HTTP handlers
+ */
+
+// ConfigTemplateHandlerGroup is a group of http.HandlerFunc functions related to config templates
+type ConfigTemplateHandlerGroup struct {
+	configTemplateRepo configtemplate.Repository
+}
+
+func NewConfigTemplateHandlerGroup(cr configtemplate.Repository) ConfigTemplateHandlerGroup {
+	return ConfigTemplateHandlerGroup{cr}
+}
+
+func (h ConfigTemplateHandlerGroup) GetConfigTemplates(w http.ResponseWriter, r *http.Request) error {
+	templates, err := h.configTemplateRepo.GetConfigTemplates()
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	resp := make([]configTemplateResponse, 0)
+	for _, c := range templates {
+		resp = append(resp, newConfigTemplateResponse(c))
+	}
+
+	return response.Success(w, http.StatusOK, resp)
+}
+
+func (h ConfigTemplateHandlerGroup) GetConfigTemplate(w http.ResponseWriter, r *http.Request) error {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	c, err := h.configTemplateRepo.GetConfigTemplateById(configTemplateId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newConfigTemplateResponse(c))
+}
+
+func (h ConfigTemplateHandlerGroup) CreateConfigTemplate(w http.ResponseWriter, r *http.Request) error {
+	var req configTemplateRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	c, err := configtemplate.New(uuid.New(), req.Name, req.Description, configtemplate.Type(req.Type), req.Content)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	if err := h.validateName(c); err != nil {
+		return err
+	}
+
+	err = h.configTemplateRepo.SetConfigTemplate(c)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusCreated, newConfigTemplateResponse(c))
+}
+
+func (h ConfigTemplateHandlerGroup) PutConfigTemplate(w http.ResponseWriter, r *http.Request) error {
+	var req configTemplateRequest
+
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	c, err := configtemplate.New(configTemplateId, req.Name, req.Description, configtemplate.Type(req.Type), req.Content)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	if err := h.validateName(c); err != nil {
+		return err
+	}
+
+	err = h.configTemplateRepo.SetConfigTemplate(c)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newConfigTemplateResponse(c))
+}
+
+func (h ConfigTemplateHandlerGroup) PatchConfigTemplate(w http.ResponseWriter, r *http.Request) error {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	// Get and map the current config template to the API DTO
+	c, err := h.configTemplateRepo.GetConfigTemplateById(configTemplateId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	req := configTemplateRequest{
+		Name:        c.Name,
+		Description: c.Description,
+		Type:        string(c.Type),
+		Content:     c.Content,
+	}
+
+	// Decode the request body into the current config template;
+	// Values supplied in the body will overwrite the current values,
+	// and anything that isn't supplied will be left alone
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	// Map the DTO back to the model, this time with the newly supplied values from the request body
+	c, err = configtemplate.New(configTemplateId, req.Name, req.Description, configtemplate.Type(req.Type), req.Content)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	if err := h.validateName(c); err != nil {
+		return err
+	}
+
+	err = h.configTemplateRepo.SetConfigTemplate(c)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newConfigTemplateResponse(c))
+}
+
+func (h ConfigTemplateHandlerGroup) DeleteConfigTemplate(w http.ResponseWriter, r *http.Request) error {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.configTemplateRepo.DeleteConfigTemplateById(configTemplateId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	// No data to return, just pass nil
+	return response.Success(w, http.StatusNoContent, nil)
+}
+
+// validateName checks that no other config template has the same name, since config templates are served by their name.
+func (h ConfigTemplateHandlerGroup) validateName(c configtemplate.ConfigTemplate) error {
+	existing, err := h.configTemplateRepo.GetConfigTemplateByName(c.Name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	if existing.Id != c.Id {
+		return NewHTTPError(errors.New("a config template with the name "+c.Name+" already exists"), http.StatusConflict)
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package api_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/discovery"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/evanebb/gobble/system"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+/*
+ * Request and response structures, and their supporting functions
+ */
+
+// discoveredSystemResponse is the JSON representation of a discovery.DiscoveredSystem that is returned by the API.
+type discoveredSystemResponse struct {
+	Id         uuid.UUID `json:"id"`
+	Mac        string    `json:"mac"`
+	FirstSeen  time.Time `json:"firstSeen"`
+	LastSeen   time.Time `json:"lastSeen"`
+	SourceIp   string    `json:"sourceIp"`
+	Vendor     string    `json:"vendor"`
+	Serial     string    `json:"serial"`
+	SmbiosUuid string    `json:"smbiosUuid"`
+}
+
+// newDiscoveredSystemResponse accepts a discovery.DiscoveredSystem, and casts it into a discoveredSystemResponse.
+func newDiscoveredSystemResponse(d discovery.DiscoveredSystem) discoveredSystemResponse {
+	var sourceIp string
+	if d.SourceIp != nil {
+		sourceIp = d.SourceIp.String()
+	}
+
+	return discoveredSystemResponse{
+		Id:         d.Id,
+		Mac:        d.Mac.String(),
+		FirstSeen:  d.FirstSeen,
+		LastSeen:   d.LastSeen,
+		SourceIp:   sourceIp,
+		Vendor:     d.Vendor,
+		Serial:     d.Serial,
+		SmbiosUuid: d.SmbiosUuid,
+	}
+}
+
+// promoteRequest is the JSON representation of the system.System that a discovery.DiscoveredSystem is promoted into, that is accepted by the API.
+// The MAC address is taken from the discovered system.
+type promoteRequest struct {
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Profile          uuid.UUID `json:"profile"`
+	KernelParameters []string  `json:"kernelParameters"`
+}
+
+/*
+ * HTTP handlers
+ */
+
+// DiscoveryHandlerGroup is a group of http.HandlerFunc functions related to discovered systems
+type DiscoveryHandlerGroup struct {
+	discoveryRepo discovery.Repository
+	systemRepo    system.Repository
+}
+
+func NewDiscoveryHandlerGroup(dr discovery.Repository, sr system.Repository) DiscoveryHandlerGroup {
+	return DiscoveryHandlerGroup{dr, sr}
+}
+
+func (h DiscoveryHandlerGroup) GetDiscoveredSystems(w http.ResponseWriter, r *http.Request) error {
+	discovered, err := h.discoveryRepo.GetDiscoveredSystems()
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	resp := make([]discoveredSystemResponse, 0)
+	for _, d := range discovered {
+		resp = append(resp, newDiscoveredSystemResponse(d))
+	}
+
+	return response.Success(w, http.StatusOK, resp)
+}
+
+func (h DiscoveryHandlerGroup) GetDiscoveredSystem(w http.ResponseWriter, r *http.Request) error {
+	discoveredId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	d, err := h.discoveryRepo.GetDiscoveredSystemById(discoveredId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newDiscoveredSystemResponse(d))
+}
+
+// PromoteDiscoveredSystem creates a new system from the discovered system, and removes the discovered system afterward.
+func (h DiscoveryHandlerGroup) PromoteDiscoveredSystem(w http.ResponseWriter, r *http.Request) error {
+	var req promoteRequest
+
+	discoveredId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	d, err := h.discoveryRepo.GetDiscoveredSystemById(discoveredId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	kp, err := kernelparameters.ParseStringSlice(req.KernelParameters)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	// The serial number and SMBIOS UUID that iPXE reported are kept, so the system is still recognized if it boots from another interface.
+	// Vendor placeholders are left out, since they do not identify the system.
+	i := system.Identifiers{Interfaces: system.Interfaces{{Mac: d.Mac, Boot: true}}, SmbiosUuid: d.ParsedSmbiosUuid(), Serial: d.Serial}.WithoutPlaceholders()
+	sys, err := system.New(uuid.New(), req.Name, req.Description, req.Profile, i, kp, nil, 0, system.BootModeProfile, nil)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.systemRepo.SetSystem(sys)
+	if err != nil {
+		// The name or the identifiers can already be in use by another system
+		if errors.Is(err, repository.ErrConflict) {
+			return NewHTTPError(err, http.StatusConflict)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	err = h.discoveryRepo.DeleteDiscoveredSystemById(d.Id)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusCreated, newSystemResponse(sys))
+}
+
+func (h DiscoveryHandlerGroup) DeleteDiscoveredSystem(w http.ResponseWriter, r *http.Request) error {
+	discoveredId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.discoveryRepo.DeleteDiscoveredSystemById(discoveredId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusNoContent, nil)
+}
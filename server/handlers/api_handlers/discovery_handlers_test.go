@@ -0,0 +1,158 @@
+package api_handlers
+
+import (
+	"github.com/evanebb/gobble/discovery"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/settings"
+	"github.com/evanebb/gobble/system"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// discoveryRepository is a simple in-memory discovery.Repository for testing.
+type discoveryRepository map[uuid.UUID]discovery.DiscoveredSystem
+
+func (r discoveryRepository) GetDiscoveredSystems() ([]discovery.DiscoveredSystem, error) {
+	var discovered []discovery.DiscoveredSystem
+	for _, d := range r {
+		discovered = append(discovered, d)
+	}
+	return discovered, nil
+}
+
+func (r discoveryRepository) GetDiscoveredSystemById(id uuid.UUID) (discovery.DiscoveredSystem, error) {
+	d, ok := r[id]
+	if !ok {
+		return d, repository.ErrNotFound
+	}
+	return d, nil
+}
+
+func (r discoveryRepository) RecordDiscoveredSystem(d discovery.DiscoveredSystem) error {
+	for id, existing := range r {
+		if existing.Mac.String() == d.Mac.String() {
+			d.Id = id
+			d.FirstSeen = existing.FirstSeen
+		}
+	}
+	r[d.Id] = d
+	return nil
+}
+
+func (r discoveryRepository) DeleteDiscoveredSystemById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func (r discoveryRepository) DeleteDiscoveredSystemsLastSeenBefore(t time.Time) (int64, error) {
+	var deleted int64
+	for id, d := range r {
+		if d.LastSeen.Before(t) {
+			delete(r, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func TestPromoteDiscoveredSystemIdentifiers(t *testing.T) {
+	mac, err := net.ParseMAC("52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("failed to parse MAC address: %v", err)
+	}
+
+	smbiosUuid := uuid.New()
+	d := discovery.New(uuid.New(), mac, time.Now(), time.Now(), nil, "QEMU", "ABC1234", smbiosUuid.String())
+	discovered := discoveryRepository{d.Id: d}
+	systems := newSystemRepository()
+
+	h := NewDiscoveryHandlerGroup(discovered, systems)
+	router := chi.NewRouter()
+	router.Post("/api/discovered-systems/{uuid}/promote", ErrorHandler(h.PromoteDiscoveredSystem))
+
+	body := `{"name": "web01", "description": "", "profile": "` + uuid.NewString() + `", "kernelParameters": []}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/discovered-systems/"+d.Id.String()+"/promote", strings.NewReader(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("PromoteDiscoveredSystem() returned %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(systems.systems) != 1 {
+		t.Fatalf("PromoteDiscoveredSystem() created %d systems, expected 1", len(systems.systems))
+	}
+
+	for _, s := range systems.systems {
+		i := s.Identifiers
+		if len(i.Interfaces) != 1 || i.Interfaces[0].Mac.String() != mac.String() || i.Serial != d.Serial || i.SmbiosUuid != smbiosUuid {
+			t.Fatalf("PromoteDiscoveredSystem() created a system with identifiers %+v, expected the ones of %+v", i, d)
+		}
+	}
+
+	if _, ok := discovered[d.Id]; ok {
+		t.Fatalf("PromoteDiscoveredSystem() did not remove the discovered system")
+	}
+}
+
+func TestPromoteDiscoveredSystemConflict(t *testing.T) {
+	mac, err := net.ParseMAC("52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("failed to parse MAC address: %v", err)
+	}
+
+	existing := system.System{Id: uuid.New(), Name: "web01", Identifiers: system.Identifiers{Interfaces: system.Interfaces{{Mac: mac, Boot: true}}}}
+	d := discovery.New(uuid.New(), mac, time.Now(), time.Now(), nil, "", "", "")
+	discovered := discoveryRepository{d.Id: d}
+
+	h := NewDiscoveryHandlerGroup(discovered, newSystemRepository(existing))
+	router := chi.NewRouter()
+	router.Post("/api/discovered-systems/{uuid}/promote", ErrorHandler(h.PromoteDiscoveredSystem))
+
+	// The MAC address of the discovered system is already used by another system, e.g. because it was registered by hand
+	body := `{"name": "web02", "description": "", "profile": "` + uuid.NewString() + `", "kernelParameters": []}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/discovered-systems/"+d.Id.String()+"/promote", strings.NewReader(body)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("PromoteDiscoveredSystem() returned %d, expected %d: %s", w.Code, http.StatusConflict, w.Body.String())
+	}
+
+	if _, ok := discovered[d.Id]; !ok {
+		t.Fatalf("PromoteDiscoveredSystem() removed the discovered system, even though it was not promoted")
+	}
+}
+
+func TestGetPxeConfigTruncatesDiscoveredSystem(t *testing.T) {
+	discovered := discoveryRepository{}
+	h := NewPxeConfigHandlerGroup(newSystemRepository(), profileRepository{}, &settingsRepository{settings: settings.New(uuid.Nil, true, 0)}, discovered, &bootEventRepository{}, configTemplateRepository{}, system.NewTemplateRenderer(), "")
+	router := chi.NewRouter()
+	router.Get("/api/pxe-config", ErrorHandler(h.GetPxeConfig))
+
+	// Multibyte characters must not be cut in half, and invalid UTF-8 can not be stored at all
+	q := url.Values{}
+	q.Set("mac", "52:54:00:12:34:56")
+	q.Set("vendor", strings.Repeat("é", 200))
+	q.Set("serial", "\xff"+strings.Repeat("a", 200))
+	q.Set("uuid", strings.Repeat("ü", 100))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/pxe-config?"+q.Encode(), nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GetPxeConfig() returned %d, expected %d", w.Code, http.StatusNotFound)
+	}
+
+	if len(discovered) != 1 {
+		t.Fatalf("GetPxeConfig() recorded %d discovered systems, expected 1", len(discovered))
+	}
+
+	for _, d := range discovered {
+		if d.Vendor != strings.Repeat("é", 128) || d.Serial != strings.Repeat("a", 128) || d.SmbiosUuid != strings.Repeat("ü", 64) {
+			t.Fatalf("GetPxeConfig() recorded %+v, expected the values to be truncated to the length of their columns", d)
+		}
+	}
+}
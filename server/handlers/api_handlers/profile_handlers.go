@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/configtemplate"
 	"github.com/evanebb/gobble/kernelparameters"
 	"github.com/evanebb/gobble/profile"
 	"github.com/evanebb/gobble/repository"
 	"github.com/evanebb/gobble/server/handlers"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
 	"net/http"
 )
@@ -18,21 +20,31 @@ import (
 
 // profileRequest is the JSON representation of a profile.Profile that is accepted by the API.
 type profileRequest struct {
-	Name             string   `json:"name"`
-	Description      string   `json:"description"`
-	Kernel           string   `json:"kernel"`
-	Initrd           string   `json:"initrd"`
-	KernelParameters []string `json:"kernelParameters"`
+	Name             string            `json:"name"`
+	Description      string            `json:"description"`
+	Kernel           string            `json:"kernel"`
+	Initrds          []initrdRequest   `json:"initrds"`
+	KernelParameters []string          `json:"kernelParameters"`
+	Template         string            `json:"template"`
+	NetworkStyle     string            `json:"networkStyle"`
+	Parent           *uuid.UUID        `json:"parent"`
+	Metadata         map[string]string `json:"metadata"`
+	ConfigTemplates  []uuid.UUID       `json:"configTemplates"`
 }
 
 // profileResponse is the JSON representation of a profile.Profile that is returned by the API.
 type profileResponse struct {
-	Id               uuid.UUID `json:"id"`
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	Kernel           string    `json:"kernel"`
-	Initrd           string    `json:"initrd"`
-	KernelParameters []string  `json:"kernelParameters"`
+	Id               uuid.UUID         `json:"id"`
+	Name             string            `json:"name"`
+	Description      string            `json:"description"`
+	Kernel           string            `json:"kernel"`
+	Initrds          []initrdResponse  `json:"initrds"`
+	KernelParameters []string          `json:"kernelParameters"`
+	Template         string            `json:"template"`
+	NetworkStyle     string            `json:"networkStyle"`
+	Parent           *uuid.UUID        `json:"parent"`
+	Metadata         map[string]string `json:"metadata"`
+	ConfigTemplates  []uuid.UUID       `json:"configTemplates"`
 }
 
 // newProfileResponse accepts a profile.Profile, and casts it to a profileResponse.
@@ -42,22 +54,95 @@ func newProfileResponse(p profile.Profile) profileResponse {
 		Name:             p.Name,
 		Description:      p.Description,
 		Kernel:           p.Kernel,
-		Initrd:           p.Initrd,
+		Initrds:          newInitrdsResponse(p.Initrds),
 		KernelParameters: p.KernelParameters.StringSlice(),
+		Template:         p.Template,
+		NetworkStyle:     string(p.NetworkStyle),
+		Parent:           newParentResponse(p.Parent),
+		Metadata:         newMetadataResponse(p.Metadata),
+		ConfigTemplates:  append([]uuid.UUID{}, p.ConfigTemplates...),
 	}
 }
 
+// newMetadataResponse returns the metadata as a map, which is empty instead of nil if there is no metadata.
+func newMetadataResponse(m variables.Metadata) map[string]string {
+	resp := make(map[string]string, len(m))
+	for k, v := range m {
+		resp[k] = v
+	}
+	return resp
+}
+
+// initrdRequest is the JSON representation of a profile.Initrd that is accepted by the API.
+type initrdRequest struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// initrds returns the initrds in the profileRequest as profile.Initrds.
+func (req profileRequest) initrds() profile.Initrds {
+	initrds := make(profile.Initrds, 0, len(req.Initrds))
+	for _, i := range req.Initrds {
+		initrds = append(initrds, profile.Initrd{Path: i.Path, Name: i.Name})
+	}
+	return initrds
+}
+
+// newInitrdsRequest accepts profile.Initrds, and casts them into a slice of initrdRequest.
+// This is used to fill a request with the current values when patching a profile.
+func newInitrdsRequest(initrds profile.Initrds) []initrdRequest {
+	req := make([]initrdRequest, 0, len(initrds))
+	for _, i := range newInitrdsResponse(initrds) {
+		req = append(req, initrdRequest(i))
+	}
+	return req
+}
+
+// initrdResponse is the JSON representation of a profile.Initrd that is returned by the API.
+type initrdResponse struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+}
+
+// newInitrdsResponse accepts profile.Initrds, and casts them into a slice of initrdResponse.
+func newInitrdsResponse(initrds profile.Initrds) []initrdResponse {
+	resp := make([]initrdResponse, 0, len(initrds))
+	for _, i := range initrds {
+		resp = append(resp, initrdResponse{Path: i.Path, Name: i.Name})
+	}
+	return resp
+}
+
+// newParentResponse returns the ID of the parent profile, or nil if there is none.
+func newParentResponse(parent uuid.UUID) *uuid.UUID {
+	if parent == uuid.Nil {
+		return nil
+	}
+
+	return &parent
+}
+
+// parent returns the ID of the parent profile in the profileRequest, or uuid.Nil if there is none.
+func (req profileRequest) parent() uuid.UUID {
+	if req.Parent == nil {
+		return uuid.Nil
+	}
+
+	return *req.Parent
+}
+
 /*
  * HTTP handlers
  */
 
 // ProfileHandlerGroup is a group of http.HandlerFunc functions related to profiles
 type ProfileHandlerGroup struct {
-	profileRepo profile.Repository
+	profileRepo        profile.Repository
+	configTemplateRepo configtemplate.Repository
 }
 
-func NewProfileHandlerGroup(pr profile.Repository) ProfileHandlerGroup {
-	return ProfileHandlerGroup{pr}
+func NewProfileHandlerGroup(pr profile.Repository, cr configtemplate.Repository) ProfileHandlerGroup {
+	return ProfileHandlerGroup{pr, cr}
 }
 
 func (h ProfileHandlerGroup) GetProfiles(w http.ResponseWriter, r *http.Request) error {
@@ -107,11 +192,19 @@ func (h ProfileHandlerGroup) CreateProfile(w http.ResponseWriter, r *http.Reques
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	p, err := profile.New(profileId, req.Name, req.Description, req.Kernel, req.Initrd, kp)
+	p, err := profile.New(profileId, req.Name, req.Description, req.Kernel, req.initrds(), kp, req.Template, profile.NetworkStyle(req.NetworkStyle), req.parent(), req.Metadata, req.ConfigTemplates)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	if err := h.validateParent(p); err != nil {
+		return err
+	}
+
+	if err := h.validateConfigTemplates(p); err != nil {
+		return err
+	}
+
 	err = h.profileRepo.SetProfile(p)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -140,11 +233,19 @@ func (h ProfileHandlerGroup) PutProfile(w http.ResponseWriter, r *http.Request)
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	p, err := profile.New(profileId, req.Name, req.Description, req.Kernel, req.Initrd, kp)
+	p, err := profile.New(profileId, req.Name, req.Description, req.Kernel, req.initrds(), kp, req.Template, profile.NetworkStyle(req.NetworkStyle), req.parent(), req.Metadata, req.ConfigTemplates)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	if err := h.validateParent(p); err != nil {
+		return err
+	}
+
+	if err := h.validateConfigTemplates(p); err != nil {
+		return err
+	}
+
 	err = h.profileRepo.SetProfile(p)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -169,8 +270,12 @@ func (h ProfileHandlerGroup) PatchProfile(w http.ResponseWriter, r *http.Request
 		Name:             p.Name,
 		Description:      p.Description,
 		Kernel:           p.Kernel,
-		Initrd:           p.Initrd,
+		Initrds:          newInitrdsRequest(p.Initrds),
 		KernelParameters: p.KernelParameters.StringSlice(),
+		Template:         p.Template,
+		NetworkStyle:     string(p.NetworkStyle),
+		Parent:           newParentResponse(p.Parent),
+		ConfigTemplates:  p.ConfigTemplates,
 	}
 
 	// Decode the request body into the current profile;
@@ -183,17 +288,30 @@ func (h ProfileHandlerGroup) PatchProfile(w http.ResponseWriter, r *http.Request
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	// The metadata is left out of the current values, since decoding into a map would merge it instead of replacing it
+	if req.Metadata == nil {
+		req.Metadata = p.Metadata
+	}
+
 	kp, err := kernelparameters.ParseStringSlice(req.KernelParameters)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
 	// Map the DTO back to the model, this time with the newly supplied values from the request body
-	p, err = profile.New(profileId, req.Name, req.Description, req.Kernel, req.Initrd, kp)
+	p, err = profile.New(profileId, req.Name, req.Description, req.Kernel, req.initrds(), kp, req.Template, profile.NetworkStyle(req.NetworkStyle), req.parent(), req.Metadata, req.ConfigTemplates)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	if err := h.validateParent(p); err != nil {
+		return err
+	}
+
+	if err := h.validateConfigTemplates(p); err != nil {
+		return err
+	}
+
 	err = h.profileRepo.SetProfile(p)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -208,6 +326,16 @@ func (h ProfileHandlerGroup) DeleteProfile(w http.ResponseWriter, r *http.Reques
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	// Profiles that others inherit from cannot be deleted, since their children would be left incomplete
+	hasChildren, err := profile.HasChildren(h.profileRepo, profileId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	if hasChildren {
+		return NewHTTPError(profile.ErrHasChildren, http.StatusConflict)
+	}
+
 	err = h.profileRepo.DeleteProfileById(profileId)
 	if err != nil {
 		return NewHTTPError(err, http.StatusInternalServerError)
@@ -216,3 +344,54 @@ func (h ProfileHandlerGroup) DeleteProfile(w http.ResponseWriter, r *http.Reques
 	// No data to return, just pass nil
 	return response.Success(w, http.StatusNoContent, nil)
 }
+
+// GetEffectiveProfile returns the profile with everything that it inherits from its parents filled in, which is what systems boot.
+func (h ProfileHandlerGroup) GetEffectiveProfile(w http.ResponseWriter, r *http.Request) error {
+	profileId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	p, err := h.profileRepo.GetProfileById(profileId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	p, err = profile.Resolve(h.profileRepo, p)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newProfileResponse(p))
+}
+
+// validateParent checks that the parent of the profile exists and does not create an inheritance cycle.
+func (h ProfileHandlerGroup) validateParent(p profile.Profile) error {
+	err := profile.ValidateParent(h.profileRepo, p)
+	if err != nil {
+		if errors.Is(err, profile.ErrInheritanceCycle) || errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusBadRequest)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// validateConfigTemplates checks that the config templates that are attached to the profile exist.
+func (h ProfileHandlerGroup) validateConfigTemplates(p profile.Profile) error {
+	for _, id := range p.ConfigTemplates {
+		_, err := h.configTemplateRepo.GetConfigTemplateById(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return NewHTTPError(errors.New("config template "+id.String()+" does not exist"), http.StatusBadRequest)
+			}
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+	}
+
+	return nil
+}
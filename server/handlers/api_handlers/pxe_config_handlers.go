@@ -1,55 +1,458 @@
 package api_handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/evanebb/gobble/api/response"
-	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/bootevent"
+	"github.com/evanebb/gobble/configtemplate"
+	"github.com/evanebb/gobble/discovery"
 	"github.com/evanebb/gobble/profile"
 	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/evanebb/gobble/settings"
 	"github.com/evanebb/gobble/system"
+	"github.com/evanebb/gobble/variables"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"log"
 	"net"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
+/*
+ * Request and response structures, and their supporting functions
+ */
+
+// pxeConfigPreviewResponse is the JSON representation of the iPXE script a system.System will receive, that is returned by the API.
+type pxeConfigPreviewResponse struct {
+	BootMode         string                             `json:"bootMode"`
+	Profile          uuid.UUID                          `json:"profile"`
+	KernelParameters []effectiveKernelParameterResponse `json:"kernelParameters"`
+	Script           string                             `json:"script"`
+}
+
+// newPxeConfigPreviewResponse accepts a system.PxeConfig and the script that was rendered from it, and casts them into a pxeConfigPreviewResponse.
+func newPxeConfigPreviewResponse(c system.PxeConfig, script string) pxeConfigPreviewResponse {
+	return pxeConfigPreviewResponse{
+		BootMode:         string(c.BootMode),
+		Profile:          c.Profile.Id,
+		KernelParameters: newEffectiveKernelParametersResponse(c.KernelParameters),
+		Script:           script,
+	}
+}
+
 /*
  * HTTP handlers
  */
 
 // PxeConfigHandlerGroup is a group of http.HandlerFunc functions related to PXE configs
 type PxeConfigHandlerGroup struct {
-	systemRepo  system.Repository
-	profileRepo profile.Repository
+	systemRepo         system.Repository
+	settingsRepo       settings.Repository
+	discoveryRepo      discovery.Repository
+	bootEventRepo      bootevent.Repository
+	configTemplateRepo configtemplate.Repository
+	builder            system.PxeConfigBuilder
+	renderer           system.Renderer
+	externalUrl        string
 }
 
-func NewPxeConfigHandlerGroup(sr system.Repository, pr profile.Repository) PxeConfigHandlerGroup {
+// NewPxeConfigHandlerGroup creates a new PxeConfigHandlerGroup. The external URL is used to build callback URLs for systems;
+// if it is empty, the URL of the incoming request is used instead.
+func NewPxeConfigHandlerGroup(sr system.Repository, pr profile.Repository, setr settings.Repository, dr discovery.Repository, br bootevent.Repository, cr configtemplate.Repository, renderer system.Renderer, externalUrl string) PxeConfigHandlerGroup {
 	return PxeConfigHandlerGroup{
 		sr,
-		pr,
+		setr,
+		dr,
+		br,
+		cr,
+		system.NewPxeConfigBuilder(pr),
+		renderer,
+		externalUrl,
 	}
 }
 
+// parseIdentifiersFromRequest parses the identifiers of the requesting system from the query parameters
+// 'mac', 'uuid', 'serial', 'asset' and 'hostname', which iPXE fills using the settings with the same names.
+// iPXE sends an empty or invalid SMBIOS UUID on some machines, in which case it is ignored.
+func parseIdentifiersFromRequest(r *http.Request) (system.Identifiers, error) {
+	q := r.URL.Query()
+	i := system.Identifiers{
+		Serial:   q.Get("serial"),
+		AssetTag: q.Get("asset"),
+		Hostname: q.Get("hostname"),
+	}
+
+	if q.Get("mac") != "" {
+		mac, err := net.ParseMAC(q.Get("mac"))
+		if err != nil {
+			return i, err
+		}
+		i.Interfaces = system.Interfaces{{Mac: mac}}
+	}
+
+	if smbiosUuid, err := uuid.Parse(q.Get("uuid")); err == nil {
+		i.SmbiosUuid = smbiosUuid
+	}
+
+	if i.IsEmpty() {
+		return i, errors.New("no identifiers for the system were passed")
+	}
+
+	return i, nil
+}
+
+// GetPxeConfig serves the iPXE script for the system that sends the request.
+// The system is looked up by the identifiers it sends, in the order described by system.FindSystem.
 func (h PxeConfigHandlerGroup) GetPxeConfig(w http.ResponseWriter, r *http.Request) error {
-	mac, err := net.ParseMAC(r.URL.Query().Get("mac"))
+	identifiers, err := parseIdentifiersFromRequest(r)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	sys, err := h.systemRepo.GetSystemByMacAddress(mac)
+	// The MAC address of the interface that sent the request, which is nil if it did not send one
+	var mac net.HardwareAddr
+	if len(identifiers.Interfaces) > 0 {
+		mac = identifiers.Interfaces[0].Mac
+	}
+
+	sys, err := system.FindSystem(h.systemRepo, identifiers)
 	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
+		if !errors.Is(err, repository.ErrNotFound) {
+			// This should be a 404, but iPXE won't load the script if that is the response code
+			return NewHTTPError(err, http.StatusOK)
+		}
+
+		s, err := h.settingsRepo.GetSettings()
+		if err != nil {
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+
+		// Discovered systems are keyed by their MAC address, so systems that did not send one cannot be recorded
+		if s.DiscoveryEnabled && mac != nil {
+			// Failing to record the system should not keep it from booting, so only log the error
+			if err := h.recordDiscoveredSystem(r, mac); err != nil {
+				log.Println(err)
+			}
+		}
+
+		// Unregistered systems boot the default profile, if one has been configured
+		if s.DefaultProfile == uuid.Nil {
+			h.recordBootEvent(r, mac, sys, bootevent.ResultNotFound, system.PxeConfig{}, "")
 			return response.PlainText(w, http.StatusNotFound, system.RenderNotFound())
 		}
-		// This should be a 404, but iPXE won't load the script if that is the response code
-		return NewHTTPError(err, http.StatusOK)
+
+		sys = system.System{Identifiers: identifiers, Profile: s.DefaultProfile, BootMode: system.BootModeProfile}
+	} else {
+		// Only the request that clears the pending next boot may serve it, so it is never served more than once
+		sys.NextBoot = nil
+		n, err := h.systemRepo.ConsumeNextBoot(sys.Id)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+		if err == nil {
+			sys.NextBoot = &n
+		}
+	}
+
+	pxeConfig, script, err := h.render(r, sys)
+	if err != nil {
+		// Put the next boot back if it could not be served, so it is not lost
+		if sys.NextBoot != nil {
+			if err := h.systemRepo.SetNextBoot(sys.Id, *sys.NextBoot); err != nil {
+				log.Println(err)
+			}
+		}
+		h.recordBootEvent(r, mac, sys, bootevent.ResultError, pxeConfig, "")
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	h.recordBootEvent(r, mac, sys, bootevent.ResultServed, pxeConfig, script)
+	return response.PlainText(w, http.StatusOK, script)
+}
+
+// recordBootEvent records the request for an iPXE config in the boot history, together with what was served in response to it.
+// Failing to record the event should not keep the system from booting, so errors are only logged.
+func (h PxeConfigHandlerGroup) recordBootEvent(r *http.Request, mac net.HardwareAddr, sys system.System, result bootevent.Result, c system.PxeConfig, script string) {
+	var scriptHash string
+	if script != "" {
+		sum := sha256.Sum256([]byte(script))
+		scriptHash = hex.EncodeToString(sum[:])
+	}
+
+	// The user agent is stored in a limited column, and is only informational anyway
+	userAgent := truncate(r.UserAgent(), 256)
+
+	e := bootevent.New(uuid.New(), time.Now(), mac, sys.Id, handlers.GetClientIPFromRequest(r), userAgent, result, c.Profile.Id, string(c.BootMode), scriptHash)
+	if err := h.bootEventRepo.RecordBootEvent(e); err != nil {
+		log.Println(err)
+	}
+}
+
+// render builds the PxeConfig for the system and renders it into an iPXE script.
+// Registered systems that boot a profile receive a new install token, so they can report that their installation has completed.
+func (h PxeConfigHandlerGroup) render(r *http.Request, sys system.System) (system.PxeConfig, string, error) {
+	req := system.BootRequest{ClientIp: handlers.GetClientIPFromRequest(r), ServerUrl: h.baseUrl(r)}
+	pxeConfig, err := h.builder.Build(sys, req)
+	if err != nil {
+		return pxeConfig, "", err
+	}
+
+	if sys.Id != uuid.Nil && pxeConfig.BootMode == system.BootModeProfile {
+		pxeConfig, err = h.withInstallToken(r, pxeConfig)
+		if err != nil {
+			return pxeConfig, "", err
+		}
+	}
+
+	script, err := h.renderer.Render(pxeConfig)
+	return pxeConfig, script, err
+}
+
+// withInstallToken issues a new install token for the system of the PxeConfig, which replaces its previous one, and adds it to the PxeConfig.
+func (h PxeConfigHandlerGroup) withInstallToken(r *http.Request, c system.PxeConfig) (system.PxeConfig, error) {
+	token, err := system.NewInstallToken()
+	if err != nil {
+		return c, err
+	}
+
+	err = h.systemRepo.SetInstallToken(c.System.Id, system.HashInstallToken(token))
+	if err != nil {
+		return c, err
+	}
+
+	return c.WithInstallToken(token, h.installCompleteUrl(r, token)), nil
+}
+
+// baseUrl returns the URL that systems can reach the application on, which is the external URL if it is set.
+func (h PxeConfigHandlerGroup) baseUrl(r *http.Request) string {
+	return handlers.GetExternalUrl(r, h.externalUrl)
+}
+
+// installCompleteUrl returns the URL that a system can send a POST request to with the passed token, to report that its installation has completed.
+func (h PxeConfigHandlerGroup) installCompleteUrl(r *http.Request, token string) string {
+	return h.baseUrl(r) + "/api/install-complete?token=" + url.QueryEscape(token)
+}
+
+// CompleteInstall is called by a system when its installation has completed, using the install token it received while booting.
+// The system will boot from its local disk from then on. This endpoint is not authenticated, the install token is used instead.
+func (h PxeConfigHandlerGroup) CompleteInstall(w http.ResponseWriter, r *http.Request) error {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		return NewHTTPError(errors.New("missing install token"), http.StatusBadRequest)
+	}
+
+	_, err := h.systemRepo.CompleteInstall(system.HashInstallToken(token), time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(errors.New("invalid or already used install token"), http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusNoContent, nil)
+}
+
+// recordDiscoveredSystem records the unregistered system that sent the request, together with the information that iPXE sent about it.
+// The query parameters 'vendor', 'serial' and 'uuid' can be filled by iPXE using '${manufacturer}', '${serial}' and '${uuid}'.
+// Anyone can send them, so they are truncated to the length of the columns they are stored in.
+func (h PxeConfigHandlerGroup) recordDiscoveredSystem(r *http.Request, mac net.HardwareAddr) error {
+	now := time.Now()
+	q := r.URL.Query()
+
+	vendor := truncate(q.Get("vendor"), 128)
+	serial := truncate(q.Get("serial"), 128)
+	smbiosUuid := truncate(q.Get("uuid"), 64)
+
+	d := discovery.New(uuid.New(), mac, now, now, handlers.GetClientIPFromRequest(r), vendor, serial, smbiosUuid)
+	return h.discoveryRepo.RecordDiscoveredSystem(d)
+}
+
+// truncate returns s with invalid UTF-8 removed, cut off after at most n characters, so it can be stored in a varchar(n) column.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+
+	return string([]rune(s)[:n])
+}
+
+// GetSystemPxeConfig renders the iPXE script that the system would receive when booting right now,
+// together with the merged kernel parameters and the profile that was used.
+func (h PxeConfigHandlerGroup) GetSystemPxeConfig(w http.ResponseWriter, r *http.Request) error {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	sys, err := h.systemRepo.GetSystemById(systemId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	// The preview is not requested by the system itself, so its IP address is unknown
+	pxeConfig, err := h.builder.Build(sys, system.BootRequest{ServerUrl: h.baseUrl(r)})
+	if err != nil {
+		// Referencing a variable that does not exist is a configuration error, just like a broken template
+		var unknown variables.UnknownVariableError
+		if errors.As(err, &unknown) {
+			return NewHTTPError(err, http.StatusUnprocessableEntity)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	// A broken profile template is a configuration error, so show it to the user instead of hiding it behind a generic error
+	script, err := h.renderer.Render(pxeConfig)
+	if err != nil {
+		return NewHTTPError(fmt.Errorf("failed to render iPXE script: %w", err), http.StatusUnprocessableEntity)
+	}
+
+	return response.Success(w, http.StatusOK, newPxeConfigPreviewResponse(pxeConfig, script))
+}
+
+// GetConfig serves a config template of the profile that the system sending the request boots, e.g. a kickstart file.
+// The system is looked up by the identifiers it sends, just like for GetPxeConfig, and unregistered systems use the default profile.
+// Like the iPXE script, this endpoint is not authenticated, since installers cannot send credentials.
+func (h PxeConfigHandlerGroup) GetConfig(w http.ResponseWriter, r *http.Request) error {
+	identifiers, err := parseIdentifiersFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	sys, err := system.FindSystem(h.systemRepo, identifiers)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+
+		s, err := h.settingsRepo.GetSettings()
+		if err != nil {
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+
+		sys = system.System{Identifiers: identifiers, Profile: s.DefaultProfile, BootMode: system.BootModeProfile}
+	}
+
+	return h.serveConfig(w, r, sys)
+}
+
+// GetSystemConfig serves a config template of the profile that the system with the passed ID boots, e.g. a kickstart file.
+// This is the URL that system.PxeConfig.ConfigUrl returns for registered systems.
+func (h PxeConfigHandlerGroup) GetSystemConfig(w http.ResponseWriter, r *http.Request) error {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	sys, err := h.systemRepo.GetSystemById(systemId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
 	}
 
-	p, err := h.profileRepo.GetProfileById(sys.Profile)
+	return h.serveConfig(w, r, sys)
+}
+
+// serveConfig renders the config template with the name in the URL for the system, and serves it with the content type of its type.
+// The profile is taken from the 'profile' query parameter, since the system may have booted another profile than its own from its menu.
+// Only profiles that the system can boot are served, and the config template must be attached to the profile or one of its parents.
+// Templates that use the install token of a registered system require the token of the boot in the 'token' query parameter.
+func (h PxeConfigHandlerGroup) serveConfig(w http.ResponseWriter, r *http.Request, sys system.System) error {
+	ct, err := h.configTemplateRepo.GetConfigTemplateByName(chi.URLParam(r, "name"))
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
 		return NewHTTPError(err, http.StatusInternalServerError)
 	}
 
-	kp := kernelparameters.MergeKernelParameters(p.KernelParameters, sys.KernelParameters)
-	pxeConfig := system.NewPxeConfig(p.Kernel, p.Initrd, kp)
+	profileId := sys.Profile
+	if q := r.URL.Query().Get("profile"); q != "" {
+		profileId, err = uuid.Parse(q)
+		if err != nil {
+			return NewHTTPError(fmt.Errorf("[%s] is not a valid UUID: [%w]", q, err), http.StatusBadRequest)
+		}
+	}
+
+	if profileId == uuid.Nil {
+		return NewHTTPError(errors.New("the system does not boot a profile"), http.StatusNotFound)
+	}
+
+	boots, err := h.bootsProfile(sys, profileId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+	if !boots {
+		return NewHTTPError(errors.New("the system does not boot profile "+profileId.String()), http.StatusNotFound)
+	}
+
+	req := system.BootRequest{ClientIp: handlers.GetClientIPFromRequest(r), ServerUrl: h.baseUrl(r)}
+	pxeConfig, err := h.builder.BuildForProfile(sys, profileId, req)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	if !pxeConfig.Profile.HasConfigTemplate(ct.Id) {
+		return NewHTTPError(errors.New("config template "+ct.Name+" is not attached to profile "+pxeConfig.Profile.Name), http.StatusNotFound)
+	}
+
+	// The install token was issued with the iPXE script, so it is only checked here instead of being replaced by a new one
+	if sys.Id != uuid.Nil && ct.UsesInstallToken() {
+		token := r.URL.Query().Get("token")
+		valid := false
+		if token != "" {
+			valid, err = h.systemRepo.HasInstallToken(sys.Id, system.HashInstallToken(token))
+			if err != nil {
+				return NewHTTPError(err, http.StatusInternalServerError)
+			}
+		}
+
+		if !valid {
+			return NewHTTPError(errors.New("a valid install token is required"), http.StatusNotFound)
+		}
+
+		pxeConfig = pxeConfig.WithInstallToken(token, h.installCompleteUrl(r, token))
+	}
+
+	content, err := h.renderer.RenderConfig(pxeConfig, ct)
+	if err != nil {
+		return NewHTTPError(fmt.Errorf("failed to render config template %s: %w", ct.Name, err), http.StatusInternalServerError)
+	}
+
+	return response.Content(w, http.StatusOK, ct.Type.ContentType(), content)
+}
+
+// bootsProfile returns whether the system can be booting the passed profile. Besides the profiles that system.System.BootsProfile
+// allows, this is the profile that was last served to the system, since a pending next boot is cleared as soon as it is served.
+func (h PxeConfigHandlerGroup) bootsProfile(sys system.System, profileId uuid.UUID) (bool, error) {
+	if sys.BootsProfile(profileId) {
+		return true, nil
+	}
+
+	if sys.Id == uuid.Nil {
+		return false, nil
+	}
+
+	events, err := h.bootEventRepo.GetBootEventsBySystemId(sys.Id, 1)
+	if err != nil {
+		return false, err
+	}
 
-	return response.PlainText(w, http.StatusOK, pxeConfig.Render())
+	return len(events) > 0 && events[0].Result == bootevent.ResultServed && events[0].Profile == profileId, nil
 }
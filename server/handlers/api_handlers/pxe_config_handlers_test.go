@@ -0,0 +1,372 @@
+package api_handlers
+
+import (
+	"github.com/evanebb/gobble/bootevent"
+	"github.com/evanebb/gobble/configtemplate"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/settings"
+	"github.com/evanebb/gobble/system"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// systemRepository is an in-memory system.Repository that supports what the handlers in this package use.
+type systemRepository struct {
+	system.Repository
+	systems       map[uuid.UUID]system.System
+	installTokens map[uuid.UUID]string
+}
+
+func newSystemRepository(systems ...system.System) systemRepository {
+	r := systemRepository{systems: make(map[uuid.UUID]system.System), installTokens: make(map[uuid.UUID]string)}
+	for _, s := range systems {
+		r.systems[s.Id] = s
+	}
+	return r
+}
+
+func (r systemRepository) GetSystemById(id uuid.UUID) (system.System, error) {
+	s, ok := r.systems[id]
+	if !ok {
+		return s, repository.ErrNotFound
+	}
+	return s, nil
+}
+
+func (r systemRepository) find(match func(s system.System) bool) (system.System, error) {
+	for _, s := range r.systems {
+		if match(s) {
+			return s, nil
+		}
+	}
+	return system.System{}, repository.ErrNotFound
+}
+
+func (r systemRepository) GetSystemByMacAddress(mac net.HardwareAddr) (system.System, error) {
+	return r.find(func(s system.System) bool {
+		for _, m := range s.Identifiers.Interfaces.Macs() {
+			if m.String() == mac.String() {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r systemRepository) GetSystemBySmbiosUuid(smbiosUuid uuid.UUID) (system.System, error) {
+	return r.find(func(s system.System) bool { return s.Identifiers.SmbiosUuid == smbiosUuid })
+}
+
+func (r systemRepository) GetSystemBySerial(serial string) (system.System, error) {
+	return r.find(func(s system.System) bool { return s.Identifiers.Serial == serial })
+}
+
+func (r systemRepository) GetSystemByAssetTag(assetTag string) (system.System, error) {
+	return r.find(func(s system.System) bool { return s.Identifiers.AssetTag == assetTag })
+}
+
+func (r systemRepository) GetSystemByHostname(hostname string) (system.System, error) {
+	return r.find(func(s system.System) bool { return s.Identifiers.Hostname == hostname })
+}
+
+// SetSystem stores the system, and returns repository.ErrConflict if another system has the same name or MAC address, like the database does.
+func (r systemRepository) SetSystem(s system.System) error {
+	for _, existing := range r.systems {
+		if existing.Id == s.Id {
+			continue
+		}
+
+		if existing.Name == s.Name {
+			return repository.ErrConflict
+		}
+
+		for _, m := range s.Identifiers.Interfaces.Macs() {
+			for _, em := range existing.Identifiers.Interfaces.Macs() {
+				if m.String() == em.String() {
+					return repository.ErrConflict
+				}
+			}
+		}
+	}
+
+	r.systems[s.Id] = s
+	return nil
+}
+
+func (r systemRepository) ConsumeNextBoot(id uuid.UUID) (system.NextBoot, error) {
+	s, ok := r.systems[id]
+	if !ok || s.NextBoot == nil {
+		return system.NextBoot{}, repository.ErrNotFound
+	}
+	n := *s.NextBoot
+	s.NextBoot = nil
+	r.systems[id] = s
+	return n, nil
+}
+
+func (r systemRepository) SetInstallToken(id uuid.UUID, tokenHash string) error {
+	r.installTokens[id] = tokenHash
+	return nil
+}
+
+func (r systemRepository) HasInstallToken(id uuid.UUID, tokenHash string) (bool, error) {
+	return r.installTokens[id] == tokenHash, nil
+}
+
+func (r systemRepository) CompleteInstall(tokenHash string, _ time.Time) (uuid.UUID, error) {
+	for id, h := range r.installTokens {
+		if h == tokenHash {
+			delete(r.installTokens, id)
+			return id, nil
+		}
+	}
+	return uuid.Nil, repository.ErrNotFound
+}
+
+// profileRepository is a simple in-memory profile.Repository for testing.
+type profileRepository map[uuid.UUID]profile.Profile
+
+func (r profileRepository) GetProfiles() ([]profile.Profile, error) {
+	var profiles []profile.Profile
+	for _, p := range r {
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+func (r profileRepository) GetProfileById(id uuid.UUID) (profile.Profile, error) {
+	p, ok := r[id]
+	if !ok {
+		return p, repository.ErrNotFound
+	}
+	return p, nil
+}
+
+func (r profileRepository) SetProfile(p profile.Profile) error {
+	r[p.Id] = p
+	return nil
+}
+
+func (r profileRepository) DeleteProfileById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+// settingsRepository is an in-memory settings.Repository for testing.
+type settingsRepository struct {
+	settings settings.Settings
+}
+
+func (r *settingsRepository) GetSettings() (settings.Settings, error) {
+	return r.settings, nil
+}
+
+func (r *settingsRepository) SetSettings(s settings.Settings) error {
+	r.settings = s
+	return nil
+}
+
+// bootEventRepository is an in-memory bootevent.Repository for testing.
+type bootEventRepository struct {
+	events []bootevent.BootEvent
+}
+
+func (r *bootEventRepository) GetBootEventsBySystemId(id uuid.UUID, limit int) ([]bootevent.BootEvent, error) {
+	var events []bootevent.BootEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if r.events[i].System == id {
+			events = append(events, r.events[i])
+		}
+	}
+	return events, nil
+}
+
+func (r *bootEventRepository) RecordBootEvent(e bootevent.BootEvent) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *bootEventRepository) DeleteBootEventsBefore(t time.Time) (int64, error) {
+	return 0, nil
+}
+
+// configTemplateRepository is an in-memory configtemplate.Repository that only supports looking up config templates by name.
+type configTemplateRepository struct {
+	configtemplate.Repository
+	templates []configtemplate.ConfigTemplate
+}
+
+func (r configTemplateRepository) GetConfigTemplateByName(name string) (configtemplate.ConfigTemplate, error) {
+	for _, c := range r.templates {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return configtemplate.ConfigTemplate{}, repository.ErrNotFound
+}
+
+// pxeConfigFixture is a registered system that boots a profile with a kickstart file, which reports the completed installation.
+type pxeConfigFixture struct {
+	router     http.Handler
+	systems    systemRepository
+	bootEvents *bootEventRepository
+	system     system.System
+	other      profile.Profile
+}
+
+func newPxeConfigFixture(t *testing.T) pxeConfigFixture {
+	mac, err := net.ParseMAC("52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("failed to parse MAC address: %v", err)
+	}
+
+	ks := configtemplate.ConfigTemplate{Id: uuid.New(), Name: "ks.cfg", Type: configtemplate.TypeKickstart, Content: "%post\ncurl -X POST {{ .InstallCompleteUrl }}\n%end"}
+	p := profile.Profile{Id: uuid.New(), Name: "Rocky", Kernel: "vmlinuz", Template: "#!ipxe\nkernel {{ .Profile.Kernel }} inst.ks={{ .ConfigUrl \"ks.cfg\" }}\nboot", ConfigTemplates: []uuid.UUID{ks.Id}}
+	other := profile.Profile{Id: uuid.New(), Name: "Other", Kernel: "vmlinuz", Template: p.Template, ConfigTemplates: []uuid.UUID{ks.Id}}
+	s := system.System{Id: uuid.New(), Name: "web01", Profile: p.Id, Identifiers: system.Identifiers{Interfaces: system.Interfaces{{Mac: mac}}}, BootMode: system.BootModeProfile}
+
+	systems := newSystemRepository(s)
+	profiles := profileRepository{p.Id: p, other.Id: other}
+	bootEvents := &bootEventRepository{}
+	h := NewPxeConfigHandlerGroup(systems, profiles, &settingsRepository{}, nil, bootEvents, configTemplateRepository{templates: []configtemplate.ConfigTemplate{ks}}, system.NewTemplateRenderer(), "http://gobble")
+
+	router := chi.NewRouter()
+	router.Get("/api/pxe-config", ErrorHandler(h.GetPxeConfig))
+	router.Post("/api/install-complete", ErrorHandler(h.CompleteInstall))
+	router.Get("/api/config/{name}", ErrorHandler(h.GetConfig))
+	router.Get("/api/systems/{uuid}/config/{name}", ErrorHandler(h.GetSystemConfig))
+
+	return pxeConfigFixture{router: router, systems: systems, bootEvents: bootEvents, system: s, other: other}
+}
+
+func (f pxeConfigFixture) do(method string, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// bootConfigUrl boots the system and returns the path and query of the config URL in the iPXE script it receives.
+func (f pxeConfigFixture) bootConfigUrl(t *testing.T) *url.URL {
+	w := f.do(http.MethodGet, "/api/pxe-config?mac=52:54:00:12:34:56")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetPxeConfig() returned %d: %s", w.Code, w.Body.String())
+	}
+
+	_, after, found := strings.Cut(w.Body.String(), "inst.ks=")
+	if !found {
+		t.Fatalf("GetPxeConfig() returned a script without a config URL: %s", w.Body.String())
+	}
+
+	u, err := url.Parse(strings.Fields(after)[0])
+	if err != nil {
+		t.Fatalf("GetPxeConfig() returned an invalid config URL: %v", err)
+	}
+
+	return u
+}
+
+func TestGetSystemConfigKeepsInstallToken(t *testing.T) {
+	f := newPxeConfigFixture(t)
+	configUrl := f.bootConfigUrl(t)
+
+	token := configUrl.Query().Get("token")
+	if token == "" {
+		t.Fatalf("config URL %s does not contain the install token", configUrl)
+	}
+
+	w := f.do(http.MethodGet, configUrl.RequestURI())
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetSystemConfig() returned %d: %s", w.Code, w.Body.String())
+	}
+
+	if !strings.Contains(w.Body.String(), "token="+token) {
+		t.Fatalf("GetSystemConfig() = %s, expected the install token of the iPXE script", w.Body.String())
+	}
+
+	// Fetching the config template again, e.g. when the installer retries, still works
+	if w := f.do(http.MethodGet, configUrl.RequestURI()); w.Code != http.StatusOK {
+		t.Fatalf("GetSystemConfig() returned %d on retry: %s", w.Code, w.Body.String())
+	}
+
+	if w := f.do(http.MethodPost, "/api/install-complete?token="+url.QueryEscape(token)); w.Code != http.StatusNoContent {
+		t.Fatalf("CompleteInstall() with the token of the iPXE script returned %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetSystemConfigInvalidInstallToken(t *testing.T) {
+	f := newPxeConfigFixture(t)
+	configUrl := f.bootConfigUrl(t)
+
+	for _, token := range []string{"", "invalid"} {
+		q := configUrl.Query()
+		q.Set("token", token)
+		target := configUrl.Path + "?" + q.Encode()
+
+		if w := f.do(http.MethodGet, target); w.Code != http.StatusNotFound {
+			t.Fatalf("GetSystemConfig() with token %q returned %d, expected %d", token, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestGetSystemConfigProfileNotBooted(t *testing.T) {
+	f := newPxeConfigFixture(t)
+	configUrl := f.bootConfigUrl(t)
+
+	// The other profile also has the config template attached, but the system does not boot it
+	q := configUrl.Query()
+	q.Set("profile", f.other.Id.String())
+	target := configUrl.Path + "?" + q.Encode()
+
+	if w := f.do(http.MethodGet, target); w.Code != http.StatusNotFound {
+		t.Fatalf("GetSystemConfig() for a profile the system does not boot returned %d, expected %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetSystemConfigServedNextBoot(t *testing.T) {
+	f := newPxeConfigFixture(t)
+
+	s := f.system
+	s.NextBoot = &system.NextBoot{Profile: f.other.Id}
+	if err := f.systems.SetSystem(s); err != nil {
+		t.Fatalf("SetSystem() = %v", err)
+	}
+
+	// The next boot is cleared as soon as it is served, so the profile of the last served boot is allowed as well
+	configUrl := f.bootConfigUrl(t)
+	if actual := configUrl.Query().Get("profile"); actual != f.other.Id.String() {
+		t.Fatalf("config URL has profile %s, expected %s", actual, f.other.Id)
+	}
+
+	if w := f.do(http.MethodGet, configUrl.RequestURI()); w.Code != http.StatusOK {
+		t.Fatalf("GetSystemConfig() for the served next boot returned %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetPxeConfigTruncatesUserAgent(t *testing.T) {
+	f := newPxeConfigFixture(t)
+
+	// Multibyte characters must not be cut in half, or the boot event can not be stored
+	req := httptest.NewRequest(http.MethodGet, "/api/pxe-config?mac=52:54:00:12:34:56", nil)
+	req.Header.Set("User-Agent", strings.Repeat("é", 300))
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetPxeConfig() returned %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(f.bootEvents.events) != 1 {
+		t.Fatalf("GetPxeConfig() recorded %d boot events, expected 1", len(f.bootEvents.events))
+	}
+
+	if actual := f.bootEvents.events[0].UserAgent; actual != strings.Repeat("é", 256) {
+		t.Fatalf("GetPxeConfig() recorded user agent %q, expected it to be truncated to 256 characters", actual)
+	}
+}
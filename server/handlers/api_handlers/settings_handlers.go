@@ -0,0 +1,102 @@
+package api_handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/evanebb/gobble/api/response"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/settings"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+/*
+ * Request and response structures, and their supporting functions
+ */
+
+// settingsRequest is the JSON representation of settings.Settings that is accepted by the API.
+type settingsRequest struct {
+	DefaultProfile         *uuid.UUID `json:"defaultProfile"`
+	DiscoveryEnabled       bool       `json:"discoveryEnabled"`
+	BootEventRetentionDays uint       `json:"bootEventRetentionDays"`
+}
+
+// settingsResponse is the JSON representation of settings.Settings that is returned by the API.
+type settingsResponse struct {
+	DefaultProfile         *uuid.UUID `json:"defaultProfile"`
+	DiscoveryEnabled       bool       `json:"discoveryEnabled"`
+	BootEventRetentionDays uint       `json:"bootEventRetentionDays"`
+}
+
+// newSettingsResponse accepts settings.Settings, and casts it into a settingsResponse.
+func newSettingsResponse(s settings.Settings) settingsResponse {
+	resp := settingsResponse{
+		DiscoveryEnabled:       s.DiscoveryEnabled,
+		BootEventRetentionDays: s.BootEventRetentionDays,
+	}
+
+	if s.DefaultProfile != uuid.Nil {
+		resp.DefaultProfile = &s.DefaultProfile
+	}
+
+	return resp
+}
+
+/*
+ * HTTP handlers
+ */
+
+// SettingsHandlerGroup is a group of http.HandlerFunc functions related to the application settings
+type SettingsHandlerGroup struct {
+	settingsRepo settings.Repository
+	profileRepo  profile.Repository
+}
+
+func NewSettingsHandlerGroup(sr settings.Repository, pr profile.Repository) SettingsHandlerGroup {
+	return SettingsHandlerGroup{sr, pr}
+}
+
+func (h SettingsHandlerGroup) GetSettings(w http.ResponseWriter, r *http.Request) error {
+	s, err := h.settingsRepo.GetSettings()
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newSettingsResponse(s))
+}
+
+func (h SettingsHandlerGroup) PutSettings(w http.ResponseWriter, r *http.Request) error {
+	var req settingsRequest
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	defaultProfile := uuid.Nil
+	if req.DefaultProfile != nil {
+		defaultProfile = *req.DefaultProfile
+	}
+
+	if defaultProfile != uuid.Nil {
+		_, err = h.profileRepo.GetProfileById(defaultProfile)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return NewHTTPError(fmt.Errorf("default profile [%s] does not exist", defaultProfile), http.StatusBadRequest)
+			}
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+	}
+
+	s := settings.New(defaultProfile, req.DiscoveryEnabled, req.BootEventRetentionDays)
+	err = h.settingsRepo.SetSettings(s)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newSettingsResponse(s))
+}
@@ -5,12 +5,15 @@ import (
 	"errors"
 	"github.com/evanebb/gobble/api/response"
 	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
 	"github.com/evanebb/gobble/repository"
 	"github.com/evanebb/gobble/server/handlers"
 	"github.com/evanebb/gobble/system"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
 	"net"
 	"net/http"
+	"time"
 )
 
 /*
@@ -19,46 +22,251 @@ import (
 
 // systemRequest is the JSON representation of a system.System that is accepted by the API.
 type systemRequest struct {
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	Profile          uuid.UUID `json:"profile"`
-	Mac              string    `json:"mac"`
-	KernelParameters []string  `json:"kernelParameters"`
+	Name             string             `json:"name"`
+	Description      string             `json:"description"`
+	Profile          uuid.UUID          `json:"profile"`
+	Interfaces       []interfaceRequest `json:"interfaces"`
+	SmbiosUuid       string             `json:"smbiosUuid"`
+	Serial           string             `json:"serial"`
+	AssetTag         string             `json:"assetTag"`
+	Hostname         string             `json:"hostname"`
+	KernelParameters []string           `json:"kernelParameters"`
+	MenuProfiles     []uuid.UUID        `json:"menuProfiles"`
+	MenuTimeout      uint               `json:"menuTimeout"`
+	BootMode         string             `json:"bootMode"`
+	Metadata         map[string]string  `json:"metadata"`
 }
 
 // systemResponse is the JSON representation of a system.System that is returned by the API.
 type systemResponse struct {
-	Id               uuid.UUID `json:"id"`
-	Name             string    `json:"name"`
-	Description      string    `json:"description"`
-	Profile          uuid.UUID `json:"profile"`
-	Mac              string    `json:"mac"`
-	KernelParameters []string  `json:"kernelParameters"`
+	Id               uuid.UUID           `json:"id"`
+	Name             string              `json:"name"`
+	Description      string              `json:"description"`
+	Profile          uuid.UUID           `json:"profile"`
+	Interfaces       []interfaceResponse `json:"interfaces"`
+	SmbiosUuid       string              `json:"smbiosUuid"`
+	Serial           string              `json:"serial"`
+	AssetTag         string              `json:"assetTag"`
+	Hostname         string              `json:"hostname"`
+	KernelParameters []string            `json:"kernelParameters"`
+	MenuProfiles     []uuid.UUID         `json:"menuProfiles"`
+	MenuTimeout      uint                `json:"menuTimeout"`
+	BootMode         string              `json:"bootMode"`
+	InstalledAt      *time.Time          `json:"installedAt"`
+	NextBoot         *nextBootResponse   `json:"nextBoot"`
+	Metadata         map[string]string   `json:"metadata"`
 }
 
 // newSystemResponse accepts a system.System, and casts it into a systemResponse.
 func newSystemResponse(sys system.System) systemResponse {
+	var installedAt *time.Time
+	if !sys.InstalledAt.IsZero() {
+		installedAt = &sys.InstalledAt
+	}
+
 	return systemResponse{
 		Id:               sys.Id,
 		Name:             sys.Name,
 		Description:      sys.Description,
 		Profile:          sys.Profile,
-		Mac:              sys.Mac.String(),
+		Interfaces:       newInterfacesResponse(sys.Identifiers.Interfaces),
+		SmbiosUuid:       sys.Identifiers.SmbiosUuidString(),
+		Serial:           sys.Identifiers.Serial,
+		AssetTag:         sys.Identifiers.AssetTag,
+		Hostname:         sys.Identifiers.Hostname,
 		KernelParameters: sys.KernelParameters.StringSlice(),
+		MenuProfiles:     append([]uuid.UUID{}, sys.MenuProfiles...),
+		MenuTimeout:      sys.MenuTimeout,
+		BootMode:         string(sys.BootMode),
+		InstalledAt:      installedAt,
+		NextBoot:         newNextBootResponse(sys.NextBoot),
+		Metadata:         newMetadataResponse(sys.Metadata),
 	}
 }
 
+// identifiers parses the identifiers in the systemRequest into system.Identifiers.
+func (req systemRequest) identifiers() (system.Identifiers, error) {
+	i := system.Identifiers{
+		Serial:   req.Serial,
+		AssetTag: req.AssetTag,
+		Hostname: req.Hostname,
+	}
+
+	for _, ir := range req.Interfaces {
+		iface, err := ir.toInterface()
+		if err != nil {
+			return i, err
+		}
+		i.Interfaces = append(i.Interfaces, iface)
+	}
+
+	if req.SmbiosUuid != "" {
+		smbiosUuid, err := uuid.Parse(req.SmbiosUuid)
+		if err != nil {
+			return i, err
+		}
+		i.SmbiosUuid = smbiosUuid
+	}
+
+	return i, nil
+}
+
+// interfaceRequest is the JSON representation of a system.Interface that is accepted by the API.
+type interfaceRequest struct {
+	Mac         string   `json:"mac"`
+	Name        string   `json:"name"`
+	Boot        bool     `json:"boot"`
+	Address     string   `json:"address"`
+	Gateway     string   `json:"gateway"`
+	Nameservers []string `json:"nameservers"`
+	Hostname    string   `json:"hostname"`
+}
+
+// toInterface parses the interfaceRequest into a system.Interface.
+func (req interfaceRequest) toInterface() (system.Interface, error) {
+	i := system.Interface{Name: req.Name, Boot: req.Boot}
+	var err error
+
+	i.Mac, err = net.ParseMAC(req.Mac)
+	if err != nil {
+		return i, err
+	}
+
+	i.Network.Hostname = req.Hostname
+
+	if req.Address != "" {
+		ip, ipNet, err := net.ParseCIDR(req.Address)
+		if err != nil {
+			return i, err
+		}
+		i.Network.Address = ip
+		i.Network.Netmask = ipNet.Mask
+	}
+
+	if req.Gateway != "" {
+		i.Network.Gateway = net.ParseIP(req.Gateway)
+		if i.Network.Gateway == nil {
+			return i, errors.New("invalid gateway " + req.Gateway)
+		}
+	}
+
+	for _, v := range req.Nameservers {
+		ns := net.ParseIP(v)
+		if ns == nil {
+			return i, errors.New("invalid nameserver " + v)
+		}
+		i.Network.Nameservers = append(i.Network.Nameservers, ns)
+	}
+
+	return i, nil
+}
+
+// newInterfacesRequest accepts system.Interfaces, and casts them into a slice of interfaceRequest.
+// This is used to fill a request with the current values when patching a system.
+func newInterfacesRequest(interfaces system.Interfaces) []interfaceRequest {
+	req := make([]interfaceRequest, 0, len(interfaces))
+	for _, i := range newInterfacesResponse(interfaces) {
+		req = append(req, interfaceRequest(i))
+	}
+	return req
+}
+
+// interfaceResponse is the JSON representation of a system.Interface that is returned by the API.
+type interfaceResponse struct {
+	Mac         string   `json:"mac"`
+	Name        string   `json:"name"`
+	Boot        bool     `json:"boot"`
+	Address     string   `json:"address"`
+	Gateway     string   `json:"gateway"`
+	Nameservers []string `json:"nameservers"`
+	Hostname    string   `json:"hostname"`
+}
+
+// newInterfacesResponse accepts system.Interfaces, and casts them into a slice of interfaceResponse.
+func newInterfacesResponse(interfaces system.Interfaces) []interfaceResponse {
+	resp := make([]interfaceResponse, 0, len(interfaces))
+	for _, i := range interfaces {
+		var gateway string
+		if i.Network.Gateway != nil {
+			gateway = i.Network.Gateway.String()
+		}
+
+		resp = append(resp, interfaceResponse{
+			Mac:         i.Mac.String(),
+			Name:        i.Name,
+			Boot:        i.Boot,
+			Address:     i.Network.AddressString(),
+			Gateway:     gateway,
+			Nameservers: i.Network.NameserverStrings(),
+			Hostname:    i.Network.Hostname,
+		})
+	}
+	return resp
+}
+
+// nextBootRequest is the JSON representation of a system.NextBoot that is accepted by the API.
+type nextBootRequest struct {
+	Profile          *uuid.UUID `json:"profile"`
+	KernelParameters []string   `json:"kernelParameters"`
+}
+
+// nextBootResponse is the JSON representation of a system.NextBoot that is returned by the API.
+type nextBootResponse struct {
+	Profile          *uuid.UUID `json:"profile"`
+	KernelParameters []string   `json:"kernelParameters"`
+}
+
+// newNextBootResponse accepts a system.NextBoot, and casts it into a nextBootResponse. If there is no next boot, nil is returned.
+func newNextBootResponse(n *system.NextBoot) *nextBootResponse {
+	if n == nil {
+		return nil
+	}
+
+	var p *uuid.UUID
+	if n.Profile != uuid.Nil {
+		p = &n.Profile
+	}
+
+	return &nextBootResponse{
+		Profile:          p,
+		KernelParameters: n.KernelParameters.StringSlice(),
+	}
+}
+
+// effectiveKernelParameterResponse is the JSON representation of a kernelparameters.EffectiveKernelParameter that is returned by the API.
+type effectiveKernelParameterResponse struct {
+	Parameter string `json:"parameter"`
+	Source    string `json:"source"`
+}
+
+// newEffectiveKernelParametersResponse accepts kernelparameters.EffectiveKernelParameters, and casts them into a slice of effectiveKernelParameterResponse.
+func newEffectiveKernelParametersResponse(e kernelparameters.EffectiveKernelParameters) []effectiveKernelParameterResponse {
+	resp := make([]effectiveKernelParameterResponse, 0, len(e))
+	for _, p := range e {
+		resp = append(resp, effectiveKernelParameterResponse{
+			Parameter: p.KernelParameter.String(),
+			Source:    p.Source,
+		})
+	}
+	return resp
+}
+
 /*
  * HTTP handlers
  */
 
 // SystemHandlerGroup is a group of http.HandlerFunc functions related to systems
 type SystemHandlerGroup struct {
-	systemRepo system.Repository
+	systemRepo  system.Repository
+	profileRepo profile.Repository
+	builder     system.PxeConfigBuilder
+	externalUrl string
 }
 
-func NewSystemHandlerGroup(sr system.Repository) SystemHandlerGroup {
-	return SystemHandlerGroup{sr}
+// NewSystemHandlerGroup creates a new SystemHandlerGroup. The external URL is used to expand the server variables in kernel parameters;
+// if it is empty, the URL of the incoming request is used instead.
+func NewSystemHandlerGroup(sr system.Repository, pr profile.Repository, externalUrl string) SystemHandlerGroup {
+	return SystemHandlerGroup{sr, pr, system.NewPxeConfigBuilder(pr), externalUrl}
 }
 
 func (h SystemHandlerGroup) GetSystems(w http.ResponseWriter, r *http.Request) error {
@@ -108,19 +316,24 @@ func (h SystemHandlerGroup) CreateSystem(w http.ResponseWriter, r *http.Request)
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	macAddress, err := net.ParseMAC(req.Mac)
+	identifiers, err := req.identifiers()
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	sys, err := system.New(systemId, req.Name, req.Description, req.Profile, macAddress, kp)
+	bootMode, err := system.ParseBootMode(req.BootMode)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	err = h.systemRepo.SetSystem(sys)
+	sys, err := system.New(systemId, req.Name, req.Description, req.Profile, identifiers, kp, req.MenuProfiles, req.MenuTimeout, bootMode, req.Metadata)
 	if err != nil {
-		return NewHTTPError(err, http.StatusInternalServerError)
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.setSystem(sys)
+	if err != nil {
+		return err
 	}
 
 	return response.Success(w, http.StatusCreated, newSystemResponse(sys))
@@ -146,19 +359,24 @@ func (h SystemHandlerGroup) PutSystem(w http.ResponseWriter, r *http.Request) er
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	macAddress, err := net.ParseMAC(req.Mac)
+	identifiers, err := req.identifiers()
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	sys, err := system.New(systemId, req.Name, req.Description, req.Profile, macAddress, kp)
+	bootMode, err := system.ParseBootMode(req.BootMode)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	err = h.systemRepo.SetSystem(sys)
+	sys, err := system.New(systemId, req.Name, req.Description, req.Profile, identifiers, kp, req.MenuProfiles, req.MenuTimeout, bootMode, req.Metadata)
 	if err != nil {
-		return NewHTTPError(err, http.StatusInternalServerError)
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.setSystem(sys)
+	if err != nil {
+		return err
 	}
 
 	return response.Success(w, http.StatusOK, newSystemResponse(sys))
@@ -180,8 +398,15 @@ func (h SystemHandlerGroup) PatchSystem(w http.ResponseWriter, r *http.Request)
 		Name:             sys.Name,
 		Description:      sys.Description,
 		Profile:          sys.Profile,
-		Mac:              sys.Mac.String(),
+		Interfaces:       newInterfacesRequest(sys.Identifiers.Interfaces),
+		SmbiosUuid:       sys.Identifiers.SmbiosUuidString(),
+		Serial:           sys.Identifiers.Serial,
+		AssetTag:         sys.Identifiers.AssetTag,
+		Hostname:         sys.Identifiers.Hostname,
 		KernelParameters: sys.KernelParameters.StringSlice(),
+		MenuProfiles:     sys.MenuProfiles,
+		MenuTimeout:      sys.MenuTimeout,
+		BootMode:         string(sys.BootMode),
 	}
 
 	// Decode the request body into the current system;
@@ -194,30 +419,53 @@ func (h SystemHandlerGroup) PatchSystem(w http.ResponseWriter, r *http.Request)
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
+	// The metadata is left out of the current values, since decoding into a map would merge it instead of replacing it
+	if req.Metadata == nil {
+		req.Metadata = sys.Metadata
+	}
+
 	kp, err := kernelparameters.ParseStringSlice(req.KernelParameters)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	macAddress, err := net.ParseMAC(req.Mac)
+	identifiers, err := req.identifiers()
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	bootMode, err := system.ParseBootMode(req.BootMode)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
 	// Map the DTO back to the model, this time with the newly supplied values from the request body
-	sys, err = system.New(systemId, req.Name, req.Description, req.Profile, macAddress, kp)
+	sys, err = system.New(systemId, req.Name, req.Description, req.Profile, identifiers, kp, req.MenuProfiles, req.MenuTimeout, bootMode, req.Metadata)
 	if err != nil {
 		return NewHTTPError(err, http.StatusBadRequest)
 	}
 
-	err = h.systemRepo.SetSystem(sys)
+	err = h.setSystem(sys)
 	if err != nil {
-		return NewHTTPError(err, http.StatusInternalServerError)
+		return err
 	}
 
 	return response.Success(w, http.StatusOK, newSystemResponse(sys))
 }
 
+// setSystem stores the system. The name and the identifiers of a system are unique, so a 409 is returned if another system already uses one of them.
+func (h SystemHandlerGroup) setSystem(s system.System) error {
+	err := h.systemRepo.SetSystem(s)
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return NewHTTPError(err, http.StatusConflict)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
 func (h SystemHandlerGroup) DeleteSystem(w http.ResponseWriter, r *http.Request) error {
 	systemId, err := handlers.GetUUIDFromRequest(r)
 	if err != nil {
@@ -231,3 +479,118 @@ func (h SystemHandlerGroup) DeleteSystem(w http.ResponseWriter, r *http.Request)
 
 	return response.Success(w, http.StatusNoContent, nil)
 }
+
+// GetEffectiveKernelParameters returns the kernel parameters that the system will boot with,
+// after merging the parameters of its profile and its own, together with where every parameter came from.
+// They are built the same way as for the iPXE script, so inherited parameters are included and variables are expanded.
+func (h SystemHandlerGroup) GetEffectiveKernelParameters(w http.ResponseWriter, r *http.Request) error {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	sys, err := h.systemRepo.GetSystemById(systemId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	// The kernel parameters are not requested by the system itself, so its IP address is unknown.
+	// A pending NextBoot is included, since it is what the system boots next, just like in the iPXE script.
+	pxeConfig, err := h.builder.BuildForProfile(sys, sys.BootProfile(), system.BootRequest{ServerUrl: handlers.GetExternalUrl(r, h.externalUrl)})
+	if err != nil {
+		// Referencing a variable that does not exist is a configuration error
+		var unknown variables.UnknownVariableError
+		if errors.As(err, &unknown) {
+			return NewHTTPError(err, http.StatusUnprocessableEntity)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newEffectiveKernelParametersResponse(pxeConfig.KernelParameters))
+}
+
+// GetNextBoot returns the pending one-shot boot action of the system, or null if there is none.
+func (h SystemHandlerGroup) GetNextBoot(w http.ResponseWriter, r *http.Request) error {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	sys, err := h.systemRepo.GetSystemById(systemId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newNextBootResponse(sys.NextBoot))
+}
+
+// PutNextBoot sets the one-shot boot action of the system, which is served the next time it boots and then cleared.
+func (h SystemHandlerGroup) PutNextBoot(w http.ResponseWriter, r *http.Request) error {
+	var req nextBootRequest
+
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&req)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	kp, err := kernelparameters.ParseStringSlice(req.KernelParameters)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	profileId := uuid.Nil
+	if req.Profile != nil {
+		profileId = *req.Profile
+
+		_, err = h.profileRepo.GetProfileById(profileId)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return NewHTTPError(errors.New("profile "+profileId.String()+" does not exist"), http.StatusBadRequest)
+			}
+			return NewHTTPError(err, http.StatusInternalServerError)
+		}
+	}
+
+	n, err := system.NewNextBoot(profileId, kp)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.systemRepo.SetNextBoot(systemId, n)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return NewHTTPError(err, http.StatusNotFound)
+		}
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusOK, newNextBootResponse(&n))
+}
+
+// DeleteNextBoot cancels the pending one-shot boot action of the system.
+func (h SystemHandlerGroup) DeleteNextBoot(w http.ResponseWriter, r *http.Request) error {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		return NewHTTPError(err, http.StatusBadRequest)
+	}
+
+	err = h.systemRepo.ClearNextBoot(systemId)
+	if err != nil {
+		return NewHTTPError(err, http.StatusInternalServerError)
+	}
+
+	return response.Success(w, http.StatusNoContent, nil)
+}
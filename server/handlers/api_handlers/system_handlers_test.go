@@ -0,0 +1,126 @@
+package api_handlers
+
+import (
+	"encoding/json"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/system"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParseKernelParameters(t *testing.T, s string) kernelparameters.KernelParameters {
+	kp, err := kernelparameters.ParseString(s)
+	if err != nil {
+		t.Fatalf("failed to parse kernel parameters %q: %v", s, err)
+	}
+	return kp
+}
+
+func TestGetEffectiveKernelParametersInherited(t *testing.T) {
+	parent := profile.Profile{Id: uuid.New(), Name: "Parent", Kernel: "vmlinuz", KernelParameters: mustParseKernelParameters(t, "console=ttyS0 quiet")}
+	child := profile.Profile{Id: uuid.New(), Name: "Child", Parent: parent.Id, KernelParameters: mustParseKernelParameters(t, "inst.repo=${server_url}/repo/${profile}")}
+	s := system.System{Id: uuid.New(), Name: "web01", Profile: child.Id, KernelParameters: mustParseKernelParameters(t, "!quiet"), BootMode: system.BootModeProfile}
+
+	actual := getEffectiveKernelParameters(t, s, profileRepository{parent.Id: parent, child.Id: child})
+
+	// The parameters of the parent are inherited, and the variables are expanded like in the iPXE script
+	expected := []effectiveKernelParameterResponse{
+		{Parameter: "console=ttyS0", Source: "profile:Child"},
+		{Parameter: "inst.repo=http://gobble/repo/Child", Source: "profile:Child"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("GetEffectiveKernelParameters() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestGetEffectiveKernelParametersNextBoot(t *testing.T) {
+	p := profile.Profile{Id: uuid.New(), Name: "Rocky", Kernel: "vmlinuz", KernelParameters: mustParseKernelParameters(t, "quiet")}
+	rescue := profile.Profile{Id: uuid.New(), Name: "Rescue", Kernel: "vmlinuz", KernelParameters: mustParseKernelParameters(t, "rescue")}
+	profiles := profileRepository{p.Id: p, rescue.Id: rescue}
+	s := system.System{Id: uuid.New(), Name: "web01", Profile: p.Id, BootMode: system.BootModeProfile}
+
+	// The pending next boot is shown with the profile it boots, never with the regular profile of the system
+	s.NextBoot = &system.NextBoot{Profile: rescue.Id, KernelParameters: mustParseKernelParameters(t, "rd.break")}
+	actual := getEffectiveKernelParameters(t, s, profiles)
+	expected := []effectiveKernelParameterResponse{
+		{Parameter: "rescue", Source: "profile:Rescue"},
+		{Parameter: "rd.break", Source: "next-boot"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("GetEffectiveKernelParameters() = %v, expected %v", actual, expected)
+	}
+
+	// A next boot without a profile adds its parameters to the regular profile
+	s.NextBoot = &system.NextBoot{KernelParameters: mustParseKernelParameters(t, "rd.break")}
+	actual = getEffectiveKernelParameters(t, s, profiles)
+	expected = []effectiveKernelParameterResponse{
+		{Parameter: "quiet", Source: "profile:Rocky"},
+		{Parameter: "rd.break", Source: "next-boot"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("GetEffectiveKernelParameters() = %v, expected %v", actual, expected)
+	}
+}
+
+// getEffectiveKernelParameters requests the effective kernel parameters of the system, and returns the ones in the response.
+func getEffectiveKernelParameters(t *testing.T, s system.System, profiles profileRepository) []effectiveKernelParameterResponse {
+	h := NewSystemHandlerGroup(newSystemRepository(s), profiles, "http://gobble")
+	router := chi.NewRouter()
+	router.Get("/api/systems/{uuid}/kernel-parameters", ErrorHandler(h.GetEffectiveKernelParameters))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/systems/"+s.Id.String()+"/kernel-parameters", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetEffectiveKernelParameters() returned %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Data []effectiveKernelParameterResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("GetEffectiveKernelParameters() returned invalid JSON: %v", err)
+	}
+
+	return resp.Data
+}
+
+func TestSetSystemConflict(t *testing.T) {
+	mac, err := net.ParseMAC("52:54:00:12:34:56")
+	if err != nil {
+		t.Fatalf("failed to parse MAC address: %v", err)
+	}
+
+	existing := system.System{Id: uuid.New(), Name: "web01", Identifiers: system.Identifiers{Interfaces: system.Interfaces{{Mac: mac, Boot: true}}}}
+	other := system.System{Id: uuid.New(), Name: "web02"}
+	h := NewSystemHandlerGroup(newSystemRepository(existing, other), profileRepository{}, "")
+	router := chi.NewRouter()
+	router.Post("/api/systems", ErrorHandler(h.CreateSystem))
+	router.Put("/api/systems/{uuid}", ErrorHandler(h.PutSystem))
+
+	profileId := uuid.NewString()
+	cases := []struct {
+		method string
+		target string
+		body   string
+	}{
+		// Another system already has the MAC address
+		{http.MethodPost, "/api/systems", `{"name": "web03", "profile": "` + profileId + `", "interfaces": [{"mac": "52:54:00:12:34:56", "boot": true}]}`},
+		// Another system already has the name
+		{http.MethodPut, "/api/systems/" + other.Id.String(), `{"name": "web01", "profile": "` + profileId + `", "serial": "ABC1234"}`},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(c.method, c.target, strings.NewReader(c.body)))
+		if w.Code != http.StatusConflict {
+			t.Fatalf("%s %s with %s returned %d, expected %d: %s", c.method, c.target, c.body, w.Code, http.StatusConflict, w.Body.String())
+		}
+	}
+}
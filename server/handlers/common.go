@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"net"
 	"net/http"
 )
 
@@ -16,3 +17,33 @@ func GetUUIDFromRequest(r *http.Request) (uuid.UUID, error) {
 	}
 	return UUID, nil
 }
+
+// GetClientIPFromRequest returns the IP address of the client that sent the request, or nil if it cannot be determined.
+func GetClientIPFromRequest(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return net.ParseIP(host)
+}
+
+// GetBaseUrlFromRequest returns the URL that the client used to reach the application, e.g. 'http://gobble.example.local'.
+func GetBaseUrlFromRequest(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
+// GetExternalUrl returns the URL that systems and browsers can reach the application on, which is the passed external URL
+// if it is set, and the URL that the client used to reach the application otherwise.
+func GetExternalUrl(r *http.Request, externalUrl string) string {
+	if externalUrl != "" {
+		return externalUrl
+	}
+
+	return GetBaseUrlFromRequest(r)
+}
@@ -1,6 +1,9 @@
 package handlers
 
-import "net/http"
+import (
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+)
 
 // MethodOverride will check for a hidden "_method" input in the POST form,
 // so that PUT, PATCH and DELETE requests can be supported
@@ -17,3 +20,31 @@ func MethodOverride(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Logger logs every request like middleware.Logger, but hides the value of the 'token' query parameter.
+// Systems pass their install token in it, since iPXE and installers can only fetch plain URLs, and it must not end up in the logs.
+func Logger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestUri := r.RequestURI
+		logged := middleware.Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Only the logger sees the redacted URI, the handlers receive the original one
+			r.RequestURI = requestUri
+			next.ServeHTTP(w, r)
+		}))
+
+		lr := r.WithContext(r.Context())
+		lr.RequestURI = redactedRequestUri(r)
+		logged.ServeHTTP(w, lr)
+	})
+}
+
+// redactedRequestUri returns the request URI of the request with the value of the 'token' query parameter replaced, so it can be logged.
+func redactedRequestUri(r *http.Request) string {
+	q := r.URL.Query()
+	if !q.Has("token") {
+		return r.RequestURI
+	}
+
+	q.Set("token", "REDACTED")
+	return r.URL.EscapedPath() + "?" + q.Encode()
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoggerRedactsToken(t *testing.T) {
+	var received string
+	h := Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.Query().Get("token")
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/install-complete?token=secret", nil)
+	if actual := redactedRequestUri(r); actual != "/api/install-complete?token=REDACTED" {
+		t.Fatalf("redactedRequestUri() = %s, expected the token to be redacted", actual)
+	}
+
+	// The handler still receives the token itself
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if received != "secret" {
+		t.Fatalf("Logger() passed token %q to the handler, expected %q", received, "secret")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/pxe-config?mac=52:54:00:12:34:56", nil)
+	if actual := redactedRequestUri(r); actual != r.RequestURI {
+		t.Fatalf("redactedRequestUri() = %s, expected %s", actual, r.RequestURI)
+	}
+}
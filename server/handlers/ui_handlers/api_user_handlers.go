@@ -0,0 +1,184 @@
+package ui_handlers
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+)
+
+// parseApiUserFromPostForm parses the user in the POST form. The password is left empty if none was entered,
+// so the caller can keep the current password when updating a user.
+func parseApiUserFromPostForm(r *http.Request) (auth.ApiUser, error) {
+	var a auth.ApiUser
+
+	err := r.ParseForm()
+	if err != nil {
+		return a, err
+	}
+
+	requiredKeys := []string{"name", "password", "role"}
+	for _, v := range requiredKeys {
+		if !r.PostForm.Has(v) {
+			return a, errors.New("missing value " + v + " in POST form")
+		}
+	}
+
+	role, err := auth.ParseRole(r.PostFormValue("role"))
+	if err != nil {
+		return a, err
+	}
+
+	var pass []byte
+	if v := r.PostFormValue("password"); v != "" {
+		pass, err = bcrypt.GenerateFromPassword([]byte(v), bcrypt.DefaultCost)
+		if err != nil {
+			return a, err
+		}
+	}
+
+	// the UUID needs to be set properly afterward by the caller, depending on whether we are creating a new one or updating an existing one
+	return auth.NewApiUser(uuid.Nil, r.PostFormValue("name"), pass, role), nil
+}
+
+type UiApiUserHandlerGroup struct {
+	apiUserRepo auth.ApiUserRepository
+}
+
+func NewUiApiUserHandlerGroup(ar auth.ApiUserRepository) UiApiUserHandlerGroup {
+	return UiApiUserHandlerGroup{ar}
+}
+
+// Overview will list all users.
+func (h UiApiUserHandlerGroup) Overview(w http.ResponseWriter, r *http.Request) {
+	users, err := h.apiUserRepo.GetApiUsers()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Users", Data: users}
+	renderTemplate(w, r, "users/overview", d)
+}
+
+// Create shows the page for creating a new user.
+func (h UiApiUserHandlerGroup) Create(w http.ResponseWriter, r *http.Request) {
+	d := templateData{Title: "Create user", Data: struct {
+		Roles []auth.Role
+	}{
+		Roles: auth.Roles,
+	}}
+	renderTemplate(w, r, "users/create", d)
+}
+
+// Store will store a newly created user.
+func (h UiApiUserHandlerGroup) Store(w http.ResponseWriter, r *http.Request) {
+	a, err := parseApiUserFromPostForm(r)
+	if err != nil || len(a.Password) == 0 {
+		renderError(w)
+		return
+	}
+
+	a.Id = uuid.New()
+
+	err = h.apiUserRepo.SetApiUser(a)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/users", http.StatusSeeOther)
+}
+
+// Edit shows the page for editing an existing user.
+func (h UiApiUserHandlerGroup) Edit(w http.ResponseWriter, r *http.Request) {
+	userId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	a, err := h.apiUserRepo.GetApiUserById(userId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Edit User", Data: struct {
+		User  auth.ApiUser
+		Roles []auth.Role
+	}{
+		User:  a,
+		Roles: auth.Roles,
+	}}
+	renderTemplate(w, r, "users/edit", d)
+}
+
+// Update will update the specified user.
+func (h UiApiUserHandlerGroup) Update(w http.ResponseWriter, r *http.Request) {
+	userId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	a, err := parseApiUserFromPostForm(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	a.Id = userId
+
+	current, err := h.apiUserRepo.GetApiUserById(userId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	// An empty password means that the current one is kept
+	if len(a.Password) == 0 {
+		a.Password = current.Password
+	}
+
+	if a.Role != auth.RoleAdmin {
+		isLastAdmin, err := auth.IsLastAdmin(h.apiUserRepo, userId)
+		if err != nil || isLastAdmin {
+			renderError(w)
+			return
+		}
+	}
+
+	err = h.apiUserRepo.SetApiUser(a)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/users", http.StatusSeeOther)
+}
+
+// Delete will delete the specified user.
+func (h UiApiUserHandlerGroup) Delete(w http.ResponseWriter, r *http.Request) {
+	userId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	isLastAdmin, err := auth.IsLastAdmin(h.apiUserRepo, userId)
+	if err != nil || isLastAdmin {
+		renderError(w)
+		return
+	}
+
+	err = h.apiUserRepo.DeleteApiUserById(userId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/users", http.StatusSeeOther)
+}
@@ -0,0 +1,193 @@
+package ui_handlers
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/configtemplate"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func parseConfigTemplateFromPostForm(r *http.Request) (configtemplate.ConfigTemplate, error) {
+	var c configtemplate.ConfigTemplate
+
+	err := r.ParseForm()
+	if err != nil {
+		return c, err
+	}
+
+	requiredKeys := []string{"name", "description", "type", "content"}
+	for _, v := range requiredKeys {
+		if !r.PostForm.Has(v) {
+			return c, errors.New("missing value " + v + " in POST form")
+		}
+	}
+
+	t, err := configtemplate.ParseType(r.PostFormValue("type"))
+	if err != nil {
+		return c, err
+	}
+
+	return configtemplate.New(
+		// the UUID needs to be set properly afterward by the caller, depending on whether we are creating a new one or updating an existing one
+		uuid.Nil,
+		r.PostFormValue("name"),
+		r.PostFormValue("description"),
+		t,
+		r.PostFormValue("content"),
+	)
+}
+
+type UiConfigTemplateHandlerGroup struct {
+	configTemplateRepo configtemplate.Repository
+}
+
+func NewUiConfigTemplateHandlerGroup(cr configtemplate.Repository) UiConfigTemplateHandlerGroup {
+	return UiConfigTemplateHandlerGroup{cr}
+}
+
+// Overview will list all config templates.
+func (h UiConfigTemplateHandlerGroup) Overview(w http.ResponseWriter, r *http.Request) {
+	templates, err := h.configTemplateRepo.GetConfigTemplates()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Config templates", Data: templates}
+	renderTemplate(w, r, "config-templates/overview", d)
+}
+
+// Show will show information about a single config template.
+func (h UiConfigTemplateHandlerGroup) Show(w http.ResponseWriter, r *http.Request) {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	c, err := h.configTemplateRepo.GetConfigTemplateById(configTemplateId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Config Template Information", Data: c}
+	renderTemplate(w, r, "config-templates/show", d)
+}
+
+// Create shows the page for creating a new config template.
+func (h UiConfigTemplateHandlerGroup) Create(w http.ResponseWriter, r *http.Request) {
+	d := templateData{Title: "Create config template", Data: struct {
+		Types []configtemplate.Type
+	}{
+		Types: configtemplate.Types,
+	}}
+	renderTemplate(w, r, "config-templates/create", d)
+}
+
+// Store will store a newly created config template.
+func (h UiConfigTemplateHandlerGroup) Store(w http.ResponseWriter, r *http.Request) {
+	c, err := parseConfigTemplateFromPostForm(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	c.Id = uuid.New()
+
+	if !h.nameAvailable(c) {
+		renderError(w)
+		return
+	}
+
+	err = h.configTemplateRepo.SetConfigTemplate(c)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/config-templates/"+c.Id.String(), http.StatusSeeOther)
+}
+
+// Edit shows the page for editing an existing config template.
+func (h UiConfigTemplateHandlerGroup) Edit(w http.ResponseWriter, r *http.Request) {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	c, err := h.configTemplateRepo.GetConfigTemplateById(configTemplateId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Edit Config Template", Data: struct {
+		ConfigTemplate configtemplate.ConfigTemplate
+		Types          []configtemplate.Type
+	}{
+		ConfigTemplate: c,
+		Types:          configtemplate.Types,
+	}}
+	renderTemplate(w, r, "config-templates/edit", d)
+}
+
+// Update will update the specified config template.
+func (h UiConfigTemplateHandlerGroup) Update(w http.ResponseWriter, r *http.Request) {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	c, err := parseConfigTemplateFromPostForm(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	c.Id = configTemplateId
+
+	if !h.nameAvailable(c) {
+		renderError(w)
+		return
+	}
+
+	err = h.configTemplateRepo.SetConfigTemplate(c)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/config-templates/"+c.Id.String(), http.StatusSeeOther)
+}
+
+// Delete will delete the specified config template.
+func (h UiConfigTemplateHandlerGroup) Delete(w http.ResponseWriter, r *http.Request) {
+	configTemplateId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = h.configTemplateRepo.DeleteConfigTemplateById(configTemplateId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/config-templates", http.StatusSeeOther)
+}
+
+// nameAvailable returns whether no other config template has the same name, since config templates are served by their name.
+func (h UiConfigTemplateHandlerGroup) nameAvailable(c configtemplate.ConfigTemplate) bool {
+	existing, err := h.configTemplateRepo.GetConfigTemplateByName(c.Name)
+	if err != nil {
+		return errors.Is(err, repository.ErrNotFound)
+	}
+
+	return existing.Id == c.Id
+}
@@ -0,0 +1,156 @@
+package ui_handlers
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/discovery"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/server/handlers"
+	"github.com/evanebb/gobble/system"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// parsePromotedSystemFromPostForm parses the system that the passed discovery.DiscoveredSystem is promoted into from the POST form.
+func parsePromotedSystemFromPostForm(r *http.Request, d discovery.DiscoveredSystem) (system.System, error) {
+	var s system.System
+
+	err := r.ParseForm()
+	if err != nil {
+		return s, err
+	}
+
+	requiredKeys := []string{"name", "description", "profile", "kernelParameters"}
+	for _, v := range requiredKeys {
+		if !r.PostForm.Has(v) {
+			return s, errors.New("missing value " + v + " in POST form")
+		}
+	}
+
+	kp, err := kernelparameters.ParseString(r.PostFormValue("kernelParameters"))
+	if err != nil {
+		return s, err
+	}
+
+	profileId, err := uuid.Parse(r.PostFormValue("profile"))
+	if err != nil {
+		return s, err
+	}
+
+	return system.New(
+		uuid.New(),
+		r.PostFormValue("name"),
+		r.PostFormValue("description"),
+		profileId,
+		system.Identifiers{Interfaces: system.Interfaces{{Mac: d.Mac, Boot: true}}, SmbiosUuid: d.ParsedSmbiosUuid(), Serial: d.Serial}.WithoutPlaceholders(),
+		kp,
+		nil,
+		0,
+		system.BootModeProfile,
+		nil,
+	)
+}
+
+type UiDiscoveryHandlerGroup struct {
+	discoveryRepo discovery.Repository
+	systemRepo    system.Repository
+	profileRepo   profile.Repository
+}
+
+func NewUiDiscoveryHandlerGroup(dr discovery.Repository, sr system.Repository, pr profile.Repository) UiDiscoveryHandlerGroup {
+	return UiDiscoveryHandlerGroup{dr, sr, pr}
+}
+
+// Overview will list all discovered systems.
+func (h UiDiscoveryHandlerGroup) Overview(w http.ResponseWriter, r *http.Request) {
+	discovered, err := h.discoveryRepo.GetDiscoveredSystems()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Discovered systems", Data: discovered}
+	renderTemplate(w, r, "discovered-systems/overview", d)
+}
+
+// Promote shows the page for promoting a discovered system into a system.
+func (h UiDiscoveryHandlerGroup) Promote(w http.ResponseWriter, r *http.Request) {
+	discoveredId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	ds, err := h.discoveryRepo.GetDiscoveredSystemById(discoveredId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	profiles, err := h.profileRepo.GetProfiles()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Promote discovered system", Data: struct {
+		DiscoveredSystem discovery.DiscoveredSystem
+		Profiles         []profile.Profile
+	}{
+		DiscoveredSystem: ds,
+		Profiles:         profiles,
+	}}
+	renderTemplate(w, r, "discovered-systems/promote", d)
+}
+
+// StorePromoted will store the system that the discovered system is promoted into, and remove the discovered system.
+func (h UiDiscoveryHandlerGroup) StorePromoted(w http.ResponseWriter, r *http.Request) {
+	discoveredId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	ds, err := h.discoveryRepo.GetDiscoveredSystemById(discoveredId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	s, err := parsePromotedSystemFromPostForm(r, ds)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = h.systemRepo.SetSystem(s)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = h.discoveryRepo.DeleteDiscoveredSystemById(ds.Id)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/systems/"+s.Id.String(), http.StatusSeeOther)
+}
+
+// Delete will delete the specified discovered system.
+func (h UiDiscoveryHandlerGroup) Delete(w http.ResponseWriter, r *http.Request) {
+	discoveredId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = h.discoveryRepo.DeleteDiscoveredSystemById(discoveredId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/discovered-systems", http.StatusSeeOther)
+}
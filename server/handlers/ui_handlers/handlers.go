@@ -4,10 +4,10 @@ import "net/http"
 
 func PageNotFound(w http.ResponseWriter, r *http.Request) {
 	d := templateData{Title: "Page not found", DisableNavbar: true}
-	renderTemplate(w, "errors/404", d)
+	renderTemplate(w, r, "errors/404", d)
 }
 
 func HomePage(w http.ResponseWriter, r *http.Request) {
 	d := templateData{Title: "Home"}
-	renderTemplate(w, "home", d)
+	renderTemplate(w, r, "home", d)
 }
@@ -0,0 +1,123 @@
+package ui_handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/api/auth/oidc"
+	"github.com/evanebb/gobble/server/handlers"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// oidcLoginCookieName is the name of the cookie that remembers the login request while the user logs in at the provider.
+	oidcLoginCookieName = "gobble_oidc_login"
+	// oidcLoginPath is the path that the login flow lives under, which the login cookie is restricted to.
+	oidcLoginPath = "/ui/login/oidc"
+)
+
+type UiOidcHandlerGroup struct {
+	provider      *oidc.Provider
+	sessionRepo   auth.SessionRepository
+	secureCookies bool
+	externalUrl   string
+}
+
+// NewUiOidcHandlerGroup creates a new UiOidcHandlerGroup. The external URL is used to build the URL that the provider redirects back to;
+// if it is empty, the URL of the incoming request is used instead.
+func NewUiOidcHandlerGroup(p *oidc.Provider, sr auth.SessionRepository, secureCookies bool, externalUrl string) UiOidcHandlerGroup {
+	return UiOidcHandlerGroup{p, sr, secureCookies, externalUrl}
+}
+
+// Login will redirect the user to the provider to log in.
+func (h UiOidcHandlerGroup) Login(w http.ResponseWriter, r *http.Request) {
+	l, err := oidc.NewLoginRequest()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	// The page to return to is stored alongside the login request, since the provider only passes the state back
+	next := base64.RawURLEncoding.EncodeToString([]byte(safeNext(r.URL.Query().Get("next"))))
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookieName,
+		Value:    strings.Join([]string{l.State, l.Nonce, l.Verifier, next}, "."),
+		Path:     oidcLoginPath,
+		MaxAge:   int((10 * time.Minute).Seconds()),
+		Secure:   h.secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, h.provider.AuthCodeUrl(l, h.redirectUrl(r)), http.StatusSeeOther)
+}
+
+// Callback will finish logging in after the provider redirects back, and start a new session if the user has a role.
+func (h UiOidcHandlerGroup) Callback(w http.ResponseWriter, r *http.Request) {
+	l, next, ok := h.loginRequest(r)
+
+	// The login request can only be used once
+	http.SetCookie(w, &http.Cookie{Name: oidcLoginCookieName, Path: oidcLoginPath, MaxAge: -1, Secure: h.secureCookies, HttpOnly: true})
+
+	q := r.URL.Query()
+	if !ok || q.Get("error") != "" || q.Get("state") != l.State {
+		h.loginFailed(w, r, next, "Logging in with single sign-on failed, please try again.")
+		return
+	}
+
+	u, err := h.provider.Exchange(r.Context(), l, q.Get("code"), h.redirectUrl(r))
+	if err != nil {
+		if errors.Is(err, oidc.ErrNoRole) {
+			h.loginFailed(w, r, next, "You do not have access to gobble.")
+			return
+		}
+
+		log.Printf("OpenID Connect login failed: %s", err)
+		h.loginFailed(w, r, next, "Logging in with single sign-on failed, please try again.")
+		return
+	}
+
+	s, token, err := auth.StartExternalSession(h.sessionRepo, u, time.Now())
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	auth.SetSessionCookie(w, token, s.ExpiresAt, h.secureCookies)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// loginRequest returns the login request and the page to return to that are stored in the login cookie, and whether there is a valid one.
+func (h UiOidcHandlerGroup) loginRequest(r *http.Request) (oidc.LoginRequest, string, bool) {
+	cookie, err := r.Cookie(oidcLoginCookieName)
+	if err != nil {
+		return oidc.LoginRequest{}, "/ui/", false
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 || parts[0] == "" {
+		return oidc.LoginRequest{}, "/ui/", false
+	}
+
+	next, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return oidc.LoginRequest{}, "/ui/", false
+	}
+
+	return oidc.LoginRequest{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, safeNext(string(next)), true
+}
+
+// loginFailed shows the login page with the passed message.
+func (h UiOidcHandlerGroup) loginFailed(w http.ResponseWriter, r *http.Request, next string, message string) {
+	w.WriteHeader(http.StatusUnauthorized)
+	d := templateData{Title: "Log in", DisableNavbar: true, Data: loginData{Next: next, Error: message, OidcEnabled: true}}
+	renderTemplate(w, r, "login", d)
+}
+
+// redirectUrl returns the URL that the provider redirects back to after logging in.
+func (h UiOidcHandlerGroup) redirectUrl(r *http.Request) string {
+	return handlers.GetExternalUrl(r, h.externalUrl) + oidcLoginPath + "/callback"
+}
@@ -2,9 +2,12 @@ package ui_handlers
 
 import (
 	"errors"
+	"github.com/evanebb/gobble/configtemplate"
 	"github.com/evanebb/gobble/kernelparameters"
 	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/repository"
 	"github.com/evanebb/gobble/server/handlers"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
 	"net/http"
 )
@@ -17,7 +20,7 @@ func parseProfileFromPostForm(r *http.Request) (profile.Profile, error) {
 		return p, err
 	}
 
-	requiredKeys := []string{"name", "description", "kernel", "initrd", "kernelParameters"}
+	requiredKeys := []string{"name", "description", "kernel", "initrds", "kernelParameters", "template", "networkStyle", "parent", "metadata"}
 	for _, v := range requiredKeys {
 		if !r.PostForm.Has(v) {
 			return p, errors.New("missing value " + v + " in POST form")
@@ -29,23 +32,59 @@ func parseProfileFromPostForm(r *http.Request) (profile.Profile, error) {
 		return p, err
 	}
 
+	initrds, err := profile.ParseInitrds(r.PostFormValue("initrds"))
+	if err != nil {
+		return p, err
+	}
+
+	metadata, err := variables.ParseMetadata(r.PostFormValue("metadata"))
+	if err != nil {
+		return p, err
+	}
+
+	// An empty parent means the profile does not inherit from another profile
+	parent := uuid.Nil
+	if v := r.PostFormValue("parent"); v != "" {
+		parent, err = uuid.Parse(v)
+		if err != nil {
+			return p, err
+		}
+	}
+
+	// A multiple select does not send anything if no options are selected, so the config templates are optional
+	var configTemplates []uuid.UUID
+	for _, v := range r.PostForm["configTemplates"] {
+		configTemplateId, err := uuid.Parse(v)
+		if err != nil {
+			return p, err
+		}
+
+		configTemplates = append(configTemplates, configTemplateId)
+	}
+
 	return profile.New(
 		// the UUID needs to be set properly afterward by the caller, depending on whether we are creating a new one or updating an existing one
 		uuid.Nil,
 		r.PostFormValue("name"),
 		r.PostFormValue("description"),
 		r.PostFormValue("kernel"),
-		r.PostFormValue("initrd"),
+		initrds,
 		kp,
+		r.PostFormValue("template"),
+		profile.NetworkStyle(r.PostFormValue("networkStyle")),
+		parent,
+		metadata,
+		configTemplates,
 	)
 }
 
 type UiProfileHandlerGroup struct {
-	profileRepo profile.Repository
+	profileRepo        profile.Repository
+	configTemplateRepo configtemplate.Repository
 }
 
-func NewUiProfileHandlerGroup(pr profile.Repository) UiProfileHandlerGroup {
-	return UiProfileHandlerGroup{pr}
+func NewUiProfileHandlerGroup(pr profile.Repository, cr configtemplate.Repository) UiProfileHandlerGroup {
+	return UiProfileHandlerGroup{pr, cr}
 }
 
 // Overview will list all profiles.
@@ -57,7 +96,7 @@ func (h UiProfileHandlerGroup) Overview(w http.ResponseWriter, r *http.Request)
 	}
 
 	d := templateData{Title: "Profiles", Data: profiles}
-	renderTemplate(w, "profiles/overview", d)
+	renderTemplate(w, r, "profiles/overview", d)
 }
 
 // Show will show information about a single profile.
@@ -74,14 +113,66 @@ func (h UiProfileHandlerGroup) Show(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	d := templateData{Title: "Profile Information", Data: p}
-	renderTemplate(w, "profiles/show", d)
+	// A broken inheritance chain is shown on the page, instead of hiding the whole profile behind a generic error
+	var resolveError string
+	effective, err := profile.Resolve(h.profileRepo, p)
+	if err != nil {
+		resolveError = err.Error()
+	}
+
+	var parent profile.Profile
+	if p.Parent != uuid.Nil {
+		parent, err = h.profileRepo.GetProfileById(p.Parent)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			renderError(w)
+			return
+		}
+	}
+
+	configTemplates, err := h.configTemplateRepo.GetConfigTemplates()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Profile Information", Data: struct {
+		Profile         profile.Profile
+		Parent          profile.Profile
+		Effective       profile.Profile
+		ResolveError    string
+		ConfigTemplates []configtemplate.ConfigTemplate
+	}{
+		Profile:         p,
+		Parent:          parent,
+		Effective:       effective,
+		ResolveError:    resolveError,
+		ConfigTemplates: configTemplates,
+	}}
+	renderTemplate(w, r, "profiles/show", d)
 }
 
 // Create shows the page for creating a new profile.
 func (h UiProfileHandlerGroup) Create(w http.ResponseWriter, r *http.Request) {
-	d := templateData{Title: "Create profile"}
-	renderTemplate(w, "profiles/create", d)
+	profiles, err := h.profileRepo.GetProfiles()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	configTemplates, err := h.configTemplateRepo.GetConfigTemplates()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Create profile", Data: struct {
+		Profiles        []profile.Profile
+		ConfigTemplates []configtemplate.ConfigTemplate
+	}{
+		Profiles:        profiles,
+		ConfigTemplates: configTemplates,
+	}}
+	renderTemplate(w, r, "profiles/create", d)
 }
 
 // Store will store a newly created profile.
@@ -94,6 +185,12 @@ func (h UiProfileHandlerGroup) Store(w http.ResponseWriter, r *http.Request) {
 
 	p.Id = uuid.New()
 
+	err = profile.ValidateParent(h.profileRepo, p)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
 	err = h.profileRepo.SetProfile(p)
 	if err != nil {
 		renderError(w)
@@ -117,8 +214,28 @@ func (h UiProfileHandlerGroup) Edit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	d := templateData{Title: "Edit Profile", Data: p}
-	renderTemplate(w, "profiles/edit", d)
+	profiles, err := h.profileRepo.GetProfiles()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	configTemplates, err := h.configTemplateRepo.GetConfigTemplates()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Edit Profile", Data: struct {
+		Profile         profile.Profile
+		Profiles        []profile.Profile
+		ConfigTemplates []configtemplate.ConfigTemplate
+	}{
+		Profile:         p,
+		Profiles:        profiles,
+		ConfigTemplates: configTemplates,
+	}}
+	renderTemplate(w, r, "profiles/edit", d)
 }
 
 // Update will update the specified profile.
@@ -137,6 +254,12 @@ func (h UiProfileHandlerGroup) Update(w http.ResponseWriter, r *http.Request) {
 
 	p.Id = profileId
 
+	err = profile.ValidateParent(h.profileRepo, p)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
 	err = h.profileRepo.SetProfile(p)
 	if err != nil {
 		renderError(w)
@@ -154,6 +277,12 @@ func (h UiProfileHandlerGroup) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hasChildren, err := profile.HasChildren(h.profileRepo, profileId)
+	if err != nil || hasChildren {
+		renderError(w)
+		return
+	}
+
 	err = h.profileRepo.DeleteProfileById(profileId)
 	if err != nil {
 		renderError(w)
@@ -0,0 +1,93 @@
+package ui_handlers
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/server/handlers"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// loginData is the data that is shown on the login page.
+type loginData struct {
+	Name  string
+	Next  string
+	Error string
+	// OidcEnabled is whether users can also log in with single sign-on.
+	OidcEnabled bool
+}
+
+type UiSessionHandlerGroup struct {
+	authenticator *auth.BasicAuthenticator
+	sessionRepo   auth.SessionRepository
+	secureCookies bool
+	oidcEnabled   bool
+}
+
+func NewUiSessionHandlerGroup(b *auth.BasicAuthenticator, sr auth.SessionRepository, secureCookies bool, oidcEnabled bool) UiSessionHandlerGroup {
+	return UiSessionHandlerGroup{b, sr, secureCookies, oidcEnabled}
+}
+
+// Login shows the login page.
+func (h UiSessionHandlerGroup) Login(w http.ResponseWriter, r *http.Request) {
+	d := templateData{Title: "Log in", DisableNavbar: true, Data: loginData{Next: safeNext(r.URL.Query().Get("next")), OidcEnabled: h.oidcEnabled}}
+	renderTemplate(w, r, "login", d)
+}
+
+// StoreLogin will check the submitted credentials, and start a new session if they are correct.
+func (h UiSessionHandlerGroup) StoreLogin(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	name := r.PostFormValue("name")
+	next := safeNext(r.PostFormValue("next"))
+
+	u, err := h.authenticator.Authenticate(handlers.GetClientIPFromRequest(r).String(), name, r.PostFormValue("password"))
+	if err != nil {
+		message := "Invalid name or password."
+		if errors.Is(err, auth.ErrLockedOut) {
+			message = "Too many failed attempts, try again later."
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+		d := templateData{Title: "Log in", DisableNavbar: true, Data: loginData{Name: name, Next: next, Error: message, OidcEnabled: h.oidcEnabled}}
+		renderTemplate(w, r, "login", d)
+		return
+	}
+
+	s, token, err := auth.StartSession(h.sessionRepo, u, time.Now())
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	auth.SetSessionCookie(w, token, s.ExpiresAt, h.secureCookies)
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// Logout will end the current session.
+func (h UiSessionHandlerGroup) Logout(w http.ResponseWriter, r *http.Request) {
+	if s, ok := auth.SessionFromContext(r.Context()); ok {
+		err := h.sessionRepo.DeleteSessionById(s.Id)
+		if err != nil {
+			renderError(w)
+			return
+		}
+	}
+
+	auth.ClearSessionCookie(w, h.secureCookies)
+	http.Redirect(w, r, "/ui/login", http.StatusSeeOther)
+}
+
+// safeNext returns the page to go to after logging in, which has to be a UI page so the login cannot redirect to other sites.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/ui/") {
+		return "/ui/"
+	}
+
+	return next
+}
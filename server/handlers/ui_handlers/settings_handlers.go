@@ -0,0 +1,103 @@
+package ui_handlers
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/settings"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+)
+
+func parseSettingsFromPostForm(r *http.Request) (settings.Settings, error) {
+	var s settings.Settings
+
+	err := r.ParseForm()
+	if err != nil {
+		return s, err
+	}
+
+	requiredKeys := []string{"defaultProfile", "bootEventRetentionDays"}
+	for _, v := range requiredKeys {
+		if !r.PostForm.Has(v) {
+			return s, errors.New("missing value " + v + " in POST form")
+		}
+	}
+
+	// An empty value means that no default profile should be used
+	defaultProfile := uuid.Nil
+	if v := r.PostFormValue("defaultProfile"); v != "" {
+		defaultProfile, err = uuid.Parse(v)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	// Unchecked checkboxes are not sent at all, so this one cannot be required
+	discoveryEnabled := r.PostFormValue("discoveryEnabled") == "on"
+
+	bootEventRetentionDays, err := strconv.ParseUint(r.PostFormValue("bootEventRetentionDays"), 10, 32)
+	if err != nil {
+		return s, err
+	}
+
+	return settings.New(defaultProfile, discoveryEnabled, uint(bootEventRetentionDays)), nil
+}
+
+type UiSettingsHandlerGroup struct {
+	settingsRepo settings.Repository
+	profileRepo  profile.Repository
+}
+
+func NewUiSettingsHandlerGroup(sr settings.Repository, pr profile.Repository) UiSettingsHandlerGroup {
+	return UiSettingsHandlerGroup{sr, pr}
+}
+
+// Edit shows the page for editing the settings.
+func (h UiSettingsHandlerGroup) Edit(w http.ResponseWriter, r *http.Request) {
+	s, err := h.settingsRepo.GetSettings()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	profiles, err := h.profileRepo.GetProfiles()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	d := templateData{Title: "Settings", Data: struct {
+		Settings settings.Settings
+		Profiles []profile.Profile
+	}{
+		Settings: s,
+		Profiles: profiles,
+	}}
+	renderTemplate(w, r, "settings/edit", d)
+}
+
+// Update will update the settings.
+func (h UiSettingsHandlerGroup) Update(w http.ResponseWriter, r *http.Request) {
+	s, err := parseSettingsFromPostForm(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	if s.DefaultProfile != uuid.Nil {
+		_, err = h.profileRepo.GetProfileById(s.DefaultProfile)
+		if err != nil {
+			renderError(w)
+			return
+		}
+	}
+
+	err = h.settingsRepo.SetSettings(s)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/settings", http.StatusSeeOther)
+}
@@ -2,13 +2,16 @@ package ui_handlers
 
 import (
 	"errors"
+	"github.com/evanebb/gobble/bootevent"
 	"github.com/evanebb/gobble/kernelparameters"
 	"github.com/evanebb/gobble/profile"
 	"github.com/evanebb/gobble/server/handlers"
 	"github.com/evanebb/gobble/system"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
-	"net"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 func parseSystemFromPostForm(r *http.Request) (system.System, error) {
@@ -19,7 +22,7 @@ func parseSystemFromPostForm(r *http.Request) (system.System, error) {
 		return s, err
 	}
 
-	requiredKeys := []string{"name", "description", "profile", "mac", "kernelParameters"}
+	requiredKeys := []string{"name", "description", "profile", "interfaces", "smbiosUuid", "serial", "assetTag", "hostname", "kernelParameters", "menuTimeout", "bootMode", "metadata"}
 	for _, v := range requiredKeys {
 		if !r.PostForm.Has(v) {
 			return s, errors.New("missing value " + v + " in POST form")
@@ -36,7 +39,46 @@ func parseSystemFromPostForm(r *http.Request) (system.System, error) {
 		return s, err
 	}
 
-	macAddress, err := net.ParseMAC(r.PostFormValue("mac"))
+	identifiers := system.Identifiers{
+		Serial:   strings.TrimSpace(r.PostFormValue("serial")),
+		AssetTag: strings.TrimSpace(r.PostFormValue("assetTag")),
+		Hostname: strings.TrimSpace(r.PostFormValue("hostname")),
+	}
+
+	identifiers.Interfaces, err = system.ParseInterfaces(r.PostFormValue("interfaces"))
+	if err != nil {
+		return s, err
+	}
+
+	if v := strings.TrimSpace(r.PostFormValue("smbiosUuid")); v != "" {
+		identifiers.SmbiosUuid, err = uuid.Parse(v)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	// A multiple select does not send anything if no options are selected, so the menu profiles are optional
+	var menuProfiles []uuid.UUID
+	for _, v := range r.PostForm["menuProfiles"] {
+		menuProfileId, err := uuid.Parse(v)
+		if err != nil {
+			return s, err
+		}
+
+		menuProfiles = append(menuProfiles, menuProfileId)
+	}
+
+	menuTimeout, err := strconv.ParseUint(r.PostFormValue("menuTimeout"), 10, 32)
+	if err != nil {
+		return s, err
+	}
+
+	bootMode, err := system.ParseBootMode(r.PostFormValue("bootMode"))
+	if err != nil {
+		return s, err
+	}
+
+	metadata, err := variables.ParseMetadata(r.PostFormValue("metadata"))
 	if err != nil {
 		return s, err
 	}
@@ -47,20 +89,33 @@ func parseSystemFromPostForm(r *http.Request) (system.System, error) {
 		r.PostFormValue("name"),
 		r.PostFormValue("description"),
 		profileId,
-		macAddress,
+		identifiers,
 		kp,
+		menuProfiles,
+		uint(menuTimeout),
+		bootMode,
+		metadata,
 	)
 }
 
 type UiSystemHandlerGroup struct {
-	systemRepo  system.Repository
-	profileRepo profile.Repository
+	systemRepo    system.Repository
+	profileRepo   profile.Repository
+	bootEventRepo bootevent.Repository
+	builder       system.PxeConfigBuilder
+	renderer      system.Renderer
+	externalUrl   string
 }
 
-func NewUiSystemHandlerGroup(sr system.Repository, pr profile.Repository) UiSystemHandlerGroup {
-	return UiSystemHandlerGroup{sr, pr}
+// NewUiSystemHandlerGroup creates a new UiSystemHandlerGroup. The external URL is used in the iPXE script preview, just like
+// in the script that is served to the system; if it is empty, the URL of the incoming request is used instead.
+func NewUiSystemHandlerGroup(sr system.Repository, pr profile.Repository, br bootevent.Repository, renderer system.Renderer, externalUrl string) UiSystemHandlerGroup {
+	return UiSystemHandlerGroup{sr, pr, br, system.NewPxeConfigBuilder(pr), renderer, externalUrl}
 }
 
+// recentBootEventLimit is the number of boot events that are shown on the page of a system.
+const recentBootEventLimit = 20
+
 // Overview will list all systems.
 func (h UiSystemHandlerGroup) Overview(w http.ResponseWriter, r *http.Request) {
 	systems, err := h.systemRepo.GetSystems()
@@ -70,7 +125,7 @@ func (h UiSystemHandlerGroup) Overview(w http.ResponseWriter, r *http.Request) {
 	}
 
 	d := templateData{Title: "Systems", Data: systems}
-	renderTemplate(w, "systems/overview", d)
+	renderTemplate(w, r, "systems/overview", d)
 }
 
 // Show will show information about a single system.
@@ -87,20 +142,62 @@ func (h UiSystemHandlerGroup) Show(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The preview is not requested by the system itself, so its IP address is unknown
+	pxeConfig, err := h.builder.Build(s, system.BootRequest{ServerUrl: handlers.GetExternalUrl(r, h.externalUrl)})
+	var unknown variables.UnknownVariableError
+	if err != nil && !errors.As(err, &unknown) {
+		renderError(w)
+		return
+	}
+
+	// Show a broken profile template or an unknown variable on the page itself, so it can be fixed
+	var renderErr, script string
+	if err != nil {
+		renderErr = err.Error()
+	} else {
+		script, err = h.renderer.Render(pxeConfig)
+		if err != nil {
+			renderErr = err.Error()
+		}
+	}
+
+	// The PxeConfig does not contain a profile if the system does not boot one, e.g. when it boots from its local disk
 	p, err := h.profileRepo.GetProfileById(s.Profile)
 	if err != nil {
 		renderError(w)
 		return
 	}
 
+	profiles, err := h.profileRepo.GetProfiles()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	bootEvents, err := h.bootEventRepo.GetBootEventsBySystemId(s.Id, recentBootEventLimit)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
 	d := templateData{Title: "System information", Data: struct {
-		System  system.System
-		Profile profile.Profile
+		System      system.System
+		Profile     profile.Profile
+		Profiles    []profile.Profile
+		PxeConfig   system.PxeConfig
+		Script      string
+		RenderError string
+		BootEvents  []bootevent.BootEvent
 	}{
-		System:  s,
-		Profile: p,
+		System:      s,
+		Profile:     p,
+		Profiles:    profiles,
+		PxeConfig:   pxeConfig,
+		Script:      script,
+		RenderError: renderErr,
+		BootEvents:  bootEvents,
 	}}
-	renderTemplate(w, "systems/show", d)
+	renderTemplate(w, r, "systems/show", d)
 }
 
 // Create shows the page for creating a new system.
@@ -112,11 +209,13 @@ func (h UiSystemHandlerGroup) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	d := templateData{Title: "Create system", Data: struct {
-		Profiles []profile.Profile
+		Profiles  []profile.Profile
+		BootModes []system.BootMode
 	}{
-		Profiles: profiles,
+		Profiles:  profiles,
+		BootModes: system.BootModes,
 	}}
-	renderTemplate(w, "systems/create", d)
+	renderTemplate(w, r, "systems/create", d)
 }
 
 // Store will store a newly created system.
@@ -159,13 +258,15 @@ func (h UiSystemHandlerGroup) Edit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	d := templateData{Title: "Edit system", Data: struct {
-		System   system.System
-		Profiles []profile.Profile
+		System    system.System
+		Profiles  []profile.Profile
+		BootModes []system.BootMode
 	}{
-		System:   s,
-		Profiles: profiles,
+		System:    s,
+		Profiles:  profiles,
+		BootModes: system.BootModes,
 	}}
-	renderTemplate(w, "systems/edit", d)
+	renderTemplate(w, r, "systems/edit", d)
 }
 
 // Update will update the specified system.
@@ -209,3 +310,73 @@ func (h UiSystemHandlerGroup) Delete(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/ui/systems", http.StatusSeeOther)
 }
+
+// UpdateNextBoot will set the one-shot boot action of the specified system.
+func (h UiSystemHandlerGroup) UpdateNextBoot(w http.ResponseWriter, r *http.Request) {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	requiredKeys := []string{"profile", "kernelParameters"}
+	for _, v := range requiredKeys {
+		if !r.PostForm.Has(v) {
+			renderError(w)
+			return
+		}
+	}
+
+	kp, err := kernelparameters.ParseString(r.PostFormValue("kernelParameters"))
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	// An empty profile means that the regular profile of the system is booted
+	profileId := uuid.Nil
+	if r.PostFormValue("profile") != "" {
+		profileId, err = uuid.Parse(r.PostFormValue("profile"))
+		if err != nil {
+			renderError(w)
+			return
+		}
+	}
+
+	n, err := system.NewNextBoot(profileId, kp)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = h.systemRepo.SetNextBoot(systemId, n)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/systems/"+systemId.String(), http.StatusSeeOther)
+}
+
+// DeleteNextBoot will cancel the one-shot boot action of the specified system.
+func (h UiSystemHandlerGroup) DeleteNextBoot(w http.ResponseWriter, r *http.Request) {
+	systemId, err := handlers.GetUUIDFromRequest(r)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	err = h.systemRepo.ClearNextBoot(systemId)
+	if err != nil {
+		renderError(w)
+		return
+	}
+
+	http.Redirect(w, r, "/ui/systems/"+systemId.String(), http.StatusSeeOther)
+}
@@ -1,23 +1,36 @@
 package ui_handlers
 
 import (
+	"github.com/evanebb/gobble/api/auth"
 	"github.com/evanebb/gobble/resources"
 	"html/template"
 	"io"
+	"net/http"
 )
 
 type templateData struct {
 	Title         string
 	Data          any
 	DisableNavbar bool
+	// User is the logged-in user, which is shown in the navigation bar.
+	User auth.ApiUser
+	// CsrfToken is the CSRF token of the session, which every form that changes something has to contain.
+	CsrfToken string
 }
 
 // renderTemplateErr will render the template referenced by the passed name and return an error if it encounters one
 func renderTemplateErr(w io.Writer, templateName string, d templateData) error {
 	var err error
 
+	funcs := template.FuncMap{
+		// csrfField returns the hidden input containing the CSRF token, which has to be added to every form
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + auth.CsrfFieldName + `" value="` + template.HTMLEscapeString(d.CsrfToken) + `">`)
+		},
+	}
+
 	templateFile := "templates/" + templateName + ".gohtml"
-	tmpl, err := template.New("base").ParseFS(resources.Templates, "templates/base.gohtml", templateFile)
+	tmpl, err := template.New("base").Funcs(funcs).ParseFS(resources.Templates, "templates/base.gohtml", templateFile)
 	if err != nil {
 		return err
 	}
@@ -30,8 +43,14 @@ func renderTemplateErr(w io.Writer, templateName string, d templateData) error {
 	return nil
 }
 
-// renderTemplate will render the template referenced by the passed name and render an error page if an error occurs
-func renderTemplate(w io.Writer, templateName string, d templateData) {
+// renderTemplate will render the template referenced by the passed name for the logged-in user of the request,
+// and render an error page if an error occurs
+func renderTemplate(w io.Writer, r *http.Request, templateName string, d templateData) {
+	d.User, _ = auth.UserFromContext(r.Context())
+	if s, ok := auth.SessionFromContext(r.Context()); ok {
+		d.CsrfToken = s.CsrfToken
+	}
+
 	err := renderTemplateErr(w, templateName, d)
 	if err != nil {
 		renderError(w)
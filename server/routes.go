@@ -1,26 +1,36 @@
 package server
 
 import (
+	"expvar"
 	"github.com/evanebb/gobble/api/auth"
 	"github.com/evanebb/gobble/resources"
 	"github.com/evanebb/gobble/server/handlers"
 	"github.com/evanebb/gobble/server/handlers/api_handlers"
 	"github.com/evanebb/gobble/server/handlers/ui_handlers"
+	"github.com/evanebb/gobble/system"
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"net/http"
 )
 
 func (s *Server) routes() {
-	s.router.Use(handlers.MethodOverride, middleware.Logger)
+	s.router.Use(handlers.MethodOverride, handlers.Logger)
+
+	renderer := system.NewTemplateRenderer()
 
 	// API route group
 	s.router.Route("/api", func(r chi.Router) {
-		r.Use(auth.ApiBasicAuth(s.apiUserRepo))
+		// JWTs issued by the OpenID Connect provider are accepted as bearer tokens, if it has been configured
+		var external auth.ExternalAuthenticator
+		if s.oidcProvider != nil {
+			external = s.oidcProvider
+		}
+
+		r.Use(auth.ApiAuth(s.basicAuthenticator, s.apiTokenRepo, external))
 		r.NotFound(api_handlers.ErrorHandler(api_handlers.UnknownEndpointHandler))
 
 		r.Route("/profiles", func(r chi.Router) {
-			h := api_handlers.NewProfileHandlerGroup(s.profileRepo)
+			r.Use(auth.ApiAuthorize(auth.RoleViewer, auth.RoleAdmin))
+			h := api_handlers.NewProfileHandlerGroup(s.profileRepo, s.configTemplateRepo)
 
 			r.Get("/", api_handlers.ErrorHandler(h.GetProfiles))
 			r.Post("/", api_handlers.ErrorHandler(h.CreateProfile))
@@ -29,11 +39,29 @@ func (s *Server) routes() {
 				r.Put("/", api_handlers.ErrorHandler(h.PutProfile))
 				r.Patch("/", api_handlers.ErrorHandler(h.PatchProfile))
 				r.Delete("/", api_handlers.ErrorHandler(h.DeleteProfile))
+				r.Get("/effective", api_handlers.ErrorHandler(h.GetEffectiveProfile))
+			})
+		})
+
+		r.Route("/config-templates", func(r chi.Router) {
+			r.Use(auth.ApiAuthorize(auth.RoleViewer, auth.RoleAdmin))
+			h := api_handlers.NewConfigTemplateHandlerGroup(s.configTemplateRepo)
+
+			r.Get("/", api_handlers.ErrorHandler(h.GetConfigTemplates))
+			r.Post("/", api_handlers.ErrorHandler(h.CreateConfigTemplate))
+			r.Route("/{uuid}", func(r chi.Router) {
+				r.Get("/", api_handlers.ErrorHandler(h.GetConfigTemplate))
+				r.Put("/", api_handlers.ErrorHandler(h.PutConfigTemplate))
+				r.Patch("/", api_handlers.ErrorHandler(h.PatchConfigTemplate))
+				r.Delete("/", api_handlers.ErrorHandler(h.DeleteConfigTemplate))
 			})
 		})
 
 		r.Route("/systems", func(r chi.Router) {
-			h := api_handlers.NewSystemHandlerGroup(s.systemRepo)
+			r.Use(auth.ApiAuthorize(auth.RoleViewer, auth.RoleOperator))
+			h := api_handlers.NewSystemHandlerGroup(s.systemRepo, s.profileRepo, s.config.externalUrl)
+			bh := api_handlers.NewBootEventHandlerGroup(s.bootEventRepo, s.systemRepo)
+			ph := api_handlers.NewPxeConfigHandlerGroup(s.systemRepo, s.profileRepo, s.settingsRepo, s.discoveryRepo, s.bootEventRepo, s.configTemplateRepo, renderer, s.config.externalUrl)
 
 			r.Get("/", api_handlers.ErrorHandler(h.GetSystems))
 			r.Post("/", api_handlers.ErrorHandler(h.CreateSystem))
@@ -42,11 +70,31 @@ func (s *Server) routes() {
 				r.Put("/", api_handlers.ErrorHandler(h.PutSystem))
 				r.Patch("/", api_handlers.ErrorHandler(h.PatchSystem))
 				r.Delete("/", api_handlers.ErrorHandler(h.DeleteSystem))
+				r.Get("/kernel-parameters", api_handlers.ErrorHandler(h.GetEffectiveKernelParameters))
+				r.Get("/pxe-config", api_handlers.ErrorHandler(ph.GetSystemPxeConfig))
+				r.Get("/next-boot", api_handlers.ErrorHandler(h.GetNextBoot))
+				r.Put("/next-boot", api_handlers.ErrorHandler(h.PutNextBoot))
+				r.Delete("/next-boot", api_handlers.ErrorHandler(h.DeleteNextBoot))
+				r.Get("/boots", api_handlers.ErrorHandler(bh.GetSystemBootEvents))
+			})
+		})
+
+		r.Route("/discovered-systems", func(r chi.Router) {
+			r.Use(auth.ApiAuthorize(auth.RoleViewer, auth.RoleOperator))
+			h := api_handlers.NewDiscoveryHandlerGroup(s.discoveryRepo, s.systemRepo)
+
+			r.Get("/", api_handlers.ErrorHandler(h.GetDiscoveredSystems))
+			r.Route("/{uuid}", func(r chi.Router) {
+				r.Get("/", api_handlers.ErrorHandler(h.GetDiscoveredSystem))
+				r.Delete("/", api_handlers.ErrorHandler(h.DeleteDiscoveredSystem))
+				r.Post("/promote", api_handlers.ErrorHandler(h.PromoteDiscoveredSystem))
 			})
 		})
 
 		r.Route("/users", func(r chi.Router) {
+			r.Use(auth.ApiAuthorize(auth.RoleAdmin, auth.RoleAdmin))
 			h := api_handlers.NewApiUserHandlerGroup(s.apiUserRepo)
+			th := api_handlers.NewApiTokenHandlerGroup(s.apiTokenRepo, s.apiUserRepo)
 
 			r.Get("/", api_handlers.ErrorHandler(h.GetUsers))
 			r.Post("/", api_handlers.ErrorHandler(h.CreateUser))
@@ -54,52 +102,153 @@ func (s *Server) routes() {
 				r.Get("/", api_handlers.ErrorHandler(h.GetUser))
 				r.Put("/", api_handlers.ErrorHandler(h.PutUser))
 				r.Delete("/", api_handlers.ErrorHandler(h.DeleteUser))
+				r.Get("/tokens", api_handlers.ErrorHandler(th.GetUserTokens))
+				r.Delete("/tokens/{tokenUuid}", api_handlers.ErrorHandler(th.DeleteUserToken))
 			})
 		})
+
+		// Every user can manage their own API tokens, regardless of their role
+		r.Route("/tokens", func(r chi.Router) {
+			r.Use(auth.ApiAuthorize(auth.RoleViewer, auth.RoleViewer))
+			h := api_handlers.NewApiTokenHandlerGroup(s.apiTokenRepo, s.apiUserRepo)
+
+			r.Get("/", api_handlers.ErrorHandler(h.GetOwnTokens))
+			r.Post("/", api_handlers.ErrorHandler(h.CreateOwnToken))
+			r.Delete("/{uuid}", api_handlers.ErrorHandler(h.DeleteOwnToken))
+		})
+
+		r.Route("/settings", func(r chi.Router) {
+			r.Use(auth.ApiAuthorize(auth.RoleViewer, auth.RoleAdmin))
+			h := api_handlers.NewSettingsHandlerGroup(s.settingsRepo, s.profileRepo)
+
+			r.Get("/", api_handlers.ErrorHandler(h.GetSettings))
+			r.Put("/", api_handlers.ErrorHandler(h.PutSettings))
+		})
+
+		// The expvar metrics, such as the authentication failure counters
+		r.With(auth.ApiAuthorize(auth.RoleAdmin, auth.RoleAdmin)).Get("/metrics", expvar.Handler().ServeHTTP)
 	})
 
-	// This endpoint should not have authentication, so it lives outside the /api group above
-	h := api_handlers.NewPxeConfigHandlerGroup(s.systemRepo, s.profileRepo)
+	// These endpoints should not have authentication, so they live outside the /api group above
+	h := api_handlers.NewPxeConfigHandlerGroup(s.systemRepo, s.profileRepo, s.settingsRepo, s.discoveryRepo, s.bootEventRepo, s.configTemplateRepo, renderer, s.config.externalUrl)
 	s.router.Get("/api/pxe-config", api_handlers.ErrorHandler(h.GetPxeConfig))
+	s.router.Post("/api/install-complete", api_handlers.ErrorHandler(h.CompleteInstall))
+	s.router.Get("/api/config/{name}", api_handlers.ErrorHandler(h.GetConfig))
+	s.router.Get("/api/systems/{uuid}/config/{name}", api_handlers.ErrorHandler(h.GetSystemConfig))
 
 	// Redirect the index to the UI by default
 	s.router.Handle("/", http.RedirectHandler("ui/", http.StatusMovedPermanently))
 
 	// Front-end (UI) routes
 	s.router.Route("/ui/", func(r chi.Router) {
-		r.Use(auth.BrowserBasicAuth(s.apiUserRepo))
-
 		r.NotFound(ui_handlers.PageNotFound)
-		r.Get("/", ui_handlers.HomePage)
 		r.Handle("/static/*", http.StripPrefix("/ui/", http.FileServer(http.FS(resources.Static))))
 
-		r.Route("/profiles", func(r chi.Router) {
-			h := ui_handlers.NewUiProfileHandlerGroup(s.profileRepo)
+		sh := ui_handlers.NewUiSessionHandlerGroup(s.basicAuthenticator, s.sessionRepo, s.config.secureCookies, s.oidcProvider != nil)
+		r.Get("/login", sh.Login)
+		r.Post("/login", sh.StoreLogin)
+
+		if s.oidcProvider != nil {
+			oh := ui_handlers.NewUiOidcHandlerGroup(s.oidcProvider, s.sessionRepo, s.config.secureCookies, s.config.externalUrl)
+			r.Get("/login/oidc", oh.Login)
+			r.Get("/login/oidc/callback", oh.Callback)
+		}
+
+		// Everything else requires a session, and forms that change something require its CSRF token
+		r.Group(func(r chi.Router) {
+			r.Use(auth.BrowserSessionAuth(s.sessionRepo, s.apiUserRepo, "/ui/login"), auth.VerifyCsrfToken)
+
+			// Pages that only show a form require the role that is needed to submit it
+			adminForm := auth.BrowserAuthorize(auth.RoleAdmin, auth.RoleAdmin)
+			operatorForm := auth.BrowserAuthorize(auth.RoleOperator, auth.RoleOperator)
+
+			r.Get("/", ui_handlers.HomePage)
+			r.Post("/logout", sh.Logout)
+
+			r.Route("/profiles", func(r chi.Router) {
+				r.Use(auth.BrowserAuthorize(auth.RoleViewer, auth.RoleAdmin))
+				h := ui_handlers.NewUiProfileHandlerGroup(s.profileRepo, s.configTemplateRepo)
+
+				r.Get("/", h.Overview)
+				r.With(adminForm).Get("/create", h.Create)
+				r.Post("/", h.Store)
+
+				r.Route("/{uuid}", func(r chi.Router) {
+					r.Get("/", h.Show)
+					r.With(adminForm).Get("/edit", h.Edit)
+					r.Put("/", h.Update)
+					r.Delete("/", h.Delete)
+				})
+			})
 
-			r.Get("/", h.Overview)
-			r.Get("/create", h.Create)
-			r.Post("/", h.Store)
+			r.Route("/config-templates", func(r chi.Router) {
+				r.Use(auth.BrowserAuthorize(auth.RoleViewer, auth.RoleAdmin))
+				h := ui_handlers.NewUiConfigTemplateHandlerGroup(s.configTemplateRepo)
 
-			r.Route("/{uuid}", func(r chi.Router) {
-				r.Get("/", h.Show)
-				r.Get("/edit", h.Edit)
-				r.Put("/", h.Update)
-				r.Delete("/", h.Delete)
+				r.Get("/", h.Overview)
+				r.With(adminForm).Get("/create", h.Create)
+				r.Post("/", h.Store)
+
+				r.Route("/{uuid}", func(r chi.Router) {
+					r.Get("/", h.Show)
+					r.With(adminForm).Get("/edit", h.Edit)
+					r.Put("/", h.Update)
+					r.Delete("/", h.Delete)
+				})
 			})
-		})
 
-		r.Route("/systems", func(r chi.Router) {
-			h := ui_handlers.NewUiSystemHandlerGroup(s.systemRepo, s.profileRepo)
+			r.Route("/systems", func(r chi.Router) {
+				r.Use(auth.BrowserAuthorize(auth.RoleViewer, auth.RoleOperator))
+				h := ui_handlers.NewUiSystemHandlerGroup(s.systemRepo, s.profileRepo, s.bootEventRepo, renderer, s.config.externalUrl)
+
+				r.Get("/", h.Overview)
+				r.With(operatorForm).Get("/create", h.Create)
+				r.Post("/", h.Store)
+
+				r.Route("/{uuid}", func(r chi.Router) {
+					r.Get("/", h.Show)
+					r.With(operatorForm).Get("/edit", h.Edit)
+					r.Put("/", h.Update)
+					r.Delete("/", h.Delete)
+					r.Put("/next-boot", h.UpdateNextBoot)
+					r.Delete("/next-boot", h.DeleteNextBoot)
+				})
+			})
 
-			r.Get("/", h.Overview)
-			r.Get("/create", h.Create)
-			r.Post("/", h.Store)
+			r.Route("/discovered-systems", func(r chi.Router) {
+				r.Use(auth.BrowserAuthorize(auth.RoleViewer, auth.RoleOperator))
+				h := ui_handlers.NewUiDiscoveryHandlerGroup(s.discoveryRepo, s.systemRepo, s.profileRepo)
 
-			r.Route("/{uuid}", func(r chi.Router) {
-				r.Get("/", h.Show)
-				r.Get("/edit", h.Edit)
+				r.Get("/", h.Overview)
+
+				r.Route("/{uuid}", func(r chi.Router) {
+					r.With(operatorForm).Get("/promote", h.Promote)
+					r.Post("/promote", h.StorePromoted)
+					r.Delete("/", h.Delete)
+				})
+			})
+
+			r.Route("/users", func(r chi.Router) {
+				r.Use(auth.BrowserAuthorize(auth.RoleAdmin, auth.RoleAdmin))
+				h := ui_handlers.NewUiApiUserHandlerGroup(s.apiUserRepo)
+
+				r.Get("/", h.Overview)
+				r.Get("/create", h.Create)
+				r.Post("/", h.Store)
+
+				r.Route("/{uuid}", func(r chi.Router) {
+					r.Get("/edit", h.Edit)
+					r.Put("/", h.Update)
+					r.Delete("/", h.Delete)
+				})
+			})
+
+			r.Route("/settings", func(r chi.Router) {
+				r.Use(auth.BrowserAuthorize(auth.RoleViewer, auth.RoleAdmin))
+				h := ui_handlers.NewUiSettingsHandlerGroup(s.settingsRepo, s.profileRepo)
+
+				r.Get("/", h.Edit)
 				r.Put("/", h.Update)
-				r.Delete("/", h.Delete)
 			})
 		})
 	})
@@ -4,8 +4,13 @@ import (
 	"context"
 	"fmt"
 	"github.com/evanebb/gobble/api/auth"
+	"github.com/evanebb/gobble/api/auth/oidc"
+	"github.com/evanebb/gobble/bootevent"
+	"github.com/evanebb/gobble/configtemplate"
+	"github.com/evanebb/gobble/discovery"
 	"github.com/evanebb/gobble/profile"
 	"github.com/evanebb/gobble/repository/postgres"
+	"github.com/evanebb/gobble/settings"
 	"github.com/evanebb/gobble/system"
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -15,11 +20,19 @@ import (
 )
 
 type Server struct {
-	apiUserRepo auth.ApiUserRepository
-	profileRepo profile.Repository
-	systemRepo  system.Repository
-	router      chi.Router
-	config      AppConfig
+	apiUserRepo        auth.ApiUserRepository
+	apiTokenRepo       auth.ApiTokenRepository
+	basicAuthenticator *auth.BasicAuthenticator
+	sessionRepo        auth.SessionRepository
+	oidcProvider       *oidc.Provider
+	profileRepo        profile.Repository
+	systemRepo         system.Repository
+	settingsRepo       settings.Repository
+	discoveryRepo      discovery.Repository
+	bootEventRepo      bootevent.Repository
+	configTemplateRepo configtemplate.Repository
+	router             chi.Router
+	config             AppConfig
 }
 
 func NewServer() (Server, error) {
@@ -58,6 +71,21 @@ func NewServer() (Server, error) {
 		return s, err
 	}
 
+	tr, err := postgres.NewApiTokenRepository(db)
+	if err != nil {
+		return s, err
+	}
+
+	ba, err := auth.NewBasicAuthenticator(ar)
+	if err != nil {
+		return s, err
+	}
+
+	ser, err := postgres.NewSessionRepository(db)
+	if err != nil {
+		return s, err
+	}
+
 	pr, err := postgres.NewProfileRepository(db)
 	if err != nil {
 		return s, err
@@ -68,11 +96,45 @@ func NewServer() (Server, error) {
 		return s, err
 	}
 
+	setr, err := postgres.NewSettingsRepository(db)
+	if err != nil {
+		return s, err
+	}
+
+	dr, err := postgres.NewDiscoveryRepository(db)
+	if err != nil {
+		return s, err
+	}
+
+	br, err := postgres.NewBootEventRepository(db)
+	if err != nil {
+		return s, err
+	}
+
+	cr, err := postgres.NewConfigTemplateRepository(db)
+	if err != nil {
+		return s, err
+	}
+
+	if s.config.oidcEnabled {
+		s.oidcProvider, err = oidc.NewProvider(context.Background(), s.config.oidc)
+		if err != nil {
+			return s, err
+		}
+	}
+
 	router := chi.NewRouter()
 
 	s.apiUserRepo = ar
+	s.apiTokenRepo = tr
+	s.basicAuthenticator = ba
+	s.sessionRepo = ser
 	s.profileRepo = pr
 	s.systemRepo = sr
+	s.settingsRepo = setr
+	s.discoveryRepo = dr
+	s.bootEventRepo = br
+	s.configTemplateRepo = cr
 	s.router = router
 	return s, nil
 }
@@ -80,6 +142,9 @@ func NewServer() (Server, error) {
 func (s *Server) Run() {
 	log.Printf("starting API on %s", s.config.listenAddress)
 	s.routes()
+	go s.pruneBootEvents()
+	go s.pruneDiscoveredSystems()
+	go s.pruneSessions()
 	// FIXME: don't use default ListenAndServe functions
 	if s.config.httpsEnabled {
 		log.Fatal(http.ListenAndServeTLS(s.config.listenAddress, s.config.httpsCertFile, s.config.httpsKeyFile, s.router))
@@ -87,3 +152,68 @@ func (s *Server) Run() {
 		log.Fatal(http.ListenAndServe(s.config.listenAddress, s.router))
 	}
 }
+
+// pruneBootEvents periodically deletes the boot events that are older than the configured retention, so the boot history does not grow forever.
+func (s *Server) pruneBootEvents() {
+	for {
+		err := s.pruneBootEventsOnce()
+		if err != nil {
+			log.Printf("failed to prune boot events: %s", err)
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
+
+func (s *Server) pruneBootEventsOnce() error {
+	set, err := s.settingsRepo.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	// A retention of 0 days means that boot events are kept forever
+	if set.BootEventRetentionDays == 0 {
+		return nil
+	}
+
+	before := time.Now().AddDate(0, 0, -int(set.BootEventRetentionDays))
+	deleted, err := s.bootEventRepo.DeleteBootEventsBefore(before)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		log.Printf("pruned %d boot events older than %d days", deleted, set.BootEventRetentionDays)
+	}
+
+	return nil
+}
+
+// pruneDiscoveredSystems periodically deletes the discovered systems that have not been seen for longer than discovery.Retention,
+// so systems that were never promoted do not count towards discovery.MaxDiscoveredSystems forever.
+func (s *Server) pruneDiscoveredSystems() {
+	for {
+		deleted, err := s.discoveryRepo.DeleteDiscoveredSystemsLastSeenBefore(time.Now().Add(-discovery.Retention))
+		if err != nil {
+			log.Printf("failed to prune discovered systems: %s", err)
+		} else if deleted > 0 {
+			log.Printf("pruned %d discovered systems that were not seen for %s", deleted, discovery.Retention)
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
+
+// pruneSessions periodically deletes the expired sessions, since sessions that are never used again are not deleted otherwise.
+func (s *Server) pruneSessions() {
+	for {
+		deleted, err := s.sessionRepo.DeleteSessionsExpiredBefore(time.Now())
+		if err != nil {
+			log.Printf("failed to prune sessions: %s", err)
+		} else if deleted > 0 {
+			log.Printf("pruned %d expired sessions", deleted)
+		}
+
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,6 @@
+package settings
+
+type Repository interface {
+	GetSettings() (Settings, error)
+	SetSettings(s Settings) error
+}
@@ -0,0 +1,24 @@
+package settings
+
+import "github.com/google/uuid"
+
+// Settings are the global settings of the application that can be changed at runtime.
+type Settings struct {
+	// DefaultProfile is the profile that unregistered systems will boot, or uuid.Nil if they should not boot anything.
+	DefaultProfile uuid.UUID
+	// DiscoveryEnabled determines whether systems that are not registered are recorded when they request an iPXE config.
+	DiscoveryEnabled bool
+	// BootEventRetentionDays is the number of days that boot events are kept for, or 0 if they should be kept forever.
+	BootEventRetentionDays uint
+}
+
+// DefaultBootEventRetentionDays is the number of days that boot events are kept for if nothing has been configured yet.
+const DefaultBootEventRetentionDays = 30
+
+func New(defaultProfile uuid.UUID, discoveryEnabled bool, bootEventRetentionDays uint) Settings {
+	return Settings{
+		DefaultProfile:         defaultProfile,
+		DiscoveryEnabled:       discoveryEnabled,
+		BootEventRetentionDays: bootEventRetentionDays,
+	}
+}
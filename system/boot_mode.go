@@ -0,0 +1,47 @@
+package system
+
+import "errors"
+
+// BootMode determines what a system does when it requests its iPXE config.
+type BootMode string
+
+const (
+	// BootModeProfile boots the profile of the system, this is the default.
+	BootModeProfile BootMode = "profile"
+	// BootModeLocalDisk boots the first local disk of the system using 'sanboot'.
+	BootModeLocalDisk BootMode = "localdisk"
+	// BootModeExit exits iPXE, so the firmware continues with the next boot device.
+	BootModeExit BootMode = "exit"
+	// BootModeShell drops into the iPXE shell.
+	BootModeShell BootMode = "shell"
+	// BootModeHalt stops booting, and keeps the system waiting in iPXE.
+	BootModeHalt BootMode = "halt"
+)
+
+// BootModes contains all valid boot modes.
+var BootModes = []BootMode{BootModeProfile, BootModeLocalDisk, BootModeExit, BootModeShell, BootModeHalt}
+
+// ParseBootMode parses s into a BootMode, and returns an error if it is not a valid boot mode.
+// An empty string is parsed as BootModeProfile.
+func ParseBootMode(s string) (BootMode, error) {
+	if s == "" {
+		return BootModeProfile, nil
+	}
+
+	m := BootMode(s)
+	if err := validateBootMode(m); err != nil {
+		return "", err
+	}
+
+	return m, nil
+}
+
+func validateBootMode(m BootMode) error {
+	for _, v := range BootModes {
+		if m == v {
+			return nil
+		}
+	}
+
+	return errors.New("invalid boot mode " + string(m))
+}
@@ -0,0 +1,28 @@
+package system
+
+import "testing"
+
+func TestParseBootMode(t *testing.T) {
+	tests := map[string]BootMode{
+		"":          BootModeProfile,
+		"profile":   BootModeProfile,
+		"localdisk": BootModeLocalDisk,
+		"exit":      BootModeExit,
+		"shell":     BootModeShell,
+		"halt":      BootModeHalt,
+	}
+
+	for s, expected := range tests {
+		actual, err := ParseBootMode(s)
+		if err != nil || actual != expected {
+			t.Fatalf(`ParseBootMode(%s) = %v, %v, expected: %v, nil`, s, actual, err, expected)
+		}
+	}
+}
+
+func TestParseBootModeInvalid(t *testing.T) {
+	actual, err := ParseBootMode("floppy")
+	if err == nil {
+		t.Fatalf(`Expected ParseBootMode() to return invalid boot mode error, got: %v, %v`, actual, err)
+	}
+}
@@ -0,0 +1,190 @@
+package system
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"regexp"
+	"strings"
+)
+
+// placeholderValues are serial numbers and asset tags that vendors ship on many machines instead of a real value,
+// so they cannot be used to tell systems apart. They are compared case-insensitively.
+var placeholderValues = []string{
+	"To be filled by O.E.M.",
+	"Default string",
+	"System Serial Number",
+	"Chassis Serial Number",
+	"Not Specified",
+	"Not Applicable",
+	"No Asset Tag",
+	"None",
+	"N/A",
+	"0",
+	"0123456789",
+	"123456789",
+	"1234567890",
+	"00000000",
+	"Asset-1234567890",
+}
+
+// placeholderSmbiosUuids are SMBIOS UUIDs that vendors ship on many machines instead of a unique one.
+var placeholderSmbiosUuids = []uuid.UUID{
+	uuid.Nil,
+	uuid.Max,
+	uuid.MustParse("03000200-0400-0500-0006-000700080009"),
+	uuid.MustParse("00020003-0004-0005-0006-000700080009"),
+}
+
+// Identifiers are the properties of a system that it can be recognized by when it requests its iPXE config.
+// iPXE can send all of them, using '${mac}', '${uuid}', '${serial}', '${asset}' and '${hostname}'.
+type Identifiers struct {
+	// Interfaces are the network interfaces of the system, which it is recognized by using their MAC addresses.
+	Interfaces Interfaces
+	// SmbiosUuid is the SMBIOS UUID of the system, or uuid.Nil if it is unknown.
+	SmbiosUuid uuid.UUID
+	Serial     string
+	AssetTag   string
+	Hostname   string
+}
+
+// IsEmpty returns whether none of the identifiers are set, in which case a system can never be matched.
+func (i Identifiers) IsEmpty() bool {
+	return len(i.Interfaces) == 0 && i.SmbiosUuid == uuid.Nil && i.Serial == "" && i.AssetTag == "" && i.Hostname == ""
+}
+
+// SmbiosUuidString returns the SMBIOS UUID as a string, which is empty if it is unknown.
+func (i Identifiers) SmbiosUuidString() string {
+	if i.SmbiosUuid == uuid.Nil {
+		return ""
+	}
+	return i.SmbiosUuid.String()
+}
+
+// WithoutPlaceholders returns a copy of the identifiers without the SMBIOS UUID, serial number and asset tag
+// if they are known vendor placeholders, e.g. 'To be filled by O.E.M.', which are shared by many machines.
+func (i Identifiers) WithoutPlaceholders() Identifiers {
+	if isPlaceholderSmbiosUuid(i.SmbiosUuid) {
+		i.SmbiosUuid = uuid.Nil
+	}
+
+	if isPlaceholderValue(i.Serial) {
+		i.Serial = ""
+	}
+
+	if isPlaceholderValue(i.AssetTag) {
+		i.AssetTag = ""
+	}
+
+	return i
+}
+
+func isPlaceholderValue(v string) bool {
+	v = strings.TrimSpace(v)
+	for _, p := range placeholderValues {
+		if strings.EqualFold(v, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isPlaceholderSmbiosUuid(id uuid.UUID) bool {
+	for _, p := range placeholderSmbiosUuids {
+		if id == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateIdentifiers(i Identifiers) error {
+	if i.IsEmpty() {
+		return errors.New("system needs at least one identifier: a MAC address, SMBIOS UUID, serial number, asset tag or hostname")
+	}
+
+	// Placeholders would match every machine of the vendor, and keep other systems with the same placeholder from being registered
+	if i.SmbiosUuid != uuid.Nil && isPlaceholderSmbiosUuid(i.SmbiosUuid) {
+		return errors.New("SMBIOS UUID " + i.SmbiosUuid.String() + " is a vendor placeholder and does not identify a single system")
+	}
+
+	if i.Serial != "" && isPlaceholderValue(i.Serial) {
+		return errors.New("serial number " + i.Serial + " is a vendor placeholder and does not identify a single system")
+	}
+
+	if i.AssetTag != "" && isPlaceholderValue(i.AssetTag) {
+		return errors.New("asset tag " + i.AssetTag + " is a vendor placeholder and does not identify a single system")
+	}
+
+	if err := validateInterfaces(i.Interfaces); err != nil {
+		return err
+	}
+
+	if i.Hostname != "" {
+		if err := validateHostname(i.Hostname); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateHostname(hostname string) error {
+	p := `^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`
+	matched, err := regexp.MatchString(p, hostname)
+	if err != nil {
+		return err
+	}
+
+	if !matched || len(hostname) > 253 {
+		return errors.New("invalid hostname " + hostname)
+	}
+
+	return nil
+}
+
+// FindSystem finds the registered system that matches the passed identifiers, which are usually sent by iPXE.
+// The identifiers are tried in order of how reliably they identify a single machine:
+// the SMBIOS UUID first, then the serial number, the asset tag, the MAC addresses and finally the hostname.
+// Vendor placeholders are ignored, since they would match another machine of the same vendor (see Identifiers.WithoutPlaceholders).
+// If no system matches any of them, repository.ErrNotFound is returned.
+func FindSystem(r Repository, i Identifiers) (System, error) {
+	i = i.WithoutPlaceholders()
+	lookups := make([]func() (System, error), 0)
+
+	if i.SmbiosUuid != uuid.Nil {
+		lookups = append(lookups, func() (System, error) { return r.GetSystemBySmbiosUuid(i.SmbiosUuid) })
+	}
+
+	if i.Serial != "" {
+		lookups = append(lookups, func() (System, error) { return r.GetSystemBySerial(i.Serial) })
+	}
+
+	if i.AssetTag != "" {
+		lookups = append(lookups, func() (System, error) { return r.GetSystemByAssetTag(i.AssetTag) })
+	}
+
+	for _, m := range i.Interfaces.Macs() {
+		mac := m
+		lookups = append(lookups, func() (System, error) { return r.GetSystemByMacAddress(mac) })
+	}
+
+	if i.Hostname != "" {
+		lookups = append(lookups, func() (System, error) { return r.GetSystemByHostname(i.Hostname) })
+	}
+
+	for _, lookup := range lookups {
+		s, err := lookup()
+		if err == nil {
+			return s, nil
+		}
+
+		if !errors.Is(err, repository.ErrNotFound) {
+			return s, err
+		}
+	}
+
+	return System{}, repository.ErrNotFound
+}
@@ -0,0 +1,168 @@
+package system
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/repository"
+	"github.com/google/uuid"
+	"net"
+	"testing"
+)
+
+// identifierRepository is an in-memory Repository that only supports looking up systems by their identifiers.
+type identifierRepository struct {
+	Repository
+	systems []System
+}
+
+func (r identifierRepository) find(match func(s System) bool) (System, error) {
+	for _, s := range r.systems {
+		if match(s) {
+			return s, nil
+		}
+	}
+	return System{}, repository.ErrNotFound
+}
+
+func (r identifierRepository) GetSystemByMacAddress(mac net.HardwareAddr) (System, error) {
+	return r.find(func(s System) bool {
+		for _, m := range s.Identifiers.Interfaces.Macs() {
+			if m.String() == mac.String() {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r identifierRepository) GetSystemBySmbiosUuid(smbiosUuid uuid.UUID) (System, error) {
+	return r.find(func(s System) bool { return s.Identifiers.SmbiosUuid == smbiosUuid })
+}
+
+func (r identifierRepository) GetSystemBySerial(serial string) (System, error) {
+	return r.find(func(s System) bool { return s.Identifiers.Serial == serial })
+}
+
+func (r identifierRepository) GetSystemByAssetTag(assetTag string) (System, error) {
+	return r.find(func(s System) bool { return s.Identifiers.AssetTag == assetTag })
+}
+
+func (r identifierRepository) GetSystemByHostname(hostname string) (System, error) {
+	return r.find(func(s System) bool { return s.Identifiers.Hostname == hostname })
+}
+
+func TestNewNoIdentifiers(t *testing.T) {
+	actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, Identifiers{}, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
+	if err == nil {
+		t.Fatalf(`New() = %v, %v, expected: System{}, error`, actual, err)
+	}
+}
+
+func TestNewDuplicateMac(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`New(): failed to parse MAC address`)
+	}
+
+	i := Identifiers{Interfaces: Interfaces{{Mac: mac}, {Mac: mac}}}
+	actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, i, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
+	if err == nil {
+		t.Fatalf(`New() = %v, %v, expected: System{}, error`, actual, err)
+	}
+}
+
+func TestNewInvalidHostname(t *testing.T) {
+	for _, hostname := range []string{"-host", "host name", "host_name", "host..example.com"} {
+		i := Identifiers{Hostname: hostname}
+		actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, i, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
+		if err == nil {
+			t.Fatalf(`New() with hostname %q = %v, %v, expected: System{}, error`, hostname, actual, err)
+		}
+	}
+}
+
+func TestFindSystem(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`FindSystem(): failed to parse MAC address`)
+	}
+
+	smbiosUuid := uuid.New()
+	bySmbiosUuid := System{Id: uuid.New(), Identifiers: Identifiers{SmbiosUuid: smbiosUuid}}
+	bySerial := System{Id: uuid.New(), Identifiers: Identifiers{Serial: "SN123"}}
+	byMac := System{Id: uuid.New(), Identifiers: Identifiers{Interfaces: Interfaces{{Mac: mac}}}}
+	byHostname := System{Id: uuid.New(), Identifiers: Identifiers{Hostname: "host.example.com"}}
+	r := identifierRepository{systems: []System{byHostname, byMac, bySerial, bySmbiosUuid}}
+
+	tests := []struct {
+		identifiers Identifiers
+		expected    uuid.UUID
+	}{
+		{Identifiers{SmbiosUuid: smbiosUuid, Serial: "SN123", Interfaces: Interfaces{{Mac: mac}}, Hostname: "host.example.com"}, bySmbiosUuid.Id},
+		{Identifiers{SmbiosUuid: uuid.New(), Serial: "SN123", Interfaces: Interfaces{{Mac: mac}}}, bySerial.Id},
+		{Identifiers{Serial: "unknown", Interfaces: Interfaces{{Mac: mac}}, Hostname: "host.example.com"}, byMac.Id},
+		{Identifiers{Hostname: "host.example.com"}, byHostname.Id},
+	}
+
+	for _, test := range tests {
+		actual, err := FindSystem(r, test.identifiers)
+		if err != nil || actual.Id != test.expected {
+			t.Fatalf(`FindSystem(%v) = %v, %v, expected: %v, nil`, test.identifiers, actual.Id, err, test.expected)
+		}
+	}
+
+	actual, err := FindSystem(r, Identifiers{Serial: "unknown"})
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf(`FindSystem() = %v, %v, expected: System{}, %v`, actual, err, repository.ErrNotFound)
+	}
+}
+
+func TestFindSystemIgnoresPlaceholders(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`FindSystem(): failed to parse MAC address`)
+	}
+
+	// A system that was registered with placeholders before they were rejected, which should not match other machines
+	placeholder := System{Id: uuid.New(), Identifiers: Identifiers{Serial: "To be filled by O.E.M.", SmbiosUuid: uuid.MustParse("03000200-0400-0500-0006-000700080009")}}
+	byMac := System{Id: uuid.New(), Identifiers: Identifiers{Interfaces: Interfaces{{Mac: mac}}}}
+	r := identifierRepository{systems: []System{placeholder, byMac}}
+
+	i := Identifiers{Serial: "to be filled by o.e.m.", SmbiosUuid: placeholder.Identifiers.SmbiosUuid, Interfaces: Interfaces{{Mac: mac}}}
+	actual, err := FindSystem(r, i)
+	if err != nil || actual.Id != byMac.Id {
+		t.Fatalf(`FindSystem(%v) = %v, %v, expected: %v, nil`, i, actual.Id, err, byMac.Id)
+	}
+
+	i = Identifiers{Serial: "0123456789"}
+	actual, err = FindSystem(r, i)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf(`FindSystem(%v) = %v, %v, expected: System{}, %v`, i, actual, err, repository.ErrNotFound)
+	}
+}
+
+func TestNewPlaceholderIdentifiers(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`New(): failed to parse MAC address`)
+	}
+
+	interfaces := Interfaces{{Mac: mac}}
+	for _, i := range []Identifiers{
+		{Interfaces: interfaces, Serial: "To be filled by O.E.M."},
+		{Interfaces: interfaces, Serial: "0123456789"},
+		{Interfaces: interfaces, AssetTag: "Default string"},
+		{Interfaces: interfaces, SmbiosUuid: uuid.Max},
+	} {
+		actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, i, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
+		if err == nil {
+			t.Fatalf(`New() with identifiers %v = %v, %v, expected: System{}, error`, i, actual, err)
+		}
+	}
+
+	// Promoted systems leave the placeholders out instead
+	i := Identifiers{Interfaces: interfaces, Serial: "To be filled by O.E.M.", SmbiosUuid: uuid.Max}.WithoutPlaceholders()
+	if _, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, i, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil); err != nil {
+		t.Fatalf(`New() with identifiers without placeholders = %v, expected: nil`, err)
+	}
+}
@@ -0,0 +1,25 @@
+package system
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// NewInstallToken generates a random token that a system can use once to report that its installation has completed.
+// A new token is generated every time a system boots a profile; only its hash (see HashInstallToken) is stored.
+func NewInstallToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// HashInstallToken returns the hash of the passed install token, which is what is stored and looked up.
+func HashInstallToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
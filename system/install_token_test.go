@@ -0,0 +1,24 @@
+package system
+
+import "testing"
+
+func TestNewInstallToken(t *testing.T) {
+	t1, err := NewInstallToken()
+	if err != nil || len(t1) != 64 {
+		t.Fatalf(`NewInstallToken() = %v, %v, expected a 64 character token, nil`, t1, err)
+	}
+
+	t2, err := NewInstallToken()
+	if err != nil || t1 == t2 {
+		t.Fatalf(`NewInstallToken() returned the same token twice: %v, %v`, t2, err)
+	}
+}
+
+func TestHashInstallToken(t *testing.T) {
+	expected := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	actual := HashInstallToken("test")
+	if actual != expected {
+		t.Fatalf(`HashInstallToken() = %v, expected: %v`, actual, expected)
+	}
+}
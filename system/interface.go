@@ -0,0 +1,214 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Interface is a network interface of a system, which the system can be recognized by when it boots from it.
+type Interface struct {
+	Mac net.HardwareAddr
+	// Name is the name of the interface in the operating system, e.g. 'eno1'. It is optional.
+	Name string
+	// Boot marks the interface that the system is expected to boot from. At most one interface of a system can be marked.
+	Boot bool
+	// Network is the static network configuration of the interface. It is empty if the interface uses e.g. DHCP.
+	Network NetworkConfig
+}
+
+// Interfaces are the network interfaces of a system.
+type Interfaces []Interface
+
+// bootFlag is the word that marks an interface as the boot interface in the string representation of Interfaces.
+const bootFlag = "boot"
+
+// ParseInterfaces parses interfaces from a string containing one interface per line, in the format
+// '<mac> [name] [boot] [ip=<address>/<prefix>] [gw=<gateway>] [dns=<nameserver>,...] [hostname=<hostname>]'.
+// Everything after the MAC address can be passed in any order. Empty lines are ignored.
+func ParseInterfaces(s string) (Interfaces, error) {
+	var interfaces Interfaces
+
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		i, err := parseInterface(fields)
+		if err != nil {
+			return interfaces, fmt.Errorf("invalid interface '%s': %w", strings.TrimSpace(line), err)
+		}
+
+		interfaces = append(interfaces, i)
+	}
+
+	return interfaces, nil
+}
+
+// parseInterface parses a single interface from the whitespace-separated fields of its line, see ParseInterfaces.
+func parseInterface(fields []string) (Interface, error) {
+	var i Interface
+
+	mac, err := net.ParseMAC(fields[0])
+	if err != nil {
+		return i, err
+	}
+	i.Mac = mac
+
+	for _, f := range fields[1:] {
+		key, value, isOption := strings.Cut(f, "=")
+		if !isOption {
+			if f == bootFlag {
+				i.Boot = true
+				continue
+			}
+
+			if i.Name != "" {
+				return i, errors.New("interface can only have one name")
+			}
+			i.Name = f
+			continue
+		}
+
+		switch key {
+		case "ip":
+			ip, ipNet, err := net.ParseCIDR(value)
+			if err != nil {
+				return i, err
+			}
+			i.Network.Address = ip
+			i.Network.Netmask = ipNet.Mask
+		case "gw":
+			i.Network.Gateway = net.ParseIP(value)
+			if i.Network.Gateway == nil {
+				return i, errors.New("invalid gateway " + value)
+			}
+		case "dns":
+			for _, v := range strings.Split(value, ",") {
+				ns := net.ParseIP(v)
+				if ns == nil {
+					return i, errors.New("invalid nameserver " + v)
+				}
+				i.Network.Nameservers = append(i.Network.Nameservers, ns)
+			}
+		case "hostname":
+			i.Network.Hostname = value
+		default:
+			return i, errors.New("unknown option " + key)
+		}
+	}
+
+	return i, nil
+}
+
+// String returns the interfaces in the format accepted by ParseInterfaces.
+func (interfaces Interfaces) String() string {
+	lines := make([]string, 0, len(interfaces))
+	for _, i := range interfaces {
+		fields := []string{i.Mac.String()}
+		if i.Name != "" {
+			fields = append(fields, i.Name)
+		}
+		if i.Boot {
+			fields = append(fields, bootFlag)
+		}
+		if i.Network.Address != nil {
+			fields = append(fields, "ip="+i.Network.AddressString())
+		}
+		if i.Network.Gateway != nil {
+			fields = append(fields, "gw="+i.Network.Gateway.String())
+		}
+		if len(i.Network.Nameservers) > 0 {
+			fields = append(fields, "dns="+strings.Join(i.Network.NameserverStrings(), ","))
+		}
+		if i.Network.Hostname != "" {
+			fields = append(fields, "hostname="+i.Network.Hostname)
+		}
+		lines = append(lines, strings.Join(fields, " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Macs returns the MAC addresses of the interfaces.
+func (interfaces Interfaces) Macs() []net.HardwareAddr {
+	macs := make([]net.HardwareAddr, 0, len(interfaces))
+	for _, i := range interfaces {
+		macs = append(macs, i.Mac)
+	}
+	return macs
+}
+
+// BootInterface returns the interface that is marked as the boot interface.
+// If none is marked, the first interface is returned. The boolean is false if there are no interfaces.
+func (interfaces Interfaces) BootInterface() (Interface, bool) {
+	for _, i := range interfaces {
+		if i.Boot {
+			return i, true
+		}
+	}
+
+	if len(interfaces) == 0 {
+		return Interface{}, false
+	}
+
+	return interfaces[0], true
+}
+
+func validateInterfaces(interfaces Interfaces) error {
+	macs := make(map[string]bool)
+	names := make(map[string]bool)
+	boot := false
+
+	for _, i := range interfaces {
+		if len(i.Mac) == 0 {
+			return errors.New("interface needs a MAC address")
+		}
+
+		if macs[i.Mac.String()] {
+			return errors.New("MAC address " + i.Mac.String() + " is assigned more than once")
+		}
+		macs[i.Mac.String()] = true
+
+		if i.Name != "" {
+			if err := validateInterfaceName(i.Name); err != nil {
+				return err
+			}
+
+			if names[i.Name] {
+				return errors.New("interface name " + i.Name + " is used more than once")
+			}
+			names[i.Name] = true
+		}
+
+		if err := validateNetworkConfig(i.Network); err != nil {
+			return fmt.Errorf("invalid network configuration for interface %s: %w", i.Mac, err)
+		}
+
+		if i.Boot {
+			if boot {
+				return errors.New("only one interface can be the boot interface")
+			}
+			boot = true
+		}
+	}
+
+	return nil
+}
+
+func validateInterfaceName(name string) error {
+	// Linux limits interface names to 15 characters
+	p := `^[a-zA-Z0-9_.-]{1,15}$`
+	matched, err := regexp.MatchString(p, name)
+	if err != nil {
+		return err
+	}
+
+	if !matched || name == bootFlag {
+		return errors.New("invalid interface name " + name)
+	}
+
+	return nil
+}
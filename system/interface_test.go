@@ -0,0 +1,98 @@
+package system
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseInterfaces(t *testing.T) {
+	mac1, _ := net.ParseMAC("11:22:33:44:55:66")
+	mac2, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+	mac3, _ := net.ParseMAC("00:11:22:33:44:55")
+
+	s := "11:22:33:44:55:66 eno1 boot\n\naa:bb:cc:dd:ee:ff eno2\n00:11:22:33:44:55 boot\n"
+	expected := Interfaces{
+		{Mac: mac1, Name: "eno1", Boot: true},
+		{Mac: mac2, Name: "eno2"},
+		{Mac: mac3, Boot: true},
+	}
+
+	actual, err := ParseInterfaces(s)
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`ParseInterfaces() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestParseInterfacesInvalid(t *testing.T) {
+	for _, s := range []string{"invalid", "11:22:33:44:55:66 eno1 eno2", "11:22:33:44:55:66 eno1 boot extra"} {
+		actual, err := ParseInterfaces(s)
+		if err == nil {
+			t.Fatalf(`ParseInterfaces(%q) = %v, %v, expected: Interfaces{}, error`, s, actual, err)
+		}
+	}
+}
+
+func TestInterfacesString(t *testing.T) {
+	expected := "11:22:33:44:55:66 eno1 boot\naa:bb:cc:dd:ee:ff"
+
+	interfaces, err := ParseInterfaces(expected)
+	if err != nil {
+		t.Fatalf(`String(): failed to parse interfaces`)
+	}
+
+	actual := interfaces.String()
+	if actual != expected {
+		t.Fatalf(`String() = %q, expected: %q`, actual, expected)
+	}
+}
+
+func TestInterfacesBootInterface(t *testing.T) {
+	mac1, _ := net.ParseMAC("11:22:33:44:55:66")
+	mac2, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+
+	tests := []struct {
+		interfaces Interfaces
+		expected   net.HardwareAddr
+	}{
+		{Interfaces{{Mac: mac1}, {Mac: mac2, Boot: true}}, mac2},
+		{Interfaces{{Mac: mac1}, {Mac: mac2}}, mac1},
+	}
+
+	for _, test := range tests {
+		actual, ok := test.interfaces.BootInterface()
+		if !ok || actual.Mac.String() != test.expected.String() {
+			t.Fatalf(`BootInterface() = %v, %v, expected: %v, true`, actual.Mac, ok, test.expected)
+		}
+	}
+
+	_, ok := Interfaces{}.BootInterface()
+	if ok {
+		t.Fatalf(`BootInterface() = _, true, expected: _, false`)
+	}
+}
+
+func TestValidateInterfaces(t *testing.T) {
+	mac1, _ := net.ParseMAC("11:22:33:44:55:66")
+	mac2, _ := net.ParseMAC("aa:bb:cc:dd:ee:ff")
+
+	invalid := []Interfaces{
+		{{Mac: mac1}, {Mac: mac1}},
+		{{Mac: mac1, Name: "eno1"}, {Mac: mac2, Name: "eno1"}},
+		{{Mac: mac1, Boot: true}, {Mac: mac2, Boot: true}},
+		{{Mac: mac1, Name: "name with spaces"}},
+		{{Mac: mac1, Name: "averyveryverylongname"}},
+		{{Name: "eno1"}},
+	}
+
+	for _, interfaces := range invalid {
+		if err := validateInterfaces(interfaces); err == nil {
+			t.Fatalf(`validateInterfaces(%v) = nil, expected: error`, interfaces)
+		}
+	}
+
+	valid := Interfaces{{Mac: mac1, Name: "eno1", Boot: true}, {Mac: mac2}}
+	if err := validateInterfaces(valid); err != nil {
+		t.Fatalf(`validateInterfaces(%v) = %v, expected: nil`, valid, err)
+	}
+}
@@ -0,0 +1,204 @@
+package system
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NetworkConfig is the static network configuration of an Interface.
+type NetworkConfig struct {
+	// Address is the static IP address of the interface, or nil if it does not have one.
+	Address     net.IP
+	Netmask     net.IPMask
+	Gateway     net.IP
+	Nameservers []net.IP
+	Hostname    string
+}
+
+// IsEmpty returns whether nothing has been configured.
+func (n NetworkConfig) IsEmpty() bool {
+	return n.Address == nil && n.Gateway == nil && len(n.Nameservers) == 0 && n.Hostname == ""
+}
+
+// AddressString returns the address in CIDR notation, e.g. '192.168.1.10/24', or an empty string if there is no address.
+func (n NetworkConfig) AddressString() string {
+	if n.Address == nil {
+		return ""
+	}
+
+	ones, _ := n.Netmask.Size()
+	return n.Address.String() + "/" + strconv.Itoa(ones)
+}
+
+// NameserverStrings returns the nameservers as strings.
+func (n NetworkConfig) NameserverStrings() []string {
+	s := make([]string, 0, len(n.Nameservers))
+	for _, ns := range n.Nameservers {
+		s = append(s, ns.String())
+	}
+	return s
+}
+
+func validateNetworkConfig(n NetworkConfig) error {
+	if n.Address == nil {
+		if n.Netmask != nil || n.Gateway != nil {
+			return errors.New("a netmask or gateway requires an address")
+		}
+	} else {
+		if n.Netmask == nil {
+			return errors.New("an address requires a netmask")
+		}
+
+		if ones, bits := n.Netmask.Size(); bits == 0 || bits != len(ipBytes(n.Address))*8 || ones == 0 {
+			return errors.New("invalid netmask for address " + n.Address.String())
+		}
+
+		if n.Gateway != nil && (n.Gateway.To4() == nil) != (n.Address.To4() == nil) {
+			return errors.New("the gateway and address must both be IPv4 or IPv6")
+		}
+	}
+
+	if n.Hostname != "" {
+		if err := validateHostname(n.Hostname); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ipBytes returns the 4-byte representation of IPv4 addresses, and the 16-byte representation of IPv6 addresses.
+func ipBytes(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+// NetworkKernelParameters generates the kernel parameters that configure the network of the interfaces in the passed style.
+// Interfaces without any network configuration are left to e.g. DHCP, and profile.NetworkStyleNone never generates anything.
+func (interfaces Interfaces) NetworkKernelParameters(style profile.NetworkStyle) kernelparameters.KernelParameters {
+	switch style {
+	case profile.NetworkStyleDracut:
+		return interfaces.dracutKernelParameters()
+	case profile.NetworkStyleDebianInstaller:
+		return interfaces.debianInstallerKernelParameters()
+	default:
+		return kernelparameters.KernelParameters{}
+	}
+}
+
+// dracutKernelParameters generates the 'ifname=', 'ip=' and 'nameserver=' parameters that are used by dracut, see dracut.cmdline(7).
+// Every configured interface is named using 'ifname=', so 'ip=' can refer to it; unnamed interfaces are called 'net<index>'.
+func (interfaces Interfaces) dracutKernelParameters() kernelparameters.KernelParameters {
+	kp := kernelparameters.KernelParameters{}
+	var nameservers []string
+	seen := make(map[string]bool)
+
+	for index, i := range interfaces {
+		if i.Network.IsEmpty() {
+			continue
+		}
+
+		name := i.Name
+		if name == "" {
+			name = "net" + strconv.Itoa(index)
+		}
+		kp = append(kp, kernelparameters.KernelParameter{Key: "ifname", Value: name + ":" + i.Mac.String(), HasValue: true})
+
+		// ip=<client-IP>:[<peer>]:<gateway-IP>:<netmask>:<client_hostname>:<interface>:{none|dhcp}
+		n := i.Network
+		if n.Address != nil {
+			fields := []string{dracutIp(n.Address), "", dracutIp(n.Gateway), formatNetmask(n.Address, n.Netmask), n.Hostname, name, "none"}
+			kp = append(kp, kernelparameters.KernelParameter{Key: "ip", Value: strings.Join(fields, ":"), HasValue: true})
+		} else if n.Hostname != "" {
+			fields := []string{"", "", "", "", n.Hostname, name, "dhcp"}
+			kp = append(kp, kernelparameters.KernelParameter{Key: "ip", Value: strings.Join(fields, ":"), HasValue: true})
+		}
+
+		for _, ns := range n.NameserverStrings() {
+			if !seen[ns] {
+				seen[ns] = true
+				nameservers = append(nameservers, ns)
+			}
+		}
+	}
+
+	for _, ns := range nameservers {
+		kp = append(kp, kernelparameters.KernelParameter{Key: "nameserver", Value: ns, HasValue: true})
+	}
+
+	return kp
+}
+
+// dracutIp formats an IP address for dracut, which requires IPv6 addresses to be enclosed in brackets.
+func dracutIp(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+
+	if ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+
+	return ip.String()
+}
+
+// formatNetmask formats a netmask as a dotted netmask for IPv4, and as a prefix length for IPv6.
+func formatNetmask(ip net.IP, mask net.IPMask) string {
+	if ip.To4() == nil {
+		ones, _ := mask.Size()
+		return strconv.Itoa(ones)
+	}
+
+	return net.IP(mask).String()
+}
+
+// debianInstallerKernelParameters generates the 'netcfg/*' parameters that are used by the Debian installer.
+// The installer only configures a single interface, so only the boot interface is used.
+func (interfaces Interfaces) debianInstallerKernelParameters() kernelparameters.KernelParameters {
+	kp := kernelparameters.KernelParameters{}
+
+	i, ok := interfaces.BootInterface()
+	if !ok || i.Network.IsEmpty() {
+		return kp
+	}
+
+	n := i.Network
+	add := func(key, value string) {
+		kp = append(kp, kernelparameters.KernelParameter{Key: key, Value: value, HasValue: true})
+	}
+
+	if i.Name != "" {
+		add("netcfg/choose_interface", i.Name)
+	}
+
+	if n.Address != nil {
+		add("netcfg/disable_autoconfig", "true")
+		add("netcfg/get_ipaddress", n.Address.String())
+		add("netcfg/get_netmask", formatNetmask(n.Address, n.Netmask))
+		if n.Gateway != nil {
+			add("netcfg/get_gateway", n.Gateway.String())
+		}
+		add("netcfg/confirm_static", "true")
+	}
+
+	if len(n.Nameservers) > 0 {
+		add("netcfg/get_nameservers", strings.Join(n.NameserverStrings(), " "))
+	}
+
+	if n.Hostname != "" {
+		// The installer asks for the hostname and domain separately
+		host, domain, _ := strings.Cut(n.Hostname, ".")
+		add("netcfg/get_hostname", host)
+		if domain != "" {
+			add("netcfg/get_domain", domain)
+		}
+	}
+
+	return kp
+}
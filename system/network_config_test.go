@@ -0,0 +1,107 @@
+package system
+
+import (
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/google/uuid"
+	"net"
+	"testing"
+)
+
+func TestParseInterfacesNetworkConfig(t *testing.T) {
+	s := "11:22:33:44:55:66 eno1 boot ip=192.168.1.10/24 gw=192.168.1.1 dns=192.168.1.2,192.168.1.3 hostname=web01.example.com"
+
+	interfaces, err := ParseInterfaces(s)
+	if err != nil {
+		t.Fatalf(`ParseInterfaces() = %v, %v, expected: Interfaces, nil`, interfaces, err)
+	}
+
+	n := interfaces[0].Network
+	if n.AddressString() != "192.168.1.10/24" || n.Gateway.String() != "192.168.1.1" || len(n.Nameservers) != 2 || n.Hostname != "web01.example.com" {
+		t.Fatalf(`ParseInterfaces() = %+v, expected network configuration to be parsed`, n)
+	}
+
+	if interfaces.String() != s {
+		t.Fatalf(`String() = %q, expected: %q`, interfaces.String(), s)
+	}
+}
+
+func TestValidateNetworkConfig(t *testing.T) {
+	invalid := []NetworkConfig{
+		{Address: net.ParseIP("192.168.1.10")},
+		{Gateway: net.ParseIP("192.168.1.1")},
+		{Address: net.ParseIP("192.168.1.10"), Netmask: net.CIDRMask(24, 32), Gateway: net.ParseIP("fe80::1")},
+		{Address: net.ParseIP("192.168.1.10"), Netmask: net.CIDRMask(64, 128)},
+		{Hostname: "invalid_hostname"},
+	}
+
+	for _, n := range invalid {
+		if err := validateNetworkConfig(n); err == nil {
+			t.Fatalf(`validateNetworkConfig(%+v) = nil, expected: error`, n)
+		}
+	}
+}
+
+func testNetworkInterfaces(t *testing.T) Interfaces {
+	interfaces, err := ParseInterfaces("11:22:33:44:55:66 eno1 ip=192.168.1.10/24 gw=192.168.1.1 dns=192.168.1.2 hostname=web01.example.com\n" +
+		"aa:bb:cc:dd:ee:ff boot ip=2001:db8::10/64 gw=2001:db8::1 dns=192.168.1.2,2001:db8::2\n" +
+		"00:11:22:33:44:55")
+	if err != nil {
+		t.Fatalf(`failed to parse interfaces: %v`, err)
+	}
+	return interfaces
+}
+
+func TestInterfacesNetworkKernelParametersDracut(t *testing.T) {
+	expected := "ifname=eno1:11:22:33:44:55:66 ip=192.168.1.10::192.168.1.1:255.255.255.0:web01.example.com:eno1:none " +
+		"ifname=net1:aa:bb:cc:dd:ee:ff ip=[2001:db8::10]::[2001:db8::1]:64::net1:none " +
+		"nameserver=192.168.1.2 nameserver=2001:db8::2"
+
+	actual := testNetworkInterfaces(t).NetworkKernelParameters(profile.NetworkStyleDracut).String()
+	if actual != expected {
+		t.Fatalf(`NetworkKernelParameters() = %q, expected: %q`, actual, expected)
+	}
+}
+
+func TestInterfacesNetworkKernelParametersDebianInstaller(t *testing.T) {
+	interfaces := testNetworkInterfaces(t)
+	interfaces[0].Boot = true
+	interfaces[1].Boot = false
+
+	expected := "netcfg/choose_interface=eno1 netcfg/disable_autoconfig=true netcfg/get_ipaddress=192.168.1.10 " +
+		"netcfg/get_netmask=255.255.255.0 netcfg/get_gateway=192.168.1.1 netcfg/confirm_static=true " +
+		"netcfg/get_nameservers=192.168.1.2 netcfg/get_hostname=web01 netcfg/get_domain=example.com"
+
+	actual := interfaces.NetworkKernelParameters(profile.NetworkStyleDebianInstaller).String()
+	if actual != expected {
+		t.Fatalf(`NetworkKernelParameters() = %q, expected: %q`, actual, expected)
+	}
+}
+
+func TestInterfacesNetworkKernelParametersNone(t *testing.T) {
+	actual := testNetworkInterfaces(t).NetworkKernelParameters(profile.NetworkStyleNone)
+	if len(actual) != 0 {
+		t.Fatalf(`NetworkKernelParameters() = %v, expected: no parameters`, actual)
+	}
+}
+
+func TestEffectiveKernelParametersNetwork(t *testing.T) {
+	p := profile.Profile{
+		Name:             "TestProfile",
+		KernelParameters: kernelparameters.KernelParameters{{Key: "ip", Value: "dhcp"}, {Key: "quiet"}},
+		NetworkStyle:     profile.NetworkStyleDracut,
+	}
+
+	interfaces, err := ParseInterfaces("11:22:33:44:55:66 eno1 ip=192.168.1.10/24")
+	if err != nil {
+		t.Fatalf(`EffectiveKernelParameters(): failed to parse interfaces`)
+	}
+
+	s := System{Id: uuid.New(), Name: "TestSystem", Identifiers: Identifiers{Interfaces: interfaces}}
+
+	expected := "ip=192.168.1.10:::255.255.255.0::eno1:none quiet ifname=eno1:11:22:33:44:55:66"
+	actual := s.EffectiveKernelParameters(p).String()
+	if actual != expected {
+		t.Fatalf(`EffectiveKernelParameters() = %q, expected: %q`, actual, expected)
+	}
+}
@@ -0,0 +1,33 @@
+package system
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/google/uuid"
+)
+
+// NextBoot is a one-shot override of the boot configuration of a system.
+// It is served the next time the system requests its iPXE config, after which it is cleared and the system boots normally again.
+type NextBoot struct {
+	// Profile is booted instead of the profile of the system. If it is uuid.Nil, the profile of the system is used.
+	Profile uuid.UUID
+	// KernelParameters are merged on top of the kernel parameters of the profile and the system.
+	KernelParameters kernelparameters.KernelParameters
+}
+
+func NewNextBoot(profile uuid.UUID, kernelParameters kernelparameters.KernelParameters) (NextBoot, error) {
+	var n NextBoot
+
+	if profile == uuid.Nil && len(kernelParameters) == 0 {
+		return n, errors.New("next boot needs a profile, kernel parameters or both")
+	}
+
+	if err := kernelParameters.ValidateVariables(); err != nil {
+		return n, err
+	}
+
+	return NextBoot{
+		Profile:          profile,
+		KernelParameters: kernelParameters,
+	}, nil
+}
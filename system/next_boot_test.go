@@ -0,0 +1,27 @@
+package system
+
+import (
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/google/uuid"
+	"reflect"
+	"testing"
+)
+
+func TestNewNextBoot(t *testing.T) {
+	profileId := uuid.New()
+	kp := kernelparameters.KernelParameters{{Key: "rd.break"}}
+
+	expected := NextBoot{Profile: profileId, KernelParameters: kp}
+
+	actual, err := NewNextBoot(profileId, kp)
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`NewNextBoot() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestNewNextBootEmpty(t *testing.T) {
+	actual, err := NewNextBoot(uuid.Nil, kernelparameters.KernelParameters{})
+	if err == nil {
+		t.Fatalf(`Expected NewNextBoot() to return empty next boot error, got: %v, %v`, actual, err)
+	}
+}
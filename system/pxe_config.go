@@ -2,17 +2,24 @@ package system
 
 import (
 	"fmt"
+	"github.com/evanebb/gobble/configtemplate"
 	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"text/template"
 )
 
-// TODO: create a PxeConfig interface with a Render() method, have the System struct implement it
+// Default iPXE script template that is served to clients if their profile does not define its own template.
+// The configured kernel, initrds and kernel parameters are substituted into it.
+var defaultTemplate = `#!ipxe
 
-// iPXE script template that is served to clients.
-// The configured kernel, initrd and kernel parameters are substituted into it.
-var template = `#!ipxe
-
-kernel %s %s
-initrd %s
+kernel {{ .Profile.Kernel }} {{ .KernelParameters }}
+{{- range .Profile.Initrds }}
+initrd {{ if .Name }}--name {{ .Name }} {{ end }}{{ .Path }}
+{{- end }}
 
 boot
 `
@@ -25,23 +32,232 @@ prompt --timeout 30000 Press any key or wait 30 seconds to continue ||
 
 `
 
+// iPXE scripts that are served to systems with a boot mode that does not boot a profile.
+var bootModeScripts = map[BootMode]string{
+	BootModeLocalDisk: `#!ipxe
+
+echo Booting from local disk
+sanboot --no-describe --drive 0x80
+`,
+	BootModeExit: `#!ipxe
+
+exit
+`,
+	BootModeShell: `#!ipxe
+
+shell
+`,
+	BootModeHalt: `#!ipxe
+
+echo Booting has been halted for this system
+:halt
+sleep 3600
+goto halt
+`,
+}
+
+// iPXE script template that is served to systems that have menu profiles, so a profile can be chosen at the console.
+// Every entry contains the script of its profile, without the '#!ipxe' header.
+var menuTemplate = `#!ipxe
+
+menu Boot menu for {{ .System.Name }}
+{{- range .Entries }}
+item {{ .Label }} {{ .Profile.Name }}
+{{- end }}
+choose {{ if .Timeout }}--timeout {{ .Timeout }} {{ end }}--default {{ .Default }} selected || exit
+goto ${selected}
+{{ range .Entries }}
+:{{ .Label }}
+{{ .Script }}
+{{ end }}`
+
+// PxeConfig contains everything that is needed to render the iPXE script for a single system.
+// It is also the data that is passed into the iPXE script templates.
 type PxeConfig struct {
-	Kernel           string
-	Initrd           string
-	KernelParameters kernelparameters.KernelParameters
+	System           System
+	Profile          profile.Profile
+	KernelParameters kernelparameters.EffectiveKernelParameters
+	// Variables are the variables that were expanded in the kernel, initrds and kernel parameters, e.g. '{{ .Variables.system_name }}'.
+	Variables variables.Variables
+	// Menu contains the PxeConfig of every profile that is offered in the boot menu of the system, starting with the default one.
+	// It is empty if the system has no menu profiles.
+	Menu []PxeConfig
+	// BootMode is the boot mode that is used; if it is not BootModeProfile, the profile and kernel parameters are empty.
+	BootMode BootMode
+	// InstallToken is the token that the system can use once to report that its installation has completed.
+	// It is only set when the config is actually served to the system, so it is empty in e.g. previews.
+	InstallToken string
+	// InstallCompleteUrl is the URL that the system should send a POST request to when its installation has completed.
+	InstallCompleteUrl string
 }
 
-func NewPxeConfig(kernel string, initrd string, kernelParameters kernelparameters.KernelParameters) PxeConfig {
+// NewPxeConfig returns the PxeConfig for booting the system with the passed profile.
+func NewPxeConfig(s System, p profile.Profile, kernelParameters kernelparameters.EffectiveKernelParameters) PxeConfig {
 	return PxeConfig{
-		Kernel:           kernel,
-		Initrd:           initrd,
+		System:           s,
+		Profile:          p,
 		KernelParameters: kernelParameters,
+		BootMode:         BootModeProfile,
 	}
 }
 
-func (p PxeConfig) Render() string {
-	kp := p.KernelParameters.String()
-	return fmt.Sprintf(template, p.Kernel, kp, p.Initrd)
+// WithInstallToken returns a copy of the PxeConfig, including all entries in its menu, with the passed install token and callback URL.
+func (c PxeConfig) WithInstallToken(token string, url string) PxeConfig {
+	c.InstallToken = token
+	c.InstallCompleteUrl = url
+
+	if len(c.Menu) > 0 {
+		menu := make([]PxeConfig, 0, len(c.Menu))
+		for _, m := range c.Menu {
+			menu = append(menu, m.WithInstallToken(token, url))
+		}
+		c.Menu = menu
+	}
+
+	return c
+}
+
+// NetworkKernelParameters generates the network kernel parameters of the system in the passed profile.NetworkStyle,
+// e.g. '{{ .NetworkKernelParameters "dracut" }}'. This allows templates to use them even if the profile does not set a network style.
+func (c PxeConfig) NetworkKernelParameters(style string) (kernelparameters.KernelParameters, error) {
+	n, err := profile.ParseNetworkStyle(style)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.System.Identifiers.Interfaces.NetworkKernelParameters(n), nil
+}
+
+// ConfigUrl returns the URL that the system can fetch the config template with the passed name from while booting the profile,
+// e.g. 'inst.ks={{ .ConfigUrl "ks.cfg" }}'. Systems that are not registered are identified by their MAC address instead of their ID.
+// The install token of the boot is passed along, so config templates can use the same token as the iPXE script.
+func (c PxeConfig) ConfigUrl(name string) string {
+	q := url.Values{}
+	q.Set("profile", c.Profile.Id.String())
+	if c.InstallToken != "" {
+		q.Set("token", c.InstallToken)
+	}
+
+	if c.System.Id == uuid.Nil {
+		q.Set("mac", c.System.Mac().String())
+		return c.Variables[variables.ServerUrl] + "/api/config/" + url.PathEscape(name) + "?" + q.Encode()
+	}
+
+	return c.Variables[variables.ServerUrl] + "/api/systems/" + c.System.Id.String() + "/config/" + url.PathEscape(name) + "?" + q.Encode()
+}
+
+// Renderer renders a PxeConfig into an iPXE script that can be served to clients.
+// It also renders the config templates of the profile, e.g. kickstart files, using the same PxeConfig.
+type Renderer interface {
+	Render(c PxeConfig) (string, error)
+	RenderConfig(c PxeConfig, t configtemplate.ConfigTemplate) (string, error)
+}
+
+// TemplateRenderer is a Renderer that uses text/template to render iPXE scripts.
+// If the profile in the PxeConfig has a template, that one is used, otherwise the default template is used.
+// If the PxeConfig has a menu, a boot menu containing the script of every profile in it is rendered instead.
+type TemplateRenderer struct {
+	defaultTemplate *template.Template
+	menuTemplate    *template.Template
+}
+
+func NewTemplateRenderer() TemplateRenderer {
+	return TemplateRenderer{
+		defaultTemplate: template.Must(template.New("default").Parse(defaultTemplate)),
+		menuTemplate:    template.Must(template.New("menu").Parse(menuTemplate)),
+	}
+}
+
+func (t TemplateRenderer) Render(c PxeConfig) (string, error) {
+	if script, ok := bootModeScripts[c.BootMode]; ok {
+		return script, nil
+	}
+
+	if len(c.Menu) > 0 {
+		return t.renderMenu(c)
+	}
+
+	return t.renderProfile(c)
+}
+
+// menuEntry is a single item in the boot menu, together with the script that is run when it is chosen.
+type menuEntry struct {
+	Label   string
+	Profile profile.Profile
+	Script  string
+}
+
+// renderMenu renders the boot menu of the PxeConfig, the first entry of the menu is booted by default.
+func (t TemplateRenderer) renderMenu(c PxeConfig) (string, error) {
+	entries := make([]menuEntry, 0, len(c.Menu))
+	for i, m := range c.Menu {
+		script, err := t.renderProfile(m)
+		if err != nil {
+			return "", fmt.Errorf("failed to render menu entry %s: %w", m.Profile.Name, err)
+		}
+
+		entries = append(entries, menuEntry{
+			Label:   fmt.Sprintf("profile%d", i),
+			Profile: m.Profile,
+			Script:  strings.TrimSpace(strings.TrimPrefix(script, "#!ipxe")),
+		})
+	}
+
+	var b strings.Builder
+	err := t.menuTemplate.Execute(&b, struct {
+		System  System
+		Entries []menuEntry
+		Default string
+		Timeout uint
+	}{
+		System:  c.System,
+		Entries: entries,
+		Default: entries[0].Label,
+		// iPXE expects the timeout in milliseconds
+		Timeout: c.System.MenuTimeout * 1000,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// renderProfile renders the script of the profile in the PxeConfig, using its own template if it has one.
+func (t TemplateRenderer) renderProfile(c PxeConfig) (string, error) {
+	var err error
+
+	tmpl := t.defaultTemplate
+	if c.Profile.Template != "" {
+		tmpl, err = template.New(c.Profile.Name).Option("missingkey=error").Parse(c.Profile.Template)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, c)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// RenderConfig renders the config template with the PxeConfig, which gives it access to the same data as the iPXE script.
+func (t TemplateRenderer) RenderConfig(c PxeConfig, ct configtemplate.ConfigTemplate) (string, error) {
+	tmpl, err := template.New(ct.Name).Option("missingkey=error").Parse(ct.Content)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	err = tmpl.Execute(&b, c)
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
 }
 
 func RenderNotFound() string {
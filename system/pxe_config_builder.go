@@ -0,0 +1,120 @@
+package system
+
+import (
+	"fmt"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+)
+
+// PxeConfigBuilder builds the PxeConfig for a system, using the profile that is assigned to it.
+type PxeConfigBuilder struct {
+	profileRepo profile.Repository
+}
+
+func NewPxeConfigBuilder(pr profile.Repository) PxeConfigBuilder {
+	return PxeConfigBuilder{pr}
+}
+
+// Build looks up the effective profile that is assigned to the system, and merges their kernel parameters into a PxeConfig.
+// The variables in the kernel, initrds and kernel parameters are expanded using the system, its profile and the passed BootRequest.
+// If the system has menu profiles, the PxeConfig of the default profile and every menu profile are added to the menu.
+// A pending NextBoot takes precedence over the boot mode, the profile and the menu of the system.
+func (b PxeConfigBuilder) Build(s System, req BootRequest) (PxeConfig, error) {
+	if s.NextBoot != nil {
+		return b.build(s, s.BootProfile(), req)
+	}
+
+	// Systems that do not boot a profile, e.g. the ones that boot from their local disk, do not need to look it up
+	if s.BootMode != BootModeProfile && s.BootMode != "" {
+		return PxeConfig{System: s, BootMode: s.BootMode}, nil
+	}
+
+	c, err := b.build(s, s.Profile, req)
+	if err != nil {
+		return c, err
+	}
+
+	if !s.HasMenu() {
+		return c, nil
+	}
+
+	c.Menu = append(c.Menu, c)
+	for _, id := range s.MenuProfiles {
+		m, err := b.build(s, id, req)
+		if err != nil {
+			return c, err
+		}
+
+		c.Menu = append(c.Menu, m)
+	}
+
+	return c, nil
+}
+
+// BuildForProfile builds the PxeConfig for booting the system with the passed profile, regardless of the boot mode and menu of the system.
+// This is used to render config templates, which are fetched by installers after the system has already booted a profile.
+// The kernel parameters of a pending NextBoot are only included if the passed profile is the one that the NextBoot boots.
+func (b PxeConfigBuilder) BuildForProfile(s System, profileId uuid.UUID, req BootRequest) (PxeConfig, error) {
+	return b.build(s, profileId, req)
+}
+
+// build builds the PxeConfig for booting the system with the passed profile.
+func (b PxeConfigBuilder) build(s System, profileId uuid.UUID, req BootRequest) (PxeConfig, error) {
+	var c PxeConfig
+
+	// The kernel parameters of a pending NextBoot are only served together with the profile it boots
+	if s.NextBoot != nil && profileId != s.BootProfile() {
+		s.NextBoot = nil
+	}
+
+	p, err := b.profileRepo.GetProfileById(profileId)
+	if err != nil {
+		return c, err
+	}
+
+	// Fill in everything the profile inherits from its parents
+	p, err = profile.Resolve(b.profileRepo, p)
+	if err != nil {
+		return c, err
+	}
+
+	// The system's kernel parameters take precedence over the profile's, and negated ones (e.g. '!quiet') remove them
+	kp := s.EffectiveKernelParameters(p)
+
+	// Variables are expanded after merging, so parameters can be overridden or removed regardless of their values
+	v := s.Variables(p, req)
+	p, kp, err = expandVariables(p, kp, v)
+	if err != nil {
+		return c, fmt.Errorf("profile %s: %w", p.Name, err)
+	}
+
+	c = NewPxeConfig(s, p, kp)
+	c.Variables = v
+	return c, nil
+}
+
+// expandVariables returns copies of the profile and kernel parameters, with the variables in the kernel, the paths of the initrds
+// and the values of the kernel parameters replaced by their values.
+func expandVariables(p profile.Profile, kp kernelparameters.EffectiveKernelParameters, v variables.Variables) (profile.Profile, kernelparameters.EffectiveKernelParameters, error) {
+	var err error
+
+	p.Kernel, err = variables.Expand(p.Kernel, v)
+	if err != nil {
+		return p, kp, fmt.Errorf("kernel: %w", err)
+	}
+
+	initrds := make(profile.Initrds, 0, len(p.Initrds))
+	for _, i := range p.Initrds {
+		i.Path, err = variables.Expand(i.Path, v)
+		if err != nil {
+			return p, kp, fmt.Errorf("initrd: %w", err)
+		}
+		initrds = append(initrds, i)
+	}
+	p.Initrds = initrds
+
+	kp, err = kp.Expand(v)
+	return p, kp, err
+}
@@ -0,0 +1,240 @@
+package system
+
+import (
+	"errors"
+	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/repository"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+	"net"
+	"reflect"
+	"testing"
+)
+
+// profileRepository is a simple in-memory profile.Repository for testing.
+type profileRepository map[uuid.UUID]profile.Profile
+
+func (r profileRepository) GetProfiles() ([]profile.Profile, error) {
+	var profiles []profile.Profile
+	for _, p := range r {
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+func (r profileRepository) GetProfileById(id uuid.UUID) (profile.Profile, error) {
+	p, ok := r[id]
+	if !ok {
+		return p, repository.ErrNotFound
+	}
+	return p, nil
+}
+
+func (r profileRepository) SetProfile(p profile.Profile) error {
+	r[p.Id] = p
+	return nil
+}
+
+func (r profileRepository) DeleteProfileById(id uuid.UUID) error {
+	delete(r, id)
+	return nil
+}
+
+func TestPxeConfigBuilder_Build(t *testing.T) {
+	p := profile.Profile{
+		Id:               uuid.New(),
+		Name:             "TestProfile",
+		Kernel:           "kernel",
+		Initrds:          profile.Initrds{{Path: "initrd"}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "quiet"}, {Key: "splash"}},
+	}
+
+	s := System{
+		Id:               uuid.New(),
+		Name:             "TestSystem",
+		Profile:          p.Id,
+		KernelParameters: kernelparameters.KernelParameters{{Key: "splash", Negated: true}},
+	}
+
+	expected := PxeConfig{
+		System:  s,
+		Profile: p,
+		KernelParameters: kernelparameters.EffectiveKernelParameters{
+			{KernelParameter: kernelparameters.KernelParameter{Key: "quiet"}, Source: "profile:TestProfile"},
+		},
+		Variables: s.Variables(p, BootRequest{}),
+		BootMode:  BootModeProfile,
+	}
+
+	b := NewPxeConfigBuilder(profileRepository{p.Id: p})
+	actual, err := b.Build(s, BootRequest{})
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestPxeConfigBuilder_BuildUnknownProfile(t *testing.T) {
+	b := NewPxeConfigBuilder(profileRepository{})
+	actual, err := b.Build(System{Profile: uuid.New()}, BootRequest{})
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf(`Expected PxeConfigBuilder.Build() to return not found error, got: %v, %v`, actual, err)
+	}
+}
+
+func TestPxeConfigBuilder_BuildMenu(t *testing.T) {
+	install := profile.Profile{Id: uuid.New(), Name: "install", Kernel: "kernel", Initrds: profile.Initrds{{Path: "initrd"}}}
+	rescue := profile.Profile{Id: uuid.New(), Name: "rescue", Kernel: "rescue-kernel", Initrds: profile.Initrds{{Path: "rescue-initrd"}}}
+
+	s := System{
+		Id:           uuid.New(),
+		Name:         "TestSystem",
+		Profile:      install.Id,
+		MenuProfiles: []uuid.UUID{rescue.Id},
+	}
+
+	b := NewPxeConfigBuilder(profileRepository{install.Id: install, rescue.Id: rescue})
+	actual, err := b.Build(s, BootRequest{})
+	if err != nil {
+		t.Fatalf(`PxeConfigBuilder.Build() returned error: %v`, err)
+	}
+
+	if actual.Profile.Id != install.Id {
+		t.Fatalf(`PxeConfigBuilder.Build() used profile %v, expected default profile %v`, actual.Profile.Id, install.Id)
+	}
+
+	if len(actual.Menu) != 2 || actual.Menu[0].Profile.Id != install.Id || actual.Menu[1].Profile.Id != rescue.Id {
+		t.Fatalf(`PxeConfigBuilder.Build() returned menu %v, expected entries for %v and %v`, actual.Menu, install.Id, rescue.Id)
+	}
+}
+
+func TestPxeConfigBuilder_BuildNextBoot(t *testing.T) {
+	regular := profile.Profile{Id: uuid.New(), Name: "regular", Kernel: "kernel", Initrds: profile.Initrds{{Path: "initrd"}}}
+	installer := profile.Profile{Id: uuid.New(), Name: "installer", Kernel: "installer-kernel", Initrds: profile.Initrds{{Path: "installer-initrd"}}}
+
+	s := System{
+		Id:           uuid.New(),
+		Name:         "TestSystem",
+		Profile:      regular.Id,
+		MenuProfiles: []uuid.UUID{installer.Id},
+		NextBoot:     &NextBoot{Profile: installer.Id},
+	}
+
+	b := NewPxeConfigBuilder(profileRepository{regular.Id: regular, installer.Id: installer})
+	actual, err := b.Build(s, BootRequest{})
+	if err != nil || actual.Profile.Id != installer.Id || len(actual.Menu) != 0 {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, %v, expected config for profile %v without menu`, actual, err, installer.Id)
+	}
+
+	// Without a profile, the next boot uses the regular profile of the system
+	s.NextBoot = &NextBoot{KernelParameters: kernelparameters.KernelParameters{{Key: "rd.break"}}}
+	actual, err = b.Build(s, BootRequest{})
+	if err != nil || actual.Profile.Id != regular.Id {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, %v, expected config for profile %v`, actual, err, regular.Id)
+	}
+
+	// The kernel parameters of the next boot are only included for the profile that it boots
+	s.NextBoot = &NextBoot{Profile: installer.Id, KernelParameters: kernelparameters.KernelParameters{{Key: "rd.break"}}}
+	actual, err = b.BuildForProfile(s, regular.Id, BootRequest{})
+	if err != nil || actual.KernelParameters.String() != "" {
+		t.Fatalf(`PxeConfigBuilder.BuildForProfile() = %v, %v, expected config without kernel parameters`, actual, err)
+	}
+
+	actual, err = b.BuildForProfile(s, installer.Id, BootRequest{})
+	if err != nil || actual.KernelParameters.String() != "rd.break" {
+		t.Fatalf(`PxeConfigBuilder.BuildForProfile() = %v, %v, expected config with kernel parameters rd.break`, actual, err)
+	}
+}
+
+func TestPxeConfigBuilder_BuildLocalDisk(t *testing.T) {
+	p := profile.Profile{Id: uuid.New(), Name: "installer", Kernel: "kernel", Initrds: profile.Initrds{{Path: "initrd"}}}
+	s := System{Id: uuid.New(), Name: "TestSystem", Profile: p.Id, BootMode: BootModeLocalDisk}
+
+	b := NewPxeConfigBuilder(profileRepository{p.Id: p})
+	actual, err := b.Build(s, BootRequest{})
+	if err != nil || actual.BootMode != BootModeLocalDisk {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, %v, expected config with boot mode %v`, actual, err, BootModeLocalDisk)
+	}
+
+	// A pending next boot still boots a profile, e.g. to reinstall the system
+	s.NextBoot = &NextBoot{Profile: p.Id}
+	actual, err = b.Build(s, BootRequest{})
+	if err != nil || actual.BootMode != BootModeProfile || actual.Profile.Id != p.Id {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, %v, expected config for profile %v`, actual, err, p.Id)
+	}
+}
+
+func TestPxeConfigBuilder_BuildInheritedProfile(t *testing.T) {
+	parent := profile.Profile{
+		Id:               uuid.New(),
+		Name:             "parent",
+		Kernel:           "kernel",
+		Initrds:          profile.Initrds{{Path: "initrd"}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "quiet"}},
+	}
+	child := profile.Profile{
+		Id:               uuid.New(),
+		Name:             "child",
+		KernelParameters: kernelparameters.KernelParameters{{Key: "splash"}},
+		Parent:           parent.Id,
+	}
+
+	s := System{Id: uuid.New(), Name: "TestSystem", Profile: child.Id}
+
+	b := NewPxeConfigBuilder(profileRepository{parent.Id: parent, child.Id: child})
+	actual, err := b.Build(s, BootRequest{})
+	if err != nil {
+		t.Fatalf(`PxeConfigBuilder.Build() returned error: %v`, err)
+	}
+
+	if actual.Profile.Kernel != "kernel" || actual.Profile.Initrd() != "initrd" || actual.KernelParameters.String() != "quiet splash" {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, expected the kernel, initrd and kernel parameters to be inherited`, actual)
+	}
+}
+
+func TestPxeConfigBuilder_BuildExpandsVariables(t *testing.T) {
+	mac, _ := net.ParseMAC("11:22:33:44:55:66")
+
+	p := profile.Profile{
+		Id:               uuid.New(),
+		Name:             "fedora",
+		Kernel:           "http://${server}/images/${profile}/vmlinuz",
+		Initrds:          profile.Initrds{{Path: "http://${server}/images/${profile}/initrd.img"}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "inst.ks", Value: "${server_url}/ks/${system_name}-${os_version}.cfg"}},
+		Metadata:         variables.Metadata{"os_version": "39"},
+	}
+
+	s := System{
+		Id:               uuid.New(),
+		Name:             "web01",
+		Profile:          p.Id,
+		Identifiers:      Identifiers{Interfaces: Interfaces{{Mac: mac}}},
+		KernelParameters: kernelparameters.KernelParameters{{Key: "BOOTIF", Value: "${mac_pxelinux}"}},
+		Metadata:         variables.Metadata{"os_version": "40"},
+	}
+
+	b := NewPxeConfigBuilder(profileRepository{p.Id: p})
+	actual, err := b.Build(s, BootRequest{ClientIp: net.ParseIP("192.168.1.10"), ServerUrl: "http://gobble.example.local:8080"})
+	if err != nil {
+		t.Fatalf(`PxeConfigBuilder.Build() returned error: %v`, err)
+	}
+
+	if actual.Profile.Kernel != "http://gobble.example.local:8080/images/fedora/vmlinuz" ||
+		actual.Profile.Initrd() != "http://gobble.example.local:8080/images/fedora/initrd.img" ||
+		actual.KernelParameters.String() != "inst.ks=http://gobble.example.local:8080/ks/web01-40.cfg BOOTIF=01-11-22-33-44-55-66" {
+		t.Fatalf(`PxeConfigBuilder.Build() = %v, expected the variables to be expanded`, actual)
+	}
+}
+
+func TestPxeConfigBuilder_BuildUnknownVariable(t *testing.T) {
+	p := profile.Profile{Id: uuid.New(), Name: "fedora", Kernel: "http://${server}/images/${release}/vmlinuz"}
+	s := System{Id: uuid.New(), Name: "web01", Profile: p.Id}
+
+	b := NewPxeConfigBuilder(profileRepository{p.Id: p})
+	actual, err := b.Build(s, BootRequest{})
+
+	var unknown variables.UnknownVariableError
+	if !errors.As(err, &unknown) || unknown.Name != "release" {
+		t.Fatalf(`Expected PxeConfigBuilder.Build() to return unknown variable error, got: %v, %v`, actual, err)
+	}
+}
@@ -1,7 +1,12 @@
 package system
 
 import (
+	"github.com/evanebb/gobble/configtemplate"
 	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+	"net"
 	"testing"
 )
 
@@ -19,9 +24,223 @@ boot
 		t.Fatalf(`NewPxeConfig(): failed to instantiate KernelParameters, error: %v`, err)
 	}
 
-	pxeConfig := NewPxeConfig("testkernel", "testinitrd", kp)
-	actual := pxeConfig.Render()
-	if actual != expected {
-		t.Fatalf("PxeConfig.Render() = %v, expected %v", actual, expected)
+	p := profile.Profile{Kernel: "testkernel", Initrds: profile.Initrds{{Path: "testinitrd"}}}
+
+	pxeConfig := NewPxeConfig(System{}, p, kernelparameters.Merge(kernelparameters.NewSource("profile", kp)))
+	actual, err := NewTemplateRenderer().Render(pxeConfig)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.Render() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderPxeConfigMultipleInitrds(t *testing.T) {
+	expected := `#!ipxe
+
+kernel wimboot gui
+initrd --name BCD http://example.com/BCD
+initrd --name boot.sdi http://example.com/boot.sdi
+initrd --name boot.wim http://example.com/boot.wim
+
+boot
+`
+
+	initrds, err := profile.ParseInitrds("http://example.com/BCD name=BCD\nhttp://example.com/boot.sdi name=boot.sdi\nhttp://example.com/boot.wim name=boot.wim")
+	if err != nil {
+		t.Fatalf(`NewPxeConfig(): failed to parse initrds, error: %v`, err)
+	}
+
+	kp, err := kernelparameters.ParseStringSlice([]string{"gui"})
+	if err != nil {
+		t.Fatalf(`NewPxeConfig(): failed to instantiate KernelParameters, error: %v`, err)
+	}
+
+	p := profile.Profile{Kernel: "wimboot", Initrds: initrds}
+
+	pxeConfig := NewPxeConfig(System{}, p, kernelparameters.Merge(kernelparameters.NewSource("profile", kp)))
+	actual, err := NewTemplateRenderer().Render(pxeConfig)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.Render() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderPxeConfigProfileTemplate(t *testing.T) {
+	expected := `#!ipxe
+echo Booting TestSystem (11:22:33:44:55:66) with TestProfile
+imgverify testkernel testkernel.sig
+kernel testkernel param1
+boot
+`
+
+	kp, err := kernelparameters.ParseStringSlice([]string{"param1"})
+	if err != nil {
+		t.Fatalf(`NewPxeConfig(): failed to instantiate KernelParameters, error: %v`, err)
+	}
+
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`NewPxeConfig(): failed to parse MAC address`)
+	}
+
+	tmpl := `#!ipxe
+echo Booting {{ .System.Name }} ({{ .System.Mac }}) with {{ .Profile.Name }}
+imgverify {{ .Profile.Kernel }} {{ .Profile.Kernel }}.sig
+kernel {{ .Profile.Kernel }} {{ .KernelParameters }}
+boot
+`
+
+	p, err := profile.New(uuid.Nil, "TestProfile", "", "testkernel", profile.Initrds{{Path: "testinitrd"}}, kp, tmpl, profile.NetworkStyleNone, uuid.Nil, nil, nil)
+	if err != nil {
+		t.Fatalf(`NewPxeConfig(): failed to instantiate Profile, error: %v`, err)
+	}
+
+	s, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, Identifiers{Interfaces: Interfaces{{Mac: mac}}}, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
+	if err != nil {
+		t.Fatalf(`NewPxeConfig(): failed to instantiate System, error: %v`, err)
+	}
+
+	pxeConfig := NewPxeConfig(s, p, s.EffectiveKernelParameters(p))
+	actual, err := NewTemplateRenderer().Render(pxeConfig)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.Render() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderPxeConfigProfileTemplateUnknownField(t *testing.T) {
+	p := profile.Profile{Name: "TestProfile", Kernel: "testkernel", Initrds: profile.Initrds{{Path: "testinitrd"}}, Template: "{{ .Unknown }}"}
+
+	actual, err := NewTemplateRenderer().Render(NewPxeConfig(System{}, p, kernelparameters.EffectiveKernelParameters{}))
+	if err == nil {
+		t.Fatalf("Expected TemplateRenderer.Render() to return an error, got: %v, %v", actual, err)
+	}
+}
+
+func TestRenderPxeConfigMenu(t *testing.T) {
+	expected := `#!ipxe
+
+menu Boot menu for TestSystem
+item profile0 install
+item profile1 memtest
+choose --timeout 10000 --default profile0 selected || exit
+goto ${selected}
+
+:profile0
+kernel install-kernel quiet
+initrd install-initrd
+
+boot
+
+:profile1
+echo Running memtest
+chain memtest.efi
+`
+
+	s := System{Name: "TestSystem", MenuTimeout: 10}
+	install := profile.Profile{Name: "install", Kernel: "install-kernel", Initrds: profile.Initrds{{Path: "install-initrd"}}}
+	memtest := profile.Profile{Name: "memtest", Template: "#!ipxe\necho Running memtest\nchain memtest.efi\n"}
+
+	kp := kernelparameters.Merge(kernelparameters.NewSource("profile", kernelparameters.KernelParameters{{Key: "quiet"}}))
+	pxeConfig := NewPxeConfig(s, install, kp)
+	pxeConfig.Menu = []PxeConfig{pxeConfig, NewPxeConfig(s, memtest, kernelparameters.EffectiveKernelParameters{})}
+
+	actual, err := NewTemplateRenderer().Render(pxeConfig)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.Render() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderPxeConfigLocalDisk(t *testing.T) {
+	expected := `#!ipxe
+
+echo Booting from local disk
+sanboot --no-describe --drive 0x80
+`
+
+	pxeConfig := PxeConfig{System: System{Name: "TestSystem"}, BootMode: BootModeLocalDisk}
+	actual, err := NewTemplateRenderer().Render(pxeConfig)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.Render() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderPxeConfigInstallToken(t *testing.T) {
+	expected := `#!ipxe
+
+menu Boot menu for TestSystem
+item profile0 install
+choose --default profile0 selected || exit
+goto ${selected}
+
+:profile0
+kernel kernel inst.ks=http://gobble/ks.cfg callback=http://gobble/api/install-complete?token=abc
+boot
+`
+
+	s := System{Name: "TestSystem"}
+	install := profile.Profile{Name: "install", Kernel: "kernel", Template: "#!ipxe\nkernel {{ .Profile.Kernel }} inst.ks=http://gobble/ks.cfg callback={{ .InstallCompleteUrl }}\nboot\n"}
+
+	pxeConfig := NewPxeConfig(s, install, kernelparameters.EffectiveKernelParameters{})
+	pxeConfig.Menu = []PxeConfig{pxeConfig}
+	pxeConfig = pxeConfig.WithInstallToken("abc", "http://gobble/api/install-complete?token=abc")
+
+	actual, err := NewTemplateRenderer().Render(pxeConfig)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.Render() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderConfig(t *testing.T) {
+	expected := "network --hostname=TestSystem\nurl --url=http://gobble/repo/40\n"
+
+	s := System{Name: "TestSystem"}
+	p := profile.Profile{Name: "install"}
+	pxeConfig := NewPxeConfig(s, p, kernelparameters.EffectiveKernelParameters{})
+	pxeConfig.Variables = variables.Variables{variables.ServerUrl: "http://gobble", "os_version": "40"}
+
+	c, err := configtemplate.New(uuid.Nil, "ks.cfg", "", configtemplate.TypeKickstart, "network --hostname={{ .System.Name }}\nurl --url={{ .Variables.server_url }}/repo/{{ .Variables.os_version }}\n")
+	if err != nil {
+		t.Fatalf("configtemplate.New(): failed to instantiate ConfigTemplate, error: %v", err)
+	}
+
+	actual, err := NewTemplateRenderer().RenderConfig(pxeConfig, c)
+	if err != nil || actual != expected {
+		t.Fatalf("TemplateRenderer.RenderConfig() = %v, %v, expected %v, nil", actual, err, expected)
+	}
+}
+
+func TestRenderConfigUnknownVariable(t *testing.T) {
+	pxeConfig := NewPxeConfig(System{}, profile.Profile{}, kernelparameters.EffectiveKernelParameters{})
+	c := configtemplate.ConfigTemplate{Name: "ks.cfg", Type: configtemplate.TypeKickstart, Content: "{{ .Variables.unknown }}"}
+
+	actual, err := NewTemplateRenderer().RenderConfig(pxeConfig, c)
+	if err == nil {
+		t.Fatalf("Expected TemplateRenderer.RenderConfig() to return an error, got: %v, %v", actual, err)
+	}
+}
+
+func TestPxeConfigConfigUrl(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+	systemId := uuid.MustParse("a1ad2f2e-0cd5-4b3a-9b8e-7c1f0b8f6b51")
+	profileId := uuid.MustParse("0e6c7f7a-6f43-4a38-8b0e-3a4a8b7d3c11")
+	v := variables.Variables{variables.ServerUrl: "http://gobble"}
+
+	registered := NewPxeConfig(System{Id: systemId}, profile.Profile{Id: profileId}, kernelparameters.EffectiveKernelParameters{})
+	registered.Variables = v
+	expected := "http://gobble/api/systems/a1ad2f2e-0cd5-4b3a-9b8e-7c1f0b8f6b51/config/ks.cfg?profile=0e6c7f7a-6f43-4a38-8b0e-3a4a8b7d3c11"
+	if actual := registered.ConfigUrl("ks.cfg"); actual != expected {
+		t.Fatalf("PxeConfig.ConfigUrl() = %v, expected %v", actual, expected)
+	}
+
+	// The install token of the boot is passed along, if there is one
+	registered = registered.WithInstallToken("abc", "http://gobble/api/install-complete?token=abc")
+	expected = "http://gobble/api/systems/a1ad2f2e-0cd5-4b3a-9b8e-7c1f0b8f6b51/config/ks.cfg?profile=0e6c7f7a-6f43-4a38-8b0e-3a4a8b7d3c11&token=abc"
+	if actual := registered.ConfigUrl("ks.cfg"); actual != expected {
+		t.Fatalf("PxeConfig.ConfigUrl() = %v, expected %v", actual, expected)
+	}
+
+	unregistered := NewPxeConfig(System{Identifiers: Identifiers{Interfaces: Interfaces{{Mac: mac}}}}, profile.Profile{Id: profileId}, kernelparameters.EffectiveKernelParameters{})
+	unregistered.Variables = v
+	expected = "http://gobble/api/config/ks.cfg?mac=52%3A54%3A00%3A12%3A34%3A56&profile=0e6c7f7a-6f43-4a38-8b0e-3a4a8b7d3c11"
+	if actual := unregistered.ConfigUrl("ks.cfg"); actual != expected {
+		t.Fatalf("PxeConfig.ConfigUrl() = %v, expected %v", actual, expected)
 	}
 }
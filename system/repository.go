@@ -3,12 +3,29 @@ package system
 import (
 	"github.com/google/uuid"
 	"net"
+	"time"
 )
 
 type Repository interface {
 	GetSystems() ([]System, error)
 	GetSystemByMacAddress(macAddress net.HardwareAddr) (System, error)
+	GetSystemBySmbiosUuid(smbiosUuid uuid.UUID) (System, error)
+	GetSystemBySerial(serial string) (System, error)
+	GetSystemByAssetTag(assetTag string) (System, error)
+	GetSystemByHostname(hostname string) (System, error)
 	GetSystemById(id uuid.UUID) (System, error)
 	SetSystem(s System) error
 	DeleteSystemById(id uuid.UUID) error
+	SetNextBoot(id uuid.UUID, n NextBoot) error
+	ClearNextBoot(id uuid.UUID) error
+	// ConsumeNextBoot atomically returns and clears the pending NextBoot of the system, so it is only served once.
+	// If there is no pending NextBoot, repository.ErrNotFound is returned.
+	ConsumeNextBoot(id uuid.UUID) (NextBoot, error)
+	// SetInstallToken stores the hash of the current install token of the system, replacing the previous one.
+	SetInstallToken(id uuid.UUID, tokenHash string) error
+	// HasInstallToken returns whether the passed hash is the hash of the current install token of the system.
+	HasInstallToken(id uuid.UUID, tokenHash string) (bool, error)
+	// CompleteInstall marks the installation of the system with the passed install token hash as completed at the passed time,
+	// sets its boot mode to BootModeLocalDisk and invalidates the token. If no system has the token, repository.ErrNotFound is returned.
+	CompleteInstall(tokenHash string, at time.Time) (uuid.UUID, error)
 }
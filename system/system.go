@@ -2,38 +2,156 @@ package system
 
 import (
 	"errors"
+	"fmt"
 	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/variables"
 	"github.com/google/uuid"
 	"net"
 	"regexp"
+	"time"
 )
 
+// MaxMenuTimeout is the maximum number of seconds that the boot menu of a system can wait before booting the default profile.
+const MaxMenuTimeout = 3600
+
 type System struct {
 	Id               uuid.UUID
 	Name             string
 	Description      string
 	Profile          uuid.UUID
-	Mac              net.HardwareAddr
+	Identifiers      Identifiers
 	KernelParameters kernelparameters.KernelParameters
+	// MenuProfiles are the profiles that are offered in an iPXE boot menu, next to the default Profile.
+	// If there are none, the system boots its Profile directly without showing a menu.
+	MenuProfiles []uuid.UUID
+	// MenuTimeout is the number of seconds after which the default Profile is booted from the menu.
+	// If it is 0, the menu waits until a profile is chosen.
+	MenuTimeout uint
+	// BootMode determines whether the system boots its profile, or e.g. its local disk.
+	BootMode BootMode
+	// InstalledAt is the last time the system reported that its installation completed, or the zero time if it never did.
+	InstalledAt time.Time
+	// NextBoot is the pending one-shot override of the boot configuration, or nil if there is none.
+	NextBoot *NextBoot
+	// Metadata is user-defined data that is available as variables when the system boots, e.g. '${rack}'.
+	// It takes precedence over the metadata of the profile.
+	Metadata variables.Metadata
 }
 
-func New(id uuid.UUID, name string, description string, profile uuid.UUID, mac net.HardwareAddr, kernelParameters kernelparameters.KernelParameters) (System, error) {
+func New(id uuid.UUID, name string, description string, profile uuid.UUID, identifiers Identifiers, kernelParameters kernelparameters.KernelParameters, menuProfiles []uuid.UUID, menuTimeout uint, bootMode BootMode, metadata variables.Metadata) (System, error) {
 	var s System
 
 	if err := validateName(name); err != nil {
 		return s, err
 	}
 
+	if err := validateIdentifiers(identifiers); err != nil {
+		return s, err
+	}
+
+	if err := validateMenuProfiles(profile, menuProfiles); err != nil {
+		return s, err
+	}
+
+	if err := validateMenuTimeout(menuTimeout); err != nil {
+		return s, err
+	}
+
+	if err := validateBootMode(bootMode); err != nil {
+		return s, err
+	}
+
+	if err := kernelParameters.ValidateVariables(); err != nil {
+		return s, err
+	}
+
+	if err := variables.ValidateMetadata(metadata); err != nil {
+		return s, err
+	}
+
 	return System{
 		Id:               id,
 		Name:             name,
 		Description:      description,
 		Profile:          profile,
-		Mac:              mac,
+		Identifiers:      identifiers,
 		KernelParameters: kernelParameters,
+		MenuProfiles:     menuProfiles,
+		MenuTimeout:      menuTimeout,
+		BootMode:         bootMode,
+		Metadata:         metadata,
 	}, nil
 }
 
+// Mac returns the MAC address of the boot interface of the system, or nil if it has no interfaces.
+// This keeps '{{ .System.Mac }}' working in templates that were written when a system only had a single MAC address.
+func (s System) Mac() net.HardwareAddr {
+	i, ok := s.Identifiers.Interfaces.BootInterface()
+	if !ok {
+		return nil
+	}
+
+	return i.Mac
+}
+
+// HasMenu returns whether the system should be shown an iPXE boot menu instead of directly booting its profile.
+func (s System) HasMenu() bool {
+	return len(s.MenuProfiles) > 0
+}
+
+// HasMenuProfile returns whether the passed profile is offered in the boot menu of the system.
+func (s System) HasMenuProfile(id uuid.UUID) bool {
+	for _, p := range s.MenuProfiles {
+		if p == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BootProfile returns the profile that the system boots right now, which is the profile of a pending NextBoot if it has one.
+func (s System) BootProfile() uuid.UUID {
+	if s.NextBoot != nil && s.NextBoot.Profile != uuid.Nil {
+		return s.NextBoot.Profile
+	}
+
+	return s.Profile
+}
+
+// BootsProfile returns whether the system can currently boot the passed profile: its own profile, one of its menu profiles,
+// or the profile of its pending NextBoot.
+func (s System) BootsProfile(id uuid.UUID) bool {
+	if id == uuid.Nil {
+		return false
+	}
+
+	if s.NextBoot != nil && s.NextBoot.Profile == id {
+		return true
+	}
+
+	return s.Profile == id || s.HasMenuProfile(id)
+}
+
+// EffectiveKernelParameters merges the kernel parameters of the passed profile with the ones of the system,
+// and keeps track of where every resulting parameter came from. The parameters of the system take precedence.
+// The network parameters that are generated for the network style of the profile are merged in between,
+// and the kernel parameters of a pending NextBoot are merged last.
+func (s System) EffectiveKernelParameters(p profile.Profile) kernelparameters.EffectiveKernelParameters {
+	sources := []kernelparameters.Source{
+		kernelparameters.NewSource("profile:"+p.Name, p.KernelParameters),
+		kernelparameters.NewSource("network", s.Identifiers.Interfaces.NetworkKernelParameters(p.NetworkStyle)),
+		kernelparameters.NewSource("system:"+s.Name, s.KernelParameters),
+	}
+
+	if s.NextBoot != nil {
+		sources = append(sources, kernelparameters.NewSource("next-boot", s.NextBoot.KernelParameters))
+	}
+
+	return kernelparameters.Merge(sources...)
+}
+
 func validateName(name string) error {
 	p := "^[a-zA-Z0-9-_.()]{1,64}$"
 	matched, err := regexp.MatchString(p, name)
@@ -47,3 +165,25 @@ func validateName(name string) error {
 
 	return nil
 }
+
+// validateMenuTimeout checks that the menu timeout is at most MaxMenuTimeout, since iPXE expects it in milliseconds.
+func validateMenuTimeout(timeout uint) error {
+	if timeout > MaxMenuTimeout {
+		return fmt.Errorf("menu timeout can be at most %d seconds", MaxMenuTimeout)
+	}
+
+	return nil
+}
+
+// validateMenuProfiles checks that every menu profile only occurs once, and that the default profile is not repeated in the menu profiles.
+func validateMenuProfiles(profile uuid.UUID, menuProfiles []uuid.UUID) error {
+	seen := map[uuid.UUID]bool{profile: true}
+	for _, p := range menuProfiles {
+		if seen[p] {
+			return errors.New("menu profile " + p.String() + " is assigned more than once")
+		}
+		seen[p] = true
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package system
 
 import (
 	"github.com/evanebb/gobble/kernelparameters"
+	"github.com/evanebb/gobble/profile"
 	"github.com/google/uuid"
 	"net"
 	"reflect"
@@ -19,11 +20,12 @@ func TestNewProfile(t *testing.T) {
 		Name:             "TestSystem",
 		Description:      "",
 		Profile:          uuid.Nil,
-		Mac:              mac,
+		Identifiers:      Identifiers{Interfaces: Interfaces{{Mac: mac}}},
 		KernelParameters: kernelparameters.KernelParameters{},
+		BootMode:         BootModeProfile,
 	}
 
-	actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, mac, kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, Identifiers{Interfaces: Interfaces{{Mac: mac}}}, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
 	if err != nil || !reflect.DeepEqual(actual, expected) {
 		t.Fatalf(`New() = %v, %v, expected: %v, nil`, actual, err, expected)
 	}
@@ -35,8 +37,115 @@ func TestNewProfileInvalidName(t *testing.T) {
 		t.Fatalf(`New(): failed to parse MAC address`)
 	}
 
-	actual, err := New(uuid.Nil, "invalid name", "", uuid.Nil, mac, kernelparameters.KernelParameters{})
+	actual, err := New(uuid.Nil, "invalid name", "", uuid.Nil, Identifiers{Interfaces: Interfaces{{Mac: mac}}}, kernelparameters.KernelParameters{}, nil, 0, BootModeProfile, nil)
 	if err == nil {
 		t.Fatalf(`Expected New() to return invalid name error, got: %v, %v`, actual, err)
 	}
 }
+
+func TestNewSystemDuplicateMenuProfile(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`New(): failed to parse MAC address`)
+	}
+
+	defaultProfile := uuid.New()
+	menuProfile := uuid.New()
+
+	for _, menuProfiles := range [][]uuid.UUID{{menuProfile, menuProfile}, {menuProfile, defaultProfile}} {
+		actual, err := New(uuid.Nil, "TestSystem", "", defaultProfile, Identifiers{Interfaces: Interfaces{{Mac: mac}}}, kernelparameters.KernelParameters{}, menuProfiles, 0, BootModeProfile, nil)
+		if err == nil {
+			t.Fatalf(`Expected New() to return duplicate menu profile error, got: %v, %v`, actual, err)
+		}
+	}
+}
+
+func TestNewSystemInvalidBootMode(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`New(): failed to parse MAC address`)
+	}
+
+	actual, err := New(uuid.Nil, "TestSystem", "", uuid.Nil, Identifiers{Interfaces: Interfaces{{Mac: mac}}}, kernelparameters.KernelParameters{}, nil, 0, "floppy", nil)
+	if err == nil {
+		t.Fatalf(`Expected New() to return invalid boot mode error, got: %v, %v`, actual, err)
+	}
+}
+
+func TestSystem_EffectiveKernelParameters(t *testing.T) {
+	p := profile.Profile{
+		Name: "TestProfile",
+		KernelParameters: kernelparameters.KernelParameters{
+			{Key: "quiet"},
+			{Key: "console", Value: "tty0"},
+		},
+	}
+
+	profileKp := kernelparameters.KernelParameters{
+		{Key: "quiet"},
+		{Key: "console", Value: "tty0"},
+	}
+
+	s := System{
+		Name: "TestSystem",
+		KernelParameters: kernelparameters.KernelParameters{
+			{Key: "quiet", Negated: true},
+			{Key: "console", Value: "ttyS0"},
+		},
+	}
+
+	expected := kernelparameters.EffectiveKernelParameters{
+		{KernelParameter: kernelparameters.KernelParameter{Key: "console", Value: "ttyS0"}, Source: "system:TestSystem"},
+	}
+
+	actual := s.EffectiveKernelParameters(p)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`System.EffectiveKernelParameters() = %v, expected: %v`, actual, expected)
+	}
+
+	if !reflect.DeepEqual(p.KernelParameters, profileKp) {
+		t.Fatalf(`System.EffectiveKernelParameters() modified the profile: %v, expected: %v`, p.KernelParameters, profileKp)
+	}
+}
+
+func TestSystem_EffectiveKernelParametersNextBoot(t *testing.T) {
+	p := profile.Profile{
+		Name:             "TestProfile",
+		KernelParameters: kernelparameters.KernelParameters{{Key: "quiet"}},
+	}
+
+	s := System{
+		Name:             "TestSystem",
+		KernelParameters: kernelparameters.KernelParameters{{Key: "console", Value: "ttyS0"}},
+		NextBoot: &NextBoot{
+			KernelParameters: kernelparameters.KernelParameters{{Key: "quiet", Negated: true}, {Key: "rd.break"}},
+		},
+	}
+
+	expected := kernelparameters.EffectiveKernelParameters{
+		{KernelParameter: kernelparameters.KernelParameter{Key: "console", Value: "ttyS0"}, Source: "system:TestSystem"},
+		{KernelParameter: kernelparameters.KernelParameter{Key: "rd.break"}, Source: "next-boot"},
+	}
+
+	actual := s.EffectiveKernelParameters(p)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`System.EffectiveKernelParameters() = %v, expected: %v`, actual, expected)
+	}
+}
+
+func TestNewSystemMenuTimeout(t *testing.T) {
+	mac, err := net.ParseMAC("11:22:33:44:55:66")
+	if err != nil {
+		t.Fatalf(`New(): failed to parse MAC address`)
+	}
+
+	i := Identifiers{Interfaces: Interfaces{{Mac: mac}}}
+	if _, err := New(uuid.Nil, "TestSystem", "", uuid.New(), i, kernelparameters.KernelParameters{}, []uuid.UUID{uuid.New()}, MaxMenuTimeout, BootModeProfile, nil); err != nil {
+		t.Fatalf(`New() with the maximum menu timeout returned error: %v`, err)
+	}
+
+	actual, err := New(uuid.Nil, "TestSystem", "", uuid.New(), i, kernelparameters.KernelParameters{}, []uuid.UUID{uuid.New()}, MaxMenuTimeout+1, BootModeProfile, nil)
+	if err == nil {
+		t.Fatalf(`New() = %v, %v, expected: System{}, error`, actual, err)
+	}
+}
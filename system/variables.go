@@ -0,0 +1,60 @@
+package system
+
+import (
+	"github.com/evanebb/gobble/profile"
+	"github.com/evanebb/gobble/variables"
+	"github.com/google/uuid"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// BootRequest contains the information about the request of a booting system that is available as variables.
+type BootRequest struct {
+	// ClientIp is the IP address that the request came from, or nil if it is unknown, e.g. when previewing a PxeConfig.
+	ClientIp net.IP
+	// ServerUrl is the URL that the system can reach this application on, e.g. 'http://gobble.example.local'.
+	ServerUrl string
+}
+
+// Variables returns the variables that are available when the system boots the passed profile for the passed request.
+// The metadata of the system takes precedence over the metadata of the profile, and the built-in variables can not be overridden.
+func (s System) Variables(p profile.Profile, req BootRequest) variables.Variables {
+	v := make(variables.Variables)
+	for k, value := range p.Metadata.Merge(s.Metadata) {
+		v[k] = value
+	}
+
+	// Unregistered systems that boot the default profile do not have an ID or a name
+	v[variables.SystemId] = ""
+	if s.Id != uuid.Nil {
+		v[variables.SystemId] = s.Id.String()
+	}
+	v[variables.SystemName] = s.Name
+
+	mac := s.Mac().String()
+	v[variables.Mac] = mac
+	v[variables.MacHyphen] = strings.ReplaceAll(mac, ":", "-")
+	v[variables.MacPlain] = strings.ReplaceAll(mac, ":", "")
+	v[variables.MacPxelinux] = ""
+	if mac != "" {
+		// pxelinux prefixes the MAC address with the ARP hardware type, which is 01 for Ethernet
+		v[variables.MacPxelinux] = "01-" + v[variables.MacHyphen]
+	}
+
+	v[variables.Profile] = p.Name
+	v[variables.ProfileId] = p.Id.String()
+
+	v[variables.ClientIp] = ""
+	if req.ClientIp != nil {
+		v[variables.ClientIp] = req.ClientIp.String()
+	}
+
+	v[variables.ServerUrl] = strings.TrimSuffix(req.ServerUrl, "/")
+	v[variables.Server] = ""
+	if u, err := url.Parse(req.ServerUrl); err == nil {
+		v[variables.Server] = u.Host
+	}
+
+	return v
+}
@@ -0,0 +1,89 @@
+package variables
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Metadata is user-defined key/value data of a system or profile. Every key is available as a variable.
+type Metadata map[string]string
+
+// ParseMetadata parses metadata from a string containing one 'key=value' pair per line. Empty lines are ignored.
+func ParseMetadata(s string) (Metadata, error) {
+	m := make(Metadata)
+
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return m, fmt.Errorf("invalid metadata '%s': expected key=value", line)
+		}
+
+		key = strings.TrimSpace(key)
+		if _, exists := m[key]; exists {
+			return m, errors.New("metadata key " + key + " is used more than once")
+		}
+		m[key] = strings.TrimSpace(value)
+	}
+
+	return m, nil
+}
+
+// Keys returns the keys of the metadata in alphabetical order.
+func (m Metadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns the metadata in the format accepted by ParseMetadata, sorted by key.
+func (m Metadata) String() string {
+	lines := make([]string, 0, len(m))
+	for _, k := range m.Keys() {
+		lines = append(lines, k+"="+m[k])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Merge returns new metadata containing the keys of m and other, where the values of other take precedence.
+func (m Metadata) Merge(other Metadata) Metadata {
+	merged := make(Metadata, len(m)+len(other))
+	for k, v := range m {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
+// ValidateMetadata returns an error if a key is not a valid variable name or is the name of a built-in variable,
+// or if a value contains characters that cannot be passed on the kernel command line.
+func ValidateMetadata(m Metadata) error {
+	for k, v := range m {
+		if !namePattern.MatchString(k) {
+			return errors.New("invalid metadata key " + k)
+		}
+
+		for _, b := range Builtin {
+			if k == b {
+				return errors.New("metadata key " + k + " is the name of a built-in variable")
+			}
+		}
+
+		if strings.ContainsAny(v, "\"\n") {
+			return errors.New("value of metadata key " + k + " contains illegal characters")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package variables
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	expected := Metadata{"rack": "A12", "os_version": "9.3", "note": "a=b"}
+
+	actual, err := ParseMetadata("rack=A12\n\n os_version = 9.3\nnote=a=b\n")
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`ParseMetadata() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestParseMetadataInvalid(t *testing.T) {
+	for _, s := range []string{"rack", "rack=A12\nrack=B3"} {
+		actual, err := ParseMetadata(s)
+		if err == nil {
+			t.Fatalf(`ParseMetadata(%q) = %v, %v, expected: Metadata{}, error`, s, actual, err)
+		}
+	}
+}
+
+func TestMetadataString(t *testing.T) {
+	expected := "os_version=9.3\nrack=A12"
+
+	m, err := ParseMetadata("rack=A12\nos_version=9.3")
+	if err != nil {
+		t.Fatalf(`String(): failed to parse metadata`)
+	}
+
+	actual := m.String()
+	if actual != expected {
+		t.Fatalf(`String() = %q, expected: %q`, actual, expected)
+	}
+}
+
+func TestValidateMetadataInvalid(t *testing.T) {
+	for _, m := range []Metadata{{"rack-1": "A12"}, {"system_name": "web01"}, {"rack": "\"A12\""}} {
+		if err := ValidateMetadata(m); err == nil {
+			t.Fatalf(`Expected ValidateMetadata(%v) to return error, got: nil`, m)
+		}
+	}
+}
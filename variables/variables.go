@@ -0,0 +1,125 @@
+package variables
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Names of the built-in variables, which are filled in for every booting system.
+const (
+	SystemId    = "system_id"
+	SystemName  = "system_name"
+	Mac         = "mac"
+	MacHyphen   = "mac_hyphen"
+	MacPlain    = "mac_plain"
+	MacPxelinux = "mac_pxelinux"
+	Profile     = "profile"
+	ProfileId   = "profile_id"
+	ClientIp    = "client_ip"
+	Server      = "server"
+	ServerUrl   = "server_url"
+)
+
+// Builtin contains the names of all built-in variables. They cannot be used as metadata keys.
+var Builtin = []string{SystemId, SystemName, Mac, MacHyphen, MacPlain, MacPxelinux, Profile, ProfileId, ClientIp, Server, ServerUrl}
+
+// escape is written to produce a literal '${' instead of a reference, e.g. to pass '${net0/mac}' on to iPXE.
+const escape = "$${"
+
+// namePattern matches valid variable names, which are also the valid metadata keys.
+var namePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,63}$`)
+
+// Variables maps variable names to the values that references to them are replaced with.
+type Variables map[string]string
+
+// UnknownVariableError is returned when a string references a variable that does not exist.
+type UnknownVariableError struct {
+	Name string
+}
+
+func (e UnknownVariableError) Error() string {
+	return "unknown variable ${" + e.Name + "}"
+}
+
+// Expand replaces every reference to a variable in s, e.g. '${system_name}', with its value.
+// A reference to a variable that does not exist returns an UnknownVariableError. Values are not expanded again.
+func Expand(s string, v Variables) (string, error) {
+	var b strings.Builder
+
+	err := walk(s, func(literal string, name string) error {
+		b.WriteString(literal)
+		if name == "" {
+			return nil
+		}
+
+		value, ok := v[name]
+		if !ok {
+			return UnknownVariableError{name}
+		}
+
+		b.WriteString(value)
+		return nil
+	})
+
+	return b.String(), err
+}
+
+// References returns the names of the variables that are referenced in s, in order, and returns an error if s contains an invalid reference.
+func References(s string) ([]string, error) {
+	var names []string
+
+	err := walk(s, func(literal string, name string) error {
+		if name != "" {
+			names = append(names, name)
+		}
+		return nil
+	})
+
+	return names, err
+}
+
+// Validate returns an error if s contains an invalid reference, e.g. an unterminated '${' or an invalid variable name.
+// It does not check whether the referenced variables exist, since that depends on the system that is booting.
+func Validate(s string) error {
+	_, err := References(s)
+	return err
+}
+
+// walk splits s into literal text and variable references, and calls f for each reference with the literal text that precedes it.
+// The remaining literal text is passed with an empty name.
+func walk(s string, f func(literal string, name string) error) error {
+	var literal strings.Builder
+
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			literal.WriteString(s)
+			return f(literal.String(), "")
+		}
+
+		// '$${' is an escaped '${', which is kept as-is apart from the escape
+		if i > 0 && s[i-1] == '$' {
+			literal.WriteString(s[:i-1] + "${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.Index(s[i:], "}")
+		if end < 0 {
+			return errors.New("unterminated variable reference in '" + s + "'; use " + escape + " for a literal ${")
+		}
+
+		name := s[i+2 : i+end]
+		if !namePattern.MatchString(name) {
+			return errors.New("invalid variable name '" + name + "'; use " + escape + " for a literal ${")
+		}
+
+		literal.WriteString(s[:i])
+		if err := f(literal.String(), name); err != nil {
+			return err
+		}
+		literal.Reset()
+		s = s[i+end+1:]
+	}
+}
@@ -0,0 +1,55 @@
+package variables
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	v := Variables{"server": "gobble.example.local", "system_name": "web01"}
+
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{"http://${server}/ks/${system_name}.cfg", "http://gobble.example.local/ks/web01.cfg"},
+		{"no variables", "no variables"},
+		{"${system_name}${system_name}", "web01web01"},
+		{"BOOTIF=01-$${net0/mac:hexhyp}", "BOOTIF=01-${net0/mac:hexhyp}"},
+		{"$5 and $server", "$5 and $server"},
+	}
+
+	for _, test := range tests {
+		actual, err := Expand(test.s, v)
+		if err != nil || actual != test.expected {
+			t.Fatalf(`Expand(%q) = %q, %v, expected: %q, nil`, test.s, actual, err, test.expected)
+		}
+	}
+}
+
+func TestExpandUnknownVariable(t *testing.T) {
+	actual, err := Expand("http://${server}/${unknown}", Variables{"server": "gobble.example.local"})
+
+	var unknown UnknownVariableError
+	if !errors.As(err, &unknown) || unknown.Name != "unknown" {
+		t.Fatalf(`Expand() = %q, %v, expected: "", UnknownVariableError{unknown}`, actual, err)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	expected := []string{"server", "profile"}
+
+	actual, err := References("http://${server}/images/${profile}/vmlinuz?mac=$${mac}")
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Fatalf(`References() = %v, %v, expected: %v, nil`, actual, err, expected)
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	for _, s := range []string{"http://${server/vmlinuz", "${net0/mac}", "${}", "${1st}"} {
+		if err := Validate(s); err == nil {
+			t.Fatalf(`Expected Validate(%q) to return error, got: nil`, s)
+		}
+	}
+}